@@ -4,6 +4,7 @@ import (
 	"asm/code"
 	"asm/parser"
 	"asm/symbol_table"
+	"asm/warning"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -13,17 +14,25 @@ import (
 	"strconv"
 )
 
-var symbolTable *symbol_table.SymbolTable = symbol_table.NewSymbolTable()
-
 func Compile(r io.Reader) []uint16 {
+	obj, _ := CompileWithWarnings(r)
+	return obj
+}
+
+// Compile and also report unused symbols and suspicious code.
+// Warnings can be suppressed with "// asm:ignore [kind,...]" comments.
+func CompileWithWarnings(r io.Reader) ([]uint16, []warning.Warning) {
 	p, err := parser.NewParser(r)
 	if err != nil {
 		log.Fatalf("Couldn't initialize parser : %v", err)
 	}
+	checker := warning.NewChecker(p.Ignored)
+	// Each program has its own symbols.
+	symbolTable := symbol_table.NewSymbolTable()
 
 	if !p.HasMoreCommands() {
 		// No commands
-		return make([]uint16, 0)
+		return make([]uint16, 0), checker.Warnings()
 	}
 	p.Advance()
 
@@ -37,11 +46,14 @@ func Compile(r io.Reader) []uint16 {
 		case parser.L_COMMAND:
 			label := p.Label()
 			symbolTable.AddLable(label, romAddress)
+			checker.DefineLabel(label, p.Line())
+		default:
+			// Labels don't take ROM addresses.
+			romAddress++
 		}
 		if !p.HasMoreCommands() {
 			break
 		}
-		romAddress++
 	}
 
 	p.ResetCurrent()
@@ -49,6 +61,7 @@ func Compile(r io.Reader) []uint16 {
 	// Second path
 	//log.Println("Second path")
 	obj := make([]uint16, 0)
+	prevSymbol := "" // Symbol of the previous A command for checking jumps
 	for ; ; p.Advance() {
 		cmdType := p.CommandType()
 		//log.Printf("Command=%v", p.Current())
@@ -56,8 +69,13 @@ func Compile(r io.Reader) []uint16 {
 		switch cmdType {
 		case parser.A_COMMAND:
 			symbol := p.Symbol()
+			prevSymbol = ""
 			// Variable
 			if _, err := strconv.Atoi(symbol); err != nil {
+				if !symbolTable.IsSystemSymbol(symbol) {
+					checker.Reference(symbol, p.Line())
+					prevSymbol = symbol
+				}
 				if !symbolTable.ExistVariable(symbol) {
 					symbolTable.AddVariable(symbol)
 				}
@@ -66,6 +84,10 @@ func Compile(r io.Reader) []uint16 {
 			}
 			obj = append(obj, code.A(p.Symbol()))
 		case parser.C_COMMAND:
+			if p.Jump() != "null" {
+				checker.Jump(prevSymbol, p.Comp(), p.Line())
+			}
+			prevSymbol = ""
 			obj = append(obj, code.C(p.Dest(), p.Comp(), p.Jump()))
 		case parser.L_COMMAND:
 		}
@@ -73,7 +95,7 @@ func Compile(r io.Reader) []uint16 {
 			break
 		}
 	}
-	return obj
+	return obj, checker.Warnings()
 }
 
 func main() {
@@ -88,7 +110,10 @@ func main() {
 		log.Fatalf("Couldn't open .asm : %v", err)
 	}
 
-	obj := Compile(f)
+	obj, warnings := CompileWithWarnings(f)
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "%v:%v\n", filepath.Base(path), w)
+	}
 	hackPath := filepath.Base(path[:len(path)-len(filepath.Ext(path))]) + ".hack"
 
 	hackb := ""
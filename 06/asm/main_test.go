@@ -1,6 +1,7 @@
 package main
 
 import (
+	"asm/warning"
 	"bufio"
 	"io"
 	"io/ioutil"
@@ -27,14 +28,13 @@ Return test cases made from all .asm and .hack in testDirPath
 func readTestcases(testDirPath string) []testCase {
 	cases := make([]testCase, 0)
 	filepath.Walk(testDirPath, func(path string, info os.FileInfo, err error) error {
+		// Both the programs with labels and their label-free L variants
 		if filepath.Ext(path) == ".asm" {
 			_, filename := filepath.Split(path)
-			if strings.Contains(filename, "L") {
-				asmf, _ := os.Open(path)
-				r := bufio.NewReader(asmf)
-				want := readHack(path[:len(path)-4] + ".hack")
-				cases = append(cases, testCase{name: filename, args: args{r: r}, want: want})
-			}
+			asmf, _ := os.Open(path)
+			r := bufio.NewReader(asmf)
+			want := readHack(path[:len(path)-4] + ".hack")
+			cases = append(cases, testCase{name: filename, args: args{r: r}, want: want})
 		}
 		return nil
 	})
@@ -72,3 +72,59 @@ func TestCompile(t *testing.T) {
 		})
 	}
 }
+
+func TestCompileWithWarnings(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []warning.Warning
+	}{
+		{
+			name: "no warnings",
+			src:  "@i\r\nM=1\r\n(LOOP)\r\n@i\r\nM=M+1\r\n@LOOP\r\n0;JMP\r\n",
+			want: []warning.Warning{},
+		},
+		{
+			name: "unused label and single use variable",
+			src:  "(START)\r\n@x\r\nM=1\r\n",
+			want: []warning.Warning{
+				{Line: 1, Kind: warning.UNUSED_LABEL},
+				{Line: 2, Kind: warning.SINGLE_USE_VARIABLE},
+			},
+		},
+		{
+			name: "variable shadows label",
+			src:  "(LOOP)\r\n@loop\r\nM=1\r\n@loop\r\nM=0\r\n@LOOP\r\n0;JMP\r\n",
+			want: []warning.Warning{
+				{Line: 2, Kind: warning.LABEL_CASE_SHADOW},
+			},
+		},
+		{
+			name: "jump to variable",
+			src:  "@R0\r\nD=M\r\n@end\r\nM;JGT\r\n@end\r\nM=D\r\n",
+			want: []warning.Warning{
+				{Line: 4, Kind: warning.JUMP_TO_VARIABLE},
+			},
+		},
+		{
+			name: "ignored",
+			src:  "// asm:ignore\r\n(START)\r\n@x // asm:ignore single-use-variable\r\nM=1\r\n(END) // asm:ignore jump-to-variable\r\n",
+			want: []warning.Warning{
+				{Line: 5, Kind: warning.UNUSED_LABEL},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, got := CompileWithWarnings(strings.NewReader(tt.src))
+			if len(got) != len(tt.want) {
+				t.Fatalf("CompileWithWarnings() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i].Line != tt.want[i].Line || got[i].Kind != tt.want[i].Kind {
+					t.Errorf("CompileWithWarnings()[%d] = %v, want line=%v kind=%v", i, got[i], tt.want[i].Line, tt.want[i].Kind)
+				}
+			}
+		})
+	}
+}
@@ -20,6 +20,7 @@ const (
 )
 
 var comment *regexp.Regexp = regexp.MustCompile(`(//).*`)
+var ignoreDirective *regexp.Regexp = regexp.MustCompile(`//\s*asm:ignore\b(.*)`)
 var spaceTab *regexp.Regexp = regexp.MustCompile(`[\t ]`)
 var emptyLine *regexp.Regexp = regexp.MustCompile(`(?m)^\n`)
 
@@ -30,6 +31,8 @@ type Command struct {
 
 type Parser struct {
 	commands        []string
+	lines           []int // Source line number (1-origin) of each command
+	ignores         map[int][]string
 	current         int
 	hasMoreCommands bool
 }
@@ -77,7 +80,7 @@ func (p *Parser) Label() string {
 	if p.CommandType() != L_COMMAND {
 		log.Fatalf("Can't get label from command other than L : %v", cmd)
 	}
-	return cmd[1 : len(cmd)-2]
+	return cmd[1 : len(cmd)-1]
 }
 
 func (p *Parser) Dest() string {
@@ -123,15 +126,58 @@ func (p *Parser) Current() string {
 	return p.commands[p.current]
 }
 
+// Line returns the source line number of the current command.
+func (p *Parser) Line() int {
+	return p.lines[p.current]
+}
+
+// Ignored reports whether warnings of the kind are suppressed at the source line
+// by "// asm:ignore" on the same line or on the comment-only line just before it.
+// "// asm:ignore" without kinds suppresses all warnings.
+func (p *Parser) Ignored(line int, kind string) bool {
+	for _, l := range []int{line, line - 1} {
+		kinds, ok := p.ignores[l]
+		if !ok {
+			continue
+		}
+		if l == line-1 && p.hasCommandAt(l) {
+			// A directive on the previous command only applies to that command.
+			continue
+		}
+		if len(kinds) == 0 {
+			return true
+		}
+		for _, k := range kinds {
+			if k == kind {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (p *Parser) hasCommandAt(line int) bool {
+	for _, l := range p.lines {
+		if l == line {
+			return true
+		}
+	}
+	return false
+}
+
 func (p *Parser) ResetCurrent() {
 	p.current = 0
 }
 
+func removeIrrelevant(l string) string {
+	l = comment.ReplaceAllString(l, "")
+	return spaceTab.ReplaceAllString(l, "")
+}
+
 func removeIrrelevants(lines []string) []string {
 	ret := make([]string, 0)
 	for _, l := range lines {
-		l = comment.ReplaceAllString(l, "")
-		l = spaceTab.ReplaceAllString(l, "")
+		l = removeIrrelevant(l)
 		if len(l) > 0 {
 			ret = append(ret, l)
 		}
@@ -139,14 +185,36 @@ func removeIrrelevants(lines []string) []string {
 	return ret
 }
 
+// Parse "// asm:ignore [kind[,kind...]]" in the line.
+// The second return value is false if the line has no directive.
+func parseIgnoreDirective(l string) ([]string, bool) {
+	m := ignoreDirective.FindStringSubmatch(l)
+	if m == nil {
+		return nil, false
+	}
+	kinds := make([]string, 0)
+	for _, k := range strings.FieldsFunc(m[1], func(r rune) bool { return r == ',' || r == ' ' || r == '\t' }) {
+		kinds = append(kinds, k)
+	}
+	return kinds, true
+}
+
 func NewParser(r io.Reader) (*Parser, error) {
 	b, err := ioutil.ReadAll(r)
 	s := string(b)
 	if err != nil {
 		return nil, fmt.Errorf("reading asm code failed : %v", err)
 	}
-	lines := strings.Split(s, "\r\n")
-	lines = removeIrrelevants(lines)
-	p := &Parser{commands: lines, current: -1}
+	p := &Parser{commands: []string{}, lines: []int{}, ignores: map[int][]string{}, current: -1}
+	for i, l := range strings.Split(s, "\r\n") {
+		if kinds, ok := parseIgnoreDirective(l); ok {
+			p.ignores[i+1] = kinds
+		}
+		l = removeIrrelevant(l)
+		if len(l) > 0 {
+			p.commands = append(p.commands, l)
+			p.lines = append(p.lines, i+1)
+		}
+	}
 	return p, nil
 }
@@ -57,6 +57,41 @@ func TestParser_Symbol(t *testing.T) {
 		})
 	}
 }
+
+func TestParser_Label(t *testing.T) {
+	p1, _ := NewParser(strings.NewReader("(LOOP)"))
+	p2, _ := NewParser(strings.NewReader("(X)"))
+	p3, _ := NewParser(strings.NewReader("(ball.setdestination$if_true0) // comment"))
+	tests := []struct {
+		name string
+		p    *Parser
+		want string
+	}{
+		{
+			name: "normal",
+			p:    p1,
+			want: "LOOP",
+		},
+		{
+			name: "one character",
+			p:    p2,
+			want: "X",
+		},
+		{
+			name: "with symbols and comment",
+			p:    p3,
+			want: "ball.setdestination$if_true0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.p.Advance()
+			if got := tt.p.Label(); got != tt.want {
+				t.Errorf("Parser.Label() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
 func TestParser_Dest(t *testing.T) {
 	p1, _ := NewParser(strings.NewReader("DA=M"))
 	p2, _ := NewParser(strings.NewReader(";JMP"))
@@ -5,15 +5,22 @@ import (
 	"log"
 )
 
-const baseAddress uint16 = 1024
+// Variables are allocated from RAM[16] after R0-R15.
+const baseAddress uint16 = 16
 
 type SymbolTable struct {
-	table  map[string]uint16
-	offset uint16
+	table   map[string]uint16
+	offset  uint16
+	systems map[string]bool
 }
 
 func (t *SymbolTable) addSystemSymbol(newSymbol string, address uint16) {
 	t.table[newSymbol] = address
+	t.systems[newSymbol] = true
+}
+
+func (t *SymbolTable) IsSystemSymbol(symbol string) bool {
+	return t.systems[symbol]
 }
 
 func (t *SymbolTable) AddVariable(newSymbol string) uint16 {
@@ -41,13 +48,13 @@ func (t *SymbolTable) GetAddress(symbol string) uint16 {
 }
 
 func NewSymbolTable() *SymbolTable {
-	t := SymbolTable{table: map[string]uint16{}}
+	t := SymbolTable{table: map[string]uint16{}, systems: map[string]bool{}}
 	t.addSystemSymbol("SP", 0)
 	t.addSystemSymbol("LCL", 1)
 	t.addSystemSymbol("ARG", 2)
 	t.addSystemSymbol("THIS", 3)
 	t.addSystemSymbol("THAT", 4)
-	for i := uint16(0); i < 15; i++ {
+	for i := uint16(0); i < 16; i++ {
 		t.addSystemSymbol(fmt.Sprintf("R%d", i), i)
 	}
 	t.addSystemSymbol("SCREEN", 16384)
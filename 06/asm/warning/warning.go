@@ -0,0 +1,124 @@
+package warning
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type Kind string
+
+const (
+	UNUSED_LABEL        Kind = "unused-label"
+	SINGLE_USE_VARIABLE Kind = "single-use-variable"
+	LABEL_CASE_SHADOW   Kind = "label-case-shadow"
+	JUMP_TO_VARIABLE    Kind = "jump-to-variable"
+)
+
+type Warning struct {
+	Line    int
+	Kind    Kind
+	Message string
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("%d: warning: %s [%s]", w.Line, w.Message, w.Kind)
+}
+
+// Checker collects labels, variable references and jumps during the two passes of the assembler
+// and reports suspicious code.
+type Checker struct {
+	labels     map[string]int // label -> line of declaration
+	labelOrder []string
+	labelRefs  map[string]int   // label -> number of references
+	varLines   map[string][]int // variable -> lines referencing it
+	varOrder   []string
+	jumps      []Warning
+	isIgnored  func(line int, kind string) bool
+}
+
+// The ignored function is consulted for every warning. Pass nil not to suppress any warnings.
+func NewChecker(ignored func(line int, kind string) bool) *Checker {
+	if ignored == nil {
+		ignored = func(int, string) bool { return false }
+	}
+	return &Checker{
+		labels:    map[string]int{},
+		labelRefs: map[string]int{},
+		varLines:  map[string][]int{},
+		isIgnored: ignored,
+	}
+}
+
+// Record a label declaration (LABEL). Called in the first pass.
+func (c *Checker) DefineLabel(label string, line int) {
+	if _, ok := c.labels[label]; !ok {
+		c.labelOrder = append(c.labelOrder, label)
+	}
+	c.labels[label] = line
+}
+
+// Record @symbol. Called in the second pass after all labels are defined.
+// Predefined symbols must not be passed.
+func (c *Checker) Reference(symbol string, line int) {
+	if _, ok := c.labels[symbol]; ok {
+		c.labelRefs[symbol]++
+		return
+	}
+	if _, ok := c.varLines[symbol]; !ok {
+		c.varOrder = append(c.varOrder, symbol)
+	}
+	c.varLines[symbol] = append(c.varLines[symbol], line)
+}
+
+// Record a jump instruction. prevSymbol is the symbol of the A command just before the jump,
+// or empty if the previous command wasn't an A command with a symbol.
+func (c *Checker) Jump(prevSymbol string, comp string, line int) {
+	if prevSymbol == "" {
+		return
+	}
+	if _, ok := c.labels[prevSymbol]; ok {
+		return
+	}
+	if _, ok := c.varLines[prevSymbol]; !ok {
+		return
+	}
+	msg := fmt.Sprintf("jump target was set from variable %v, not a label", prevSymbol)
+	if strings.Contains(comp, "M") {
+		msg += fmt.Sprintf(" (comp %v reads RAM[%v])", comp, prevSymbol)
+	}
+	c.jumps = append(c.jumps, Warning{Line: line, Kind: JUMP_TO_VARIABLE, Message: msg})
+}
+
+// Return warnings sorted by line.
+func (c *Checker) Warnings() []Warning {
+	ws := make([]Warning, 0)
+	add := func(w Warning) {
+		if !c.isIgnored(w.Line, string(w.Kind)) {
+			ws = append(ws, w)
+		}
+	}
+
+	for _, label := range c.labelOrder {
+		if c.labelRefs[label] == 0 {
+			add(Warning{Line: c.labels[label], Kind: UNUSED_LABEL, Message: fmt.Sprintf("label %v is never referenced", label)})
+		}
+	}
+	for _, v := range c.varOrder {
+		lines := c.varLines[v]
+		if len(lines) == 1 {
+			add(Warning{Line: lines[0], Kind: SINGLE_USE_VARIABLE, Message: fmt.Sprintf("variable %v is used only once", v)})
+		}
+		for _, label := range c.labelOrder {
+			if strings.EqualFold(v, label) {
+				add(Warning{Line: lines[0], Kind: LABEL_CASE_SHADOW, Message: fmt.Sprintf("variable %v differs from label %v only in case", v, label)})
+			}
+		}
+	}
+	for _, w := range c.jumps {
+		add(w)
+	}
+
+	sort.SliceStable(ws, func(i, j int) bool { return ws[i].Line < ws[j].Line })
+	return ws
+}
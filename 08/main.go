@@ -35,6 +35,10 @@ func Compile(r io.Reader, vmName string, bootstrap bool) string {
 		code = append(code, codewriter.Bootstrap()...)
 	}
 	for ; ; p.Advance() {
+		// Carry the position in the .jack source to the assembly.
+		if source := p.Source(); source != "" {
+			code = append(code, "// "+source)
+		}
 		cmdType := p.CommandType()
 		switch cmdType {
 		case parser.C_PUSH, parser.C_POP:
@@ -31,3 +31,20 @@ func TestCompile(t *testing.T) {
 		})
 	}
 }
+
+func TestCompile_SourceComments(t *testing.T) {
+	vm := "// Main.jack:2 function void f() {\r\nfunction Main.f 0\r\n// other comment\r\n// Main.jack:3 return;\r\npush constant 0\r\nreturn"
+	got := strings.Split(Compile(strings.NewReader(vm), "Main", false), SEP)
+	want := []string{"// Main.jack:2 function void f() {", "(Main.f)", "// Main.jack:3 return;"}
+	j := 0
+	for _, line := range got {
+		if j < len(want) && strings.HasPrefix(line, want[j]) {
+			j++
+		} else if strings.HasPrefix(line, "// ") {
+			t.Errorf("Unexpected comment: %v", line)
+		}
+	}
+	if j < len(want) {
+		t.Errorf("%q isn't in the assembly in order:\n%v", want[j], strings.Join(got, "\n"))
+	}
+}
@@ -11,6 +11,7 @@ import (
 
 type Parser struct {
 	commands        []string
+	sources         []string // Source position comment before each command such as "Main.jack:42 let x = 1;", or empty
 	current         int
 	hasMoreCommands bool
 }
@@ -77,6 +78,9 @@ var comment *regexp.Regexp = regexp.MustCompile(`(//).*`)
 var spaceTabTrim *regexp.Regexp = regexp.MustCompile(`^[\t ]+|[\t ]+$`)
 var emptyLine *regexp.Regexp = regexp.MustCompile(`(?m)^\n`)
 
+// A comment which the Jack compiler writes with -g, such as "// Main.jack:42 let x = 1;"
+var sourceComment *regexp.Regexp = regexp.MustCompile(`^[\t ]*//[\t ]*(\S+\.jack:\d+.*?)[\t ]*$`)
+
 func (p *Parser) HasMoreCommands() bool {
 	return len(p.commands)-1 > p.current
 }
@@ -92,6 +96,11 @@ func (p *Parser) Current() string {
 	return p.commands[p.current]
 }
 
+// Return the source position comment written just before the current command, or empty if there is none.
+func (p *Parser) Source() string {
+	return p.sources[p.current]
+}
+
 // Implement only C_ARITHMETIC, C_PUSH, C_POP for the project 07
 func (p *Parser) CommandType() CommandType {
 	cmdLine := p.Current()
@@ -146,16 +155,25 @@ func (p *Parser) Arg2() string {
 	return tokens[2]
 }
 
-func removeIrrelvants(lines []string) []string {
+// Return the commands and the source position comment before each of them.
+func removeIrrelvants(lines []string) ([]string, []string) {
 	ret := make([]string, 0)
+	sources := make([]string, 0)
+	source := ""
 	for _, l := range lines {
+		if m := sourceComment.FindStringSubmatch(l); m != nil {
+			source = m[1]
+			continue
+		}
 		l = comment.ReplaceAllString(l, "")
 		l = spaceTabTrim.ReplaceAllString(l, "")
 		if len(l) > 0 {
 			ret = append(ret, l)
+			sources = append(sources, source)
+			source = ""
 		}
 	}
-	return ret
+	return ret, sources
 }
 
 func NewParser(r io.Reader) (*Parser, error) {
@@ -170,7 +188,7 @@ func NewParser(r io.Reader) (*Parser, error) {
 	} else {
 		lines = strings.Split(s, "\n")
 	}
-	lines = removeIrrelvants(lines)
-	p := &Parser{commands: lines, current: -1}
+	commands, sources := removeIrrelvants(lines)
+	p := &Parser{commands: commands, sources: sources, current: -1}
 	return p, nil
 }
@@ -1,5 +1,8 @@
-module compiler
+module analyzer
 
 go 1.17
 
-require github.com/google/go-cmp v0.5.7 // indirect
+require compiler v0.0.0
+
+// The tokenizer and the parser are shared with the compiler of chapter 11.
+replace compiler => ../11
@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"compiler/ast"
 	"compiler/parser"
 	"compiler/tokenizer"
 )
@@ -16,8 +17,20 @@ import (
 var (
 	tokenizeOnly = flag.Bool("tokenize", false, "Tokenization only mode")
 	parse        = flag.Bool("parse", false, "Tokenization + Parsing mode")
+	maxErrors    = flag.Int("max-errors", 10, "Maximum number of syntax errors reported. 0 means no limit")
 )
 
+// Print the lexical or syntax errors up to -max-errors.
+func printErrors(errs []error) {
+	for i, e := range errs {
+		if *maxErrors > 0 && i >= *maxErrors {
+			fmt.Fprintf(os.Stderr, "too many errors (%v more)\n", len(errs)-i)
+			return
+		}
+		fmt.Fprintln(os.Stderr, e)
+	}
+}
+
 func compile(srcPath string) error {
 	f, err := os.Open(srcPath)
 	if err != nil {
@@ -25,16 +38,23 @@ func compile(srcPath string) error {
 	}
 
 	// Tokenize
-	tokenizer, err := tokenizer.NewTokenizer(f)
+	tk, err := tokenizer.NewTokenizer(f)
 	if err != nil {
 		log.Fatalf("Failed to initialize tokenizer: %v", err)
 	}
-	err = tokenizer.Tokenize()
-	if err != nil {
+	err = tk.Tokenize()
+	if lexErrs, ok := err.(tokenizer.LexErrorList); ok {
+		errs := make([]error, len(lexErrs))
+		for i, e := range lexErrs {
+			errs[i] = fmt.Errorf("%v:%v", srcPath, e)
+		}
+		printErrors(errs)
+		return fmt.Errorf("%v lexical error(s) in %v", len(lexErrs), srcPath)
+	} else if err != nil {
 		return fmt.Errorf("Failed to tokenize: src=%v: %v", srcPath, err)
 	}
 
-	tokenXML := tokenizer.XML()
+	tokenXML := tk.XML()
 	base := filepath.Base(srcPath)
 	tokenFilename := base[:strings.LastIndex(base, ".")] + "T.xml.out"
 	tokenDstPath := filepath.Join(filepath.Dir(srcPath), tokenFilename)
@@ -49,13 +69,19 @@ func compile(srcPath string) error {
 	}
 
 	// Parse
-	parser := parser.NewParser(*tokenizer)
-	err = parser.Parse()
-	if err != nil {
+	class, err := parser.NewParser(tk, srcPath).ParseClass()
+	if errList, ok := err.(parser.ErrorList); ok {
+		errs := make([]error, len(errList))
+		for i, e := range errList {
+			errs[i] = e
+		}
+		printErrors(errs)
+		return fmt.Errorf("%v syntax error(s) in %v", len(errList), srcPath)
+	} else if err != nil {
 		return fmt.Errorf("Failed to parse: src=%v: %v", srcPath, err)
 	}
 
-	treeXML := parser.XML()
+	treeXML := ast.XML(class)
 	treeFileName := base[:strings.LastIndex(base, ".")] + ".xml.out"
 	treeDstPath := filepath.Join(filepath.Dir(srcPath), treeFileName)
 	if os.Getenv("LOGLEVEL") == "debug" {
@@ -75,7 +101,7 @@ func main() {
 	args := flag.Args()
 	if flag.NArg() < 1 {
 		exe, _ := os.Executable()
-		fmt.Fprintf(os.Stderr, "Usage: %v <.jack/.jack dir> [-tokenize | -parse]\n", filepath.Base(exe))
+		fmt.Fprintf(os.Stderr, "Usage: %v [-tokenize | -parse] [-max-errors n] <.jack/.jack dir>\n", filepath.Base(exe))
 		os.Exit(1)
 	}
 
@@ -0,0 +1,345 @@
+package ast
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Pos is a position in the source. Line and column start with 1.
+type Pos struct {
+	Line   int
+	Column int
+}
+
+// Make Pos from Token.Pos() of the tokenizer.
+func NewPos(pos []int) Pos {
+	return Pos{pos[0], pos[1]}
+}
+
+func (p Pos) String() string {
+	return fmt.Sprintf("line=%v, column=%v", p.Line, p.Column)
+}
+
+// Node is a node of the syntax tree.
+type Node interface {
+	Pos() Pos // Position of the first token of the node
+}
+
+// Expr is an expression node.
+type Expr interface {
+	Node
+	exprNode()
+}
+
+// Stmt is a statement node.
+type Stmt interface {
+	Node
+	stmtNode()
+}
+
+// Ident is an identifier: a name of a class, subroutine or variable.
+type Ident struct {
+	NamePos Pos
+	Name    string
+}
+
+// Type is int, char, boolean, void or a class name.
+type Type struct {
+	TypePos Pos
+	Name    string
+}
+
+// Whether the type is written with a keyword rather than a class name
+func (t *Type) IsKeyword() bool {
+	switch t.Name {
+	case "int", "char", "boolean", "void":
+		return true
+	}
+	return false
+}
+
+// Class is a class declaration, the root of the tree.
+type Class struct {
+	Doc         string // Doc comment /** */ before the declaration. Empty if there is none.
+	ClassPos    Pos
+	Name        *Ident
+	Vars        []*ClassVarDec
+	Consts      []*ConstDecl
+	Enums       []*EnumDecl
+	Subroutines []*Subroutine
+}
+
+// Return the declarations of the class variables, constants and enums in the source order.
+func (c *Class) Decls() []Node {
+	decls := []Node{}
+	for _, d := range c.Vars {
+		decls = append(decls, d)
+	}
+	for _, d := range c.Consts {
+		decls = append(decls, d)
+	}
+	for _, d := range c.Enums {
+		decls = append(decls, d)
+	}
+	sort.SliceStable(decls, func(i, j int) bool {
+		pi, pj := decls[i].Pos(), decls[j].Pos()
+		return pi.Line < pj.Line || pi.Line == pj.Line && pi.Column < pj.Column
+	})
+	return decls
+}
+
+// static or field declaration
+type ClassVarDec struct {
+	Doc     string
+	DeclPos Pos
+	Kind    string // static or field
+	Type    *Type
+	Names   []*Ident
+}
+
+// const Type Name = Value; of the language extensions. Value must be a constant expression.
+type ConstDecl struct {
+	Doc      string
+	ConstPos Pos
+	Type     *Type
+	Name     *Ident
+	Value    Expr
+}
+
+// enum Name { Members } of the language extensions.
+// The members are int constants of the class numbered from 0 in the order.
+type EnumDecl struct {
+	Doc     string
+	EnumPos Pos
+	Name    *Ident
+	Members []*Ident
+	Rbrace  Pos
+}
+
+// Subroutine is a constructor, function or method declaration.
+type Subroutine struct {
+	Doc        string
+	DeclPos    Pos
+	Kind       string // constructor, function or method
+	ReturnType *Type
+	Name       *Ident
+	Params     []*Param
+	Locals     []*VarDec
+	Body       *Block
+}
+
+type Param struct {
+	Type *Type
+	Name *Ident
+}
+
+// var declaration in a subroutine body
+type VarDec struct {
+	VarPos Pos
+	Type   *Type
+	Names  []*Ident
+}
+
+// Block is statements in { }.
+type Block struct {
+	Lbrace Pos
+	Stmts  []Stmt
+	Rbrace Pos
+}
+
+// Statements
+
+// let Name[Index] = Value; Index is nil unless it's an array element.
+// Op is the operator of the compound assignment of the language extension, e.g. + of +=. It's empty for =.
+// Op is ++ or -- for the increment and decrement, and Value is nil then.
+type LetStmt struct {
+	LetPos Pos
+	Name   *Ident
+	Index  Expr
+	OpPos  Pos // Position of the assignment operator
+	Op     string
+	Value  Expr
+}
+
+// if (Cond) Then else Else. Else is nil if there is no else clause.
+// ElseIf is the if statement after else of the language extension. Else is nil if it's set.
+type IfStmt struct {
+	IfPos  Pos
+	Cond   Expr
+	Then   *Block
+	Else   *Block
+	ElseIf *IfStmt
+}
+
+type WhileStmt struct {
+	WhilePos Pos
+	Cond     Expr
+	Body     *Block
+}
+
+// for (Init; Cond; Post) Body. Init and Post are let statements. Any of them is nil if it's omitted.
+type ForStmt struct {
+	ForPos Pos
+	Init   *LetStmt
+	Cond   Expr
+	Post   *LetStmt
+	Body   *Block
+}
+
+// break or continue. It's a language extension.
+type BranchStmt struct {
+	TokPos Pos
+	Tok    string // break or continue
+}
+
+// switch (Tag) { Cases }. It's a language extension.
+type SwitchStmt struct {
+	SwitchPos Pos
+	Tag       Expr
+	Cases     []*CaseClause
+	Rbrace    Pos
+}
+
+// case Value: Body or default: Body. Value is nil for default.
+// The body ends at the next case. It doesn't fall through.
+type CaseClause struct {
+	CasePos Pos
+	Value   Expr
+	Body    []Stmt
+}
+
+type DoStmt struct {
+	DoPos Pos
+	Call  *CallExpr
+}
+
+// return Value; Value is nil for a void return.
+type ReturnStmt struct {
+	ReturnPos Pos
+	Value     Expr
+}
+
+// Expressions
+
+type IntLit struct {
+	ValuePos Pos
+	Value    int
+	Literal  string // As written in the source
+}
+
+type StringLit struct {
+	ValuePos Pos
+	Value    string // With the escape sequences replaced
+	Literal  string // As written in the source without the double quotes. Empty if it's the same as Value.
+}
+
+// 'A' of the language extensions. Value is the code in the Hack character set.
+type CharLit struct {
+	ValuePos Pos
+	Value    int
+	Literal  string // As written in the source without the single quotes
+}
+
+// true, false, null or this
+type KeywordConst struct {
+	KeywordPos Pos
+	Value      string
+}
+
+// Name[Index]
+type IndexExpr struct {
+	Name  *Ident
+	Index Expr
+}
+
+// Class.Name of the language extensions. It refers to a constant of the class.
+type ConstRef struct {
+	Class *Ident
+	Name  *Ident
+}
+
+// Receiver.Name(Args) or Name(Args). Receiver is nil for the latter.
+// Receiver is a class name or a variable name.
+type CallExpr struct {
+	Receiver *Ident
+	Name     *Ident
+	Args     []Expr
+}
+
+// Op X. Op is - or ~.
+type UnaryExpr struct {
+	OpPos Pos
+	Op    string
+	X     Expr
+}
+
+// X Op Y
+type BinaryExpr struct {
+	X     Expr
+	OpPos Pos
+	Op    string
+	Y     Expr
+}
+
+// (X)
+type ParenExpr struct {
+	Lparen Pos
+	X      Expr
+}
+
+func (n *Ident) Pos() Pos        { return n.NamePos }
+func (n *Type) Pos() Pos         { return n.TypePos }
+func (n *Class) Pos() Pos        { return n.ClassPos }
+func (n *ClassVarDec) Pos() Pos  { return n.DeclPos }
+func (n *ConstDecl) Pos() Pos    { return n.ConstPos }
+func (n *EnumDecl) Pos() Pos     { return n.EnumPos }
+func (n *Subroutine) Pos() Pos   { return n.DeclPos }
+func (n *Param) Pos() Pos        { return n.Type.Pos() }
+func (n *VarDec) Pos() Pos       { return n.VarPos }
+func (n *Block) Pos() Pos        { return n.Lbrace }
+func (n *LetStmt) Pos() Pos      { return n.LetPos }
+func (n *IfStmt) Pos() Pos       { return n.IfPos }
+func (n *WhileStmt) Pos() Pos    { return n.WhilePos }
+func (n *ForStmt) Pos() Pos      { return n.ForPos }
+func (n *BranchStmt) Pos() Pos   { return n.TokPos }
+func (n *SwitchStmt) Pos() Pos   { return n.SwitchPos }
+func (n *CaseClause) Pos() Pos   { return n.CasePos }
+func (n *DoStmt) Pos() Pos       { return n.DoPos }
+func (n *ReturnStmt) Pos() Pos   { return n.ReturnPos }
+func (n *IntLit) Pos() Pos       { return n.ValuePos }
+func (n *StringLit) Pos() Pos    { return n.ValuePos }
+func (n *CharLit) Pos() Pos      { return n.ValuePos }
+func (n *KeywordConst) Pos() Pos { return n.KeywordPos }
+func (n *IndexExpr) Pos() Pos    { return n.Name.Pos() }
+func (n *ConstRef) Pos() Pos     { return n.Class.Pos() }
+func (n *UnaryExpr) Pos() Pos    { return n.OpPos }
+func (n *BinaryExpr) Pos() Pos   { return n.X.Pos() }
+func (n *ParenExpr) Pos() Pos    { return n.Lparen }
+
+func (n *CallExpr) Pos() Pos {
+	if n.Receiver != nil {
+		return n.Receiver.Pos()
+	}
+	return n.Name.Pos()
+}
+
+func (*LetStmt) stmtNode()    {}
+func (*IfStmt) stmtNode()     {}
+func (*WhileStmt) stmtNode()  {}
+func (*ForStmt) stmtNode()    {}
+func (*BranchStmt) stmtNode() {}
+func (*SwitchStmt) stmtNode() {}
+func (*DoStmt) stmtNode()     {}
+func (*ReturnStmt) stmtNode() {}
+
+func (*Ident) exprNode()        {}
+func (*IntLit) exprNode()       {}
+func (*StringLit) exprNode()    {}
+func (*CharLit) exprNode()      {}
+func (*KeywordConst) exprNode() {}
+func (*IndexExpr) exprNode()    {}
+func (*ConstRef) exprNode()     {}
+func (*CallExpr) exprNode()     {}
+func (*UnaryExpr) exprNode()    {}
+func (*BinaryExpr) exprNode()   {}
+func (*ParenExpr) exprNode()    {}
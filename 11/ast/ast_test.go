@@ -0,0 +1,123 @@
+package ast
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func ident(name string) *Ident { return &Ident{Name: name} }
+
+// class A { function void f() { do Output.printInt(1 + x); return; } }
+func sampleClass() *Class {
+	call := &CallExpr{
+		Receiver: ident("Output"),
+		Name:     ident("printInt"),
+		Args:     []Expr{&BinaryExpr{X: &IntLit{Value: 1}, Op: "+", Y: ident("x")}},
+	}
+	return &Class{
+		Name: ident("A"),
+		Subroutines: []*Subroutine{{
+			Kind:       "function",
+			ReturnType: &Type{Name: "void"},
+			Name:       ident("f"),
+			Body:       &Block{Stmts: []Stmt{&DoStmt{Call: call}, &ReturnStmt{}}},
+		}},
+	}
+}
+
+func TestWalk(t *testing.T) {
+	got := []string{}
+	Inspect(sampleClass(), func(n Node) bool {
+		switch n := n.(type) {
+		case *Ident:
+			got = append(got, n.Name)
+		case *IntLit:
+			got = append(got, "1")
+		case *CallExpr:
+			// Don't visit the arguments
+			got = append(got, "call")
+			return false
+		}
+		return true
+	})
+	want := []string{"A", "f", "call"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("visited nodes differ: %v", diff)
+	}
+}
+
+func TestXML(t *testing.T) {
+	want := `<class>
+  <keyword> class </keyword>
+  <identifier> A </identifier>
+  <symbol> { </symbol>
+  <subroutineDec>
+    <keyword> function </keyword>
+    <keyword> void </keyword>
+    <identifier> f </identifier>
+    <symbol> ( </symbol>
+    <parameterList>
+    </parameterList>
+    <symbol> ) </symbol>
+    <subroutineBody>
+      <symbol> { </symbol>
+      <statements>
+        <doStatement>
+          <keyword> do </keyword>
+          <identifier> Output </identifier>
+          <symbol> . </symbol>
+          <identifier> printInt </identifier>
+          <symbol> ( </symbol>
+          <expressionList>
+            <expression>
+              <term>
+                <integerConstant> 1 </integerConstant>
+              </term>
+              <symbol> + </symbol>
+              <term>
+                <identifier> x </identifier>
+              </term>
+            </expression>
+          </expressionList>
+          <symbol> ) </symbol>
+          <symbol> ; </symbol>
+        </doStatement>
+        <returnStatement>
+          <keyword> return </keyword>
+          <symbol> ; </symbol>
+        </returnStatement>
+      </statements>
+      <symbol> } </symbol>
+    </subroutineBody>
+  </subroutineDec>
+  <symbol> } </symbol>
+</class>
+`
+	if diff := cmp.Diff(want, XML(sampleClass())); diff != "" {
+		t.Errorf("XML differs: %v", diff)
+	}
+}
+
+func TestClass_Decls(t *testing.T) {
+	c := &Class{
+		Vars:   []*ClassVarDec{{DeclPos: Pos{2, 3}, Names: []*Ident{ident("a")}}, {DeclPos: Pos{5, 3}, Names: []*Ident{ident("d")}}},
+		Consts: []*ConstDecl{{ConstPos: Pos{3, 3}, Name: ident("B")}, {ConstPos: Pos{5, 1}, Name: ident("C")}},
+		Enums:  []*EnumDecl{{EnumPos: Pos{4, 3}, Name: ident("E")}},
+	}
+	got := []string{}
+	for _, d := range c.Decls() {
+		switch d := d.(type) {
+		case *ClassVarDec:
+			got = append(got, d.Names[0].Name)
+		case *ConstDecl:
+			got = append(got, d.Name.Name)
+		case *EnumDecl:
+			got = append(got, d.Name.Name)
+		}
+	}
+	want := []string{"a", "B", "E", "C", "d"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("declarations differ: %v", diff)
+	}
+}
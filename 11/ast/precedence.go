@@ -0,0 +1,149 @@
+package ast
+
+// Conventional precedence of the binary operators. A larger number binds tighter.
+// Jack itself has no precedence and applies operators from left to right.
+var precedence = map[string]int{
+	"*": 7, "/": 7,
+	"+": 6, "-": 6,
+	"<": 5, ">": 5, "=": 5,
+	"&": 4,
+	"|": 3,
+	// The logical operators of the language extensions
+	"&&": 2,
+	"||": 1,
+}
+
+// Split the chain of binary operators written without parentheses, X op Y op Z ..., into terms and operators.
+// The parser builds the chain leaning to the left.
+func flatten(e *BinaryExpr) ([]Expr, []*BinaryExpr) {
+	terms := []Expr{e.Y}
+	ops := []*BinaryExpr{e}
+	x := e.X
+	for {
+		b, ok := x.(*BinaryExpr)
+		if !ok {
+			break
+		}
+		terms = append(terms, b.Y)
+		ops = append(ops, b)
+		x = b.X
+	}
+	terms = append(terms, x)
+	// Reverse to the order of the source.
+	for i, j := 0, len(terms)-1; i < j; i, j = i+1, j-1 {
+		terms[i], terms[j] = terms[j], terms[i]
+	}
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return terms, ops
+}
+
+// Build the tree of the chain by the conventional precedence. Operators of the same precedence are left associative.
+func group(terms []Expr, ops []*BinaryExpr) Expr {
+	operands := []Expr{terms[0]}
+	stack := []*BinaryExpr{}
+	reduce := func() {
+		op := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		n := len(operands)
+		operands = append(operands[:n-2], &BinaryExpr{X: operands[n-2], OpPos: op.OpPos, Op: op.Op, Y: operands[n-1]})
+	}
+	for i, op := range ops {
+		for len(stack) > 0 && precedence[stack[len(stack)-1].Op] >= precedence[op.Op] {
+			reduce()
+		}
+		stack = append(stack, op)
+		operands = append(operands, terms[i+1])
+	}
+	for len(stack) > 0 {
+		reduce()
+	}
+	return operands[0]
+}
+
+// Return the first operator which binds tighter than an operator before it in the chain, and the nearest such operator.
+// The value of the chain may differ between Jack and the conventional precedence only if it exists.
+func PrecedenceConflict(e *BinaryExpr) (earlier *BinaryExpr, later *BinaryExpr, ok bool) {
+	_, ops := flatten(e)
+	for j := 1; j < len(ops); j++ {
+		for i := j - 1; i >= 0; i-- {
+			if precedence[ops[j].Op] > precedence[ops[i].Op] {
+				return ops[i], ops[j], true
+			}
+		}
+	}
+	return nil, nil, false
+}
+
+// Return the chains of binary operators in the class. Inner chains in parentheses and arguments are included.
+func BinaryChains(c *Class) []*BinaryExpr {
+	chains := []*BinaryExpr{}
+	inChain := map[*BinaryExpr]bool{}
+	Inspect(c, func(n Node) bool {
+		if b, ok := n.(*BinaryExpr); ok && !inChain[b] {
+			chains = append(chains, b)
+			_, ops := flatten(b)
+			for _, op := range ops {
+				inChain[op] = true
+			}
+		}
+		return true
+	})
+	return chains
+}
+
+// Regroup all chains of binary operators in the class by the conventional precedence instead of from left to right.
+func ApplyPrecedence(c *Class) {
+	regroup := func(e Expr) Expr {
+		if b, ok := e.(*BinaryExpr); ok {
+			terms, ops := flatten(b)
+			return group(terms, ops)
+		}
+		return e
+	}
+	Inspect(c, func(n Node) bool {
+		switch n := n.(type) {
+		case *BinaryExpr:
+			// The operands are the terms of the chain regrouped by its parent.
+		case *LetStmt:
+			if n.Index != nil {
+				n.Index = regroup(n.Index)
+			}
+			if n.Value != nil {
+				n.Value = regroup(n.Value)
+			}
+		case *IfStmt:
+			n.Cond = regroup(n.Cond)
+		case *WhileStmt:
+			n.Cond = regroup(n.Cond)
+		case *SwitchStmt:
+			n.Tag = regroup(n.Tag)
+		case *CaseClause:
+			if n.Value != nil {
+				n.Value = regroup(n.Value)
+			}
+		case *ForStmt:
+			if n.Cond != nil {
+				n.Cond = regroup(n.Cond)
+			}
+		case *ReturnStmt:
+			if n.Value != nil {
+				n.Value = regroup(n.Value)
+			}
+		case *ConstDecl:
+			n.Value = regroup(n.Value)
+		case *IndexExpr:
+			n.Index = regroup(n.Index)
+		case *CallExpr:
+			for i, a := range n.Args {
+				n.Args[i] = regroup(a)
+			}
+		case *UnaryExpr:
+			n.X = regroup(n.X)
+		case *ParenExpr:
+			n.X = regroup(n.X)
+		}
+		return true
+	})
+}
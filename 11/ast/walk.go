@@ -0,0 +1,151 @@
+package ast
+
+// A Visitor's Visit method is invoked for each node encountered by Walk.
+// If the result visitor w is not nil, Walk visits each of the children of node with w,
+// followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses the tree in depth-first order, in the order of the source.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Class:
+		Walk(v, n.Name)
+		for _, d := range n.Decls() {
+			Walk(v, d)
+		}
+		for _, s := range n.Subroutines {
+			Walk(v, s)
+		}
+	case *ClassVarDec:
+		Walk(v, n.Type)
+		for _, name := range n.Names {
+			Walk(v, name)
+		}
+	case *ConstDecl:
+		Walk(v, n.Type)
+		Walk(v, n.Name)
+		Walk(v, n.Value)
+	case *EnumDecl:
+		Walk(v, n.Name)
+		for _, m := range n.Members {
+			Walk(v, m)
+		}
+	case *Subroutine:
+		Walk(v, n.ReturnType)
+		Walk(v, n.Name)
+		for _, p := range n.Params {
+			Walk(v, p)
+		}
+		for _, d := range n.Locals {
+			Walk(v, d)
+		}
+		Walk(v, n.Body)
+	case *Param:
+		Walk(v, n.Type)
+		Walk(v, n.Name)
+	case *VarDec:
+		Walk(v, n.Type)
+		for _, name := range n.Names {
+			Walk(v, name)
+		}
+	case *Block:
+		for _, s := range n.Stmts {
+			Walk(v, s)
+		}
+	case *LetStmt:
+		Walk(v, n.Name)
+		if n.Index != nil {
+			Walk(v, n.Index)
+		}
+		if n.Value != nil {
+			Walk(v, n.Value)
+		}
+	case *IfStmt:
+		Walk(v, n.Cond)
+		Walk(v, n.Then)
+		if n.Else != nil {
+			Walk(v, n.Else)
+		}
+		if n.ElseIf != nil {
+			Walk(v, n.ElseIf)
+		}
+	case *WhileStmt:
+		Walk(v, n.Cond)
+		Walk(v, n.Body)
+	case *ForStmt:
+		if n.Init != nil {
+			Walk(v, n.Init)
+		}
+		if n.Cond != nil {
+			Walk(v, n.Cond)
+		}
+		if n.Post != nil {
+			Walk(v, n.Post)
+		}
+		Walk(v, n.Body)
+	case *SwitchStmt:
+		Walk(v, n.Tag)
+		for _, c := range n.Cases {
+			Walk(v, c)
+		}
+	case *CaseClause:
+		if n.Value != nil {
+			Walk(v, n.Value)
+		}
+		for _, s := range n.Body {
+			Walk(v, s)
+		}
+	case *DoStmt:
+		Walk(v, n.Call)
+	case *ReturnStmt:
+		if n.Value != nil {
+			Walk(v, n.Value)
+		}
+	case *IndexExpr:
+		Walk(v, n.Name)
+		Walk(v, n.Index)
+	case *ConstRef:
+		Walk(v, n.Class)
+		Walk(v, n.Name)
+	case *CallExpr:
+		if n.Receiver != nil {
+			Walk(v, n.Receiver)
+		}
+		Walk(v, n.Name)
+		for _, a := range n.Args {
+			Walk(v, a)
+		}
+	case *UnaryExpr:
+		Walk(v, n.X)
+	case *BinaryExpr:
+		Walk(v, n.X)
+		Walk(v, n.Y)
+	case *ParenExpr:
+		Walk(v, n.X)
+	case *Ident, *Type, *BranchStmt, *IntLit, *StringLit, *CharLit, *KeywordConst:
+		// Leaves
+	}
+
+	v.Visit(nil)
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses the tree and calls f for each node.
+// If f returns true, Inspect visits the children of the node, followed by a call of f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
@@ -0,0 +1,372 @@
+package ast
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strconv"
+	"strings"
+)
+
+// Element names of terminals. Same as the token types of the tokenizer.
+const (
+	xmlKeyword    = "keyword"
+	xmlSymbol     = "symbol"
+	xmlIdentifier = "identifier"
+	xmlIntConst   = "integerConstant"
+	xmlStrConst   = "stringConstant"
+	xmlCharConst  = "charConstant"
+)
+
+type xmlPrinter struct {
+	buf   bytes.Buffer
+	depth int
+}
+
+func (p *xmlPrinter) indent() {
+	p.buf.WriteString(strings.Repeat("  ", p.depth))
+}
+
+func (p *xmlPrinter) terminal(elemName string, s string) {
+	p.indent()
+	p.buf.WriteString("<" + elemName + "> ")
+	xml.EscapeText(&p.buf, []byte(s))
+	p.buf.WriteString(" </" + elemName + ">\n")
+}
+
+func (p *xmlPrinter) keyword(s string) { p.terminal(xmlKeyword, s) }
+func (p *xmlPrinter) symbol(s string)  { p.terminal(xmlSymbol, s) }
+func (p *xmlPrinter) ident(n *Ident)   { p.terminal(xmlIdentifier, n.Name) }
+
+func (p *xmlPrinter) typeName(t *Type) {
+	if t.IsKeyword() {
+		p.keyword(t.Name)
+	} else {
+		p.terminal(xmlIdentifier, t.Name)
+	}
+}
+
+// Write a non-terminal. Empty ones are written in two lines as Nand2Tetris does.
+func (p *xmlPrinter) nonTerminal(elemName string, children func()) {
+	p.indent()
+	p.buf.WriteString("<" + elemName + ">\n")
+	p.depth++
+	children()
+	p.depth--
+	p.indent()
+	p.buf.WriteString("</" + elemName + ">\n")
+}
+
+// XML returns the parse tree of the class in the format of Nand2Tetris chapter 10.
+func XML(c *Class) string {
+	p := &xmlPrinter{}
+	p.class(c)
+	return p.buf.String()
+}
+
+func (p *xmlPrinter) class(c *Class) {
+	p.nonTerminal("class", func() {
+		p.keyword("class")
+		p.ident(c.Name)
+		p.symbol("{")
+		for _, d := range c.Decls() {
+			switch d := d.(type) {
+			case *ClassVarDec:
+				p.classVarDec(d)
+			case *ConstDecl:
+				p.constDec(d)
+			case *EnumDecl:
+				p.enumDec(d)
+			}
+		}
+		for _, s := range c.Subroutines {
+			p.subroutine(s)
+		}
+		p.symbol("}")
+	})
+}
+
+func (p *xmlPrinter) names(names []*Ident) {
+	for i, name := range names {
+		if i > 0 {
+			p.symbol(",")
+		}
+		p.ident(name)
+	}
+}
+
+func (p *xmlPrinter) classVarDec(d *ClassVarDec) {
+	p.nonTerminal("classVarDec", func() {
+		p.keyword(d.Kind)
+		p.typeName(d.Type)
+		p.names(d.Names)
+		p.symbol(";")
+	})
+}
+
+func (p *xmlPrinter) constDec(d *ConstDecl) {
+	p.nonTerminal("constDec", func() {
+		p.keyword("const")
+		p.typeName(d.Type)
+		p.ident(d.Name)
+		p.symbol("=")
+		p.expression(d.Value)
+		p.symbol(";")
+	})
+}
+
+func (p *xmlPrinter) enumDec(d *EnumDecl) {
+	p.nonTerminal("enumDec", func() {
+		p.keyword("enum")
+		p.ident(d.Name)
+		p.symbol("{")
+		p.names(d.Members)
+		p.symbol("}")
+	})
+}
+
+func (p *xmlPrinter) subroutine(s *Subroutine) {
+	p.nonTerminal("subroutineDec", func() {
+		p.keyword(s.Kind)
+		p.typeName(s.ReturnType)
+		p.ident(s.Name)
+		p.symbol("(")
+		p.nonTerminal("parameterList", func() {
+			for i, param := range s.Params {
+				if i > 0 {
+					p.symbol(",")
+				}
+				p.typeName(param.Type)
+				p.ident(param.Name)
+			}
+		})
+		p.symbol(")")
+		p.nonTerminal("subroutineBody", func() {
+			p.symbol("{")
+			for _, d := range s.Locals {
+				p.nonTerminal("varDec", func() {
+					p.keyword("var")
+					p.typeName(d.Type)
+					p.names(d.Names)
+					p.symbol(";")
+				})
+			}
+			p.statements(s.Body.Stmts)
+			p.symbol("}")
+		})
+	})
+}
+
+func (p *xmlPrinter) block(b *Block) {
+	p.symbol("{")
+	p.statements(b.Stmts)
+	p.symbol("}")
+}
+
+func (p *xmlPrinter) statements(stmts []Stmt) {
+	p.nonTerminal("statements", func() {
+		for _, s := range stmts {
+			p.statement(s)
+		}
+	})
+}
+
+func (p *xmlPrinter) statement(s Stmt) {
+	switch s := s.(type) {
+	case *LetStmt:
+		p.let(s, true)
+	case *IfStmt:
+		p.nonTerminal("ifStatement", func() {
+			p.keyword("if")
+			p.symbol("(")
+			p.expression(s.Cond)
+			p.symbol(")")
+			p.block(s.Then)
+			if s.Else != nil {
+				p.keyword("else")
+				p.block(s.Else)
+			}
+			if s.ElseIf != nil {
+				p.keyword("else")
+				p.statement(s.ElseIf)
+			}
+		})
+	case *WhileStmt:
+		p.nonTerminal("whileStatement", func() {
+			p.keyword("while")
+			p.symbol("(")
+			p.expression(s.Cond)
+			p.symbol(")")
+			p.block(s.Body)
+		})
+	case *ForStmt:
+		p.nonTerminal("forStatement", func() {
+			p.keyword("for")
+			p.symbol("(")
+			if s.Init != nil {
+				p.let(s.Init, true)
+			} else {
+				p.symbol(";")
+			}
+			if s.Cond != nil {
+				p.expression(s.Cond)
+			}
+			p.symbol(";")
+			if s.Post != nil {
+				p.let(s.Post, false)
+			}
+			p.symbol(")")
+			p.block(s.Body)
+		})
+	case *SwitchStmt:
+		p.nonTerminal("switchStatement", func() {
+			p.keyword("switch")
+			p.symbol("(")
+			p.expression(s.Tag)
+			p.symbol(")")
+			p.symbol("{")
+			for _, c := range s.Cases {
+				p.nonTerminal("caseClause", func() {
+					if c.Value != nil {
+						p.keyword("case")
+						p.expression(c.Value)
+					} else {
+						p.keyword("default")
+					}
+					p.symbol(":")
+					p.statements(c.Body)
+				})
+			}
+			p.symbol("}")
+		})
+	case *BranchStmt:
+		p.nonTerminal(s.Tok+"Statement", func() {
+			p.keyword(s.Tok)
+			p.symbol(";")
+		})
+	case *DoStmt:
+		p.nonTerminal("doStatement", func() {
+			p.keyword("do")
+			p.call(s.Call)
+			p.symbol(";")
+		})
+	case *ReturnStmt:
+		p.nonTerminal("returnStatement", func() {
+			p.keyword("return")
+			if s.Value != nil {
+				p.expression(s.Value)
+			}
+			p.symbol(";")
+		})
+	}
+}
+
+// The post statement of for has no semicolon.
+func (p *xmlPrinter) let(s *LetStmt, semicolon bool) {
+	p.nonTerminal("letStatement", func() {
+		p.keyword("let")
+		p.ident(s.Name)
+		if s.Index != nil {
+			p.symbol("[")
+			p.expression(s.Index)
+			p.symbol("]")
+		}
+		switch s.Op {
+		case "":
+			p.symbol("=")
+		case "++", "--":
+			p.symbol(s.Op)
+		default:
+			p.symbol(s.Op + "=")
+		}
+		if s.Value != nil {
+			p.expression(s.Value)
+		}
+		if semicolon {
+			p.symbol(";")
+		}
+	})
+}
+
+// Binary expressions are written flat as "term (op term)*" in the source order whatever the shape of the tree is.
+func (p *xmlPrinter) expression(e Expr) {
+	p.nonTerminal("expression", func() {
+		p.operands(e)
+	})
+}
+
+func (p *xmlPrinter) operands(e Expr) {
+	if b, ok := e.(*BinaryExpr); ok {
+		p.operands(b.X)
+		p.symbol(b.Op)
+		p.operands(b.Y)
+		return
+	}
+	p.term(e)
+}
+
+func (p *xmlPrinter) term(e Expr) {
+	p.nonTerminal("term", func() {
+		switch e := e.(type) {
+		case *IntLit:
+			literal := e.Literal
+			if literal == "" {
+				literal = strconv.Itoa(e.Value)
+			}
+			p.terminal(xmlIntConst, literal)
+		case *StringLit:
+			literal := e.Literal
+			if literal == "" {
+				literal = e.Value
+			}
+			p.terminal(xmlStrConst, literal)
+		case *CharLit:
+			p.terminal(xmlCharConst, e.Literal)
+		case *KeywordConst:
+			p.keyword(e.Value)
+		case *Ident:
+			p.ident(e)
+		case *IndexExpr:
+			p.ident(e.Name)
+			p.symbol("[")
+			p.expression(e.Index)
+			p.symbol("]")
+		case *ConstRef:
+			p.ident(e.Class)
+			p.symbol(".")
+			p.ident(e.Name)
+		case *CallExpr:
+			p.call(e)
+		case *UnaryExpr:
+			p.symbol(e.Op)
+			p.term(e.X)
+		case *ParenExpr:
+			p.symbol("(")
+			p.expression(e.X)
+			p.symbol(")")
+		case *BinaryExpr:
+			// Not a term in the grammar. Write it with parentheses not to lose the structure.
+			p.symbol("(")
+			p.expression(e)
+			p.symbol(")")
+		}
+	})
+}
+
+// Subroutine call is written inline without its own element.
+func (p *xmlPrinter) call(c *CallExpr) {
+	if c.Receiver != nil {
+		p.ident(c.Receiver)
+		p.symbol(".")
+	}
+	p.ident(c.Name)
+	p.symbol("(")
+	p.nonTerminal("expressionList", func() {
+		for i, a := range c.Args {
+			if i > 0 {
+				p.symbol(",")
+			}
+			p.expression(a)
+		}
+	})
+	p.symbol(")")
+}
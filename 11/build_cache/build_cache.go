@@ -0,0 +1,112 @@
+package build_cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Cache stores compilation outputs of .jack files keyed on the hash of the source,
+// the compiler version and the compile options.
+type Cache struct {
+	dir     string
+	version string
+}
+
+func NewCache(dir string, version string) (*Cache, error) {
+	err := os.MkdirAll(dir, 0777)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create cache directory: %v", err)
+	}
+	return &Cache{dir, version}, nil
+}
+
+// Return the default cache directory under the user cache directory.
+func DefaultDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "nand2tetris-jack")
+}
+
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+// Return the cache key for the source and options.
+func (c *Cache) Key(src []byte, options string) string {
+	h := sha256.New()
+	// Separate the fields with NUL so that they can't be confused.
+	h.Write([]byte(c.version))
+	h.Write([]byte{0})
+	h.Write([]byte(options))
+	h.Write([]byte{0})
+	h.Write(src)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *Cache) path(key string, artifact string) string {
+	return filepath.Join(c.dir, key[:2], key+"."+artifact)
+}
+
+// Return the cached artifacts (e.g. "vm", "xml") for the key.
+// The second return value is false unless all of them are cached.
+func (c *Cache) Get(key string, artifacts ...string) (map[string][]byte, bool) {
+	ret := make(map[string][]byte, len(artifacts))
+	for _, a := range artifacts {
+		b, err := os.ReadFile(c.path(key, a))
+		if err != nil {
+			return nil, false
+		}
+		ret[a] = b
+	}
+	return ret, true
+}
+
+// Store the artifacts for the key.
+func (c *Cache) Put(key string, artifacts map[string][]byte) error {
+	for a, b := range artifacts {
+		p := c.path(key, a)
+		err := os.MkdirAll(filepath.Dir(p), 0777)
+		if err != nil {
+			return fmt.Errorf("Failed to write cache: %v", err)
+		}
+		// Write to a temporary file and rename it not to leave a broken entry.
+		tmp, err := os.CreateTemp(filepath.Dir(p), key+".tmp*")
+		if err != nil {
+			return fmt.Errorf("Failed to write cache: %v", err)
+		}
+		_, err = tmp.Write(b)
+		tmp.Close()
+		if err == nil {
+			err = os.Rename(tmp.Name(), p)
+		}
+		if err != nil {
+			os.Remove(tmp.Name())
+			return fmt.Errorf("Failed to write cache: %v", err)
+		}
+	}
+	return nil
+}
+
+// Remove all entries in the cache.
+func (c *Cache) Clean() error {
+	entries, err := os.ReadDir(c.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("Failed to clean cache: %v", err)
+	}
+	for _, e := range entries {
+		err = os.RemoveAll(filepath.Join(c.dir, e.Name()))
+		if err != nil {
+			return fmt.Errorf("Failed to clean cache: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package build_cache
+
+import (
+	"testing"
+)
+
+func TestCache(t *testing.T) {
+	c, err := NewCache(t.TempDir(), "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := []byte("class Main {}")
+	key := c.Key(src, "")
+
+	if _, ok := c.Get(key, "vm"); ok {
+		t.Errorf("Get() hit before Put()")
+	}
+	err = c.Put(key, map[string][]byte{"vm": []byte("return"), "xml": []byte("<class>")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok := c.Get(key, "vm", "xml")
+	if !ok || string(got["vm"]) != "return" || string(got["xml"]) != "<class>" {
+		t.Errorf("Get() = %v, %v", got, ok)
+	}
+	if _, ok := c.Get(key, "vm", "T.xml"); ok {
+		t.Errorf("Get() hit with a missing artifact")
+	}
+
+	c2, _ := NewCache(c.Dir(), "2")
+	for name, k := range map[string]string{
+		"source":  c.Key([]byte("class Main { }"), ""),
+		"options": c.Key(src, "-ext"),
+		"version": c2.Key(src, ""),
+	} {
+		if k == key {
+			t.Errorf("Key() didn't change with %v", name)
+		}
+	}
+
+	err = c.Clean()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Get(key, "vm"); ok {
+		t.Errorf("Get() hit after Clean()")
+	}
+}
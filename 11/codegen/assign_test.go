@@ -0,0 +1,92 @@
+package codegen
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGenerator_CompoundAssignment(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []int // Results for x = 0, 3, -7
+	}{
+		{"add", "let s = 10; let s += x; return s;", []int{10, 13, 3}},
+		{"subtract", "let s = 10; let s -= x + 1; return s;", []int{9, 6, 16}},
+		{"multiply", "let s = x; let s *= 3; return s;", []int{0, 9, -21}},
+		{"divide", "let s = 100; let s /= x + 10; return s;", []int{10, 7, 33}},
+		{"and", "let s = x; let s &= 6; return s;", []int{0, 2, 0}},
+		{"or", "let s = x; let s |= 8; return s;", []int{8, 11, -7}},
+		{"increment", "let s = x; let s++; let s++; return s;", []int{2, 5, -5}},
+		{"decrement", "let s = x; let s--; return s;", []int{-1, 2, -8}},
+		{"field-like argument", "let x += 1; let x *= 2; return x;", []int{2, 8, -12}},
+		{"in for", "for (let i = 0; i < 5; let i++) { let s += i; } return s;", []int{10, 10, 10}},
+		{
+			name: "array element",
+			body: "var Array a; let a = Memory.alloc(3); let a[0] = 1; let a[1] = x; let a[2] = 2; let a[1] += a[0] + a[2]; let a[2]++; let a[0]--; return a[0] + a[1] + a[2];",
+			want: []int{6, 9, -1},
+		},
+		{
+			name: "array element in the value",
+			body: "var Array a, b; let a = Memory.alloc(2); let b = Memory.alloc(2); let b[1] = 5; let a[x & 1] = 1; let a[x & 1] *= b[1] + a[x & 1]; return a[0] + a[1];",
+			want: []int{6, 6, 6},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := runExt(t, tt.body, []int{0, 3, -7})
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("results differ: %v", diff)
+			}
+		})
+	}
+}
+
+func TestGenerator_CompoundAssignmentIndexOnce(t *testing.T) {
+	src := `class Main {
+		static int calls;
+		function int next() { let calls = calls + 1; return calls; }
+		function int f() {
+			var Array a;
+			let a = Memory.alloc(4);
+			let a[Main.next()] += 10;
+			let a[Main.next()]++;
+			return (a[1] * 100) + (a[2] * 10) + calls;
+		}
+	}`
+	got := run(t, src, options{ext: true}, "Main.f")
+	if want := 1000 + 10 + 2; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestGenerator_IncrementCode(t *testing.T) {
+	tests := []struct {
+		stmt string
+		want []string
+	}{
+		{"let x++;", []string{"push argument 0", "push constant 1", "add", "pop argument 0"}},
+		{"let s--;", []string{"push local 0", "push constant 1", "sub", "pop local 0"}},
+		{"let a[x]++;", []string{"push argument 0", "push local 1", "add", "pop pointer 1", "push that 0", "push constant 1", "add", "pop that 0"}},
+		{"let s += 1 + 2;", []string{"push local 0", "push constant 1", "push constant 2", "add", "add", "pop local 0"}},
+		{"let a[1] -= x;", []string{
+			"push constant 1", "push local 1", "add", "pop pointer 1", "push pointer 1", "push that 0",
+			"push argument 0", "sub", "pop temp 0", "pop pointer 1", "push temp 0", "pop that 0",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.stmt, func(t *testing.T) {
+			src := "class Main { function void f(int x) { var int s; var Array a; " + tt.stmt + " return; } }"
+			code, err := generateWith(src, options{ext: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The statement between the function command and return
+			got := code[1:indexOf(code, "push constant 0")]
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Code differs: %v", diff)
+			}
+		})
+	}
+}
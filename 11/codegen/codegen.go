@@ -0,0 +1,736 @@
+package codegen
+
+import (
+	"compiler/ast"
+	"compiler/semantic"
+	. "compiler/symbol_table"
+	. "compiler/vmwriter"
+	"fmt"
+)
+
+// Generator writes VM code of a class from its syntax tree.
+// It also records the declarations and calls of the class for the whole-program check.
+type Generator struct {
+	w               *VMWriter
+	classTable      *SymbolTable
+	subroutineTable *SymbolTable  // It will be cleared at every subroutine declaration.
+	labelManager    *LabelManager // It will be cleared at every subroutine declaration.
+	class           *semantic.Class
+	subroutine      *semantic.Subroutine  // Subroutine being compiled
+	typeChecker     *semantic.TypeChecker // nil unless type checking is enabled
+	optimize        bool                  // Fold constant expressions and replace multiplications and divisions by constants with cheaper code
+	constants       semantic.Constants    // Constants of the other classes
+	classConstants  semantic.Constants    // Constants of the class being compiled
+	stringPool      bool                  // Keep each string literal in a hidden static instead of building it every time
+	pooledStrings   map[string]int        // Index of the hidden static of each string literal
+	stringValues    []string              // Pooled string literals in the order of the statics
+}
+
+func NewGenerator(w *VMWriter) *Generator {
+	return &Generator{w: w}
+}
+
+// Check types while generating code. Errors are reported to the type checker.
+func (g *Generator) EnableTypeCheck(tc *semantic.TypeChecker) {
+	g.typeChecker = tc
+}
+
+// Fold constant expressions and write multiplications by small constants with additions instead of calling Math.multiply.
+// The generated code differs from the official compiler.
+func (g *Generator) EnableOptimization() {
+	g.optimize = true
+}
+
+// Set the constants of the other classes in the program. Class.name refers to them.
+func (g *Generator) SetConstants(constants semantic.Constants) {
+	g.constants = semantic.Constants{}
+	for key, c := range constants {
+		g.constants[key] = c
+	}
+}
+
+// Return the declarations and calls in the generated class for semantic.Program.
+func (g *Generator) Class() *semantic.Class {
+	return g.class
+}
+
+func ints(pos ast.Pos) []int {
+	return []int{pos.Line, pos.Column}
+}
+
+// Record the source position for the code written next. Call the returned function to restore the previous one.
+func (g *Generator) at(pos ast.Pos) func() {
+	prev := g.w.SetPos(SourcePos{Line: pos.Line, Column: pos.Column})
+	return func() {
+		g.w.SetPos(prev)
+	}
+}
+
+// Return an error for the undefined variable with the similar name in the scope if any.
+func (g *Generator) undefinedError(name *ast.Ident) error {
+	candidates := []string{}
+	for _, table := range []*SymbolTable{g.subroutineTable, g.classTable} {
+		if table == nil {
+			continue
+		}
+		for _, n := range table.Names() {
+			// The dummy argument of method isn't a variable.
+			if n != "this" {
+				candidates = append(candidates, n)
+			}
+		}
+	}
+	candidates = append(candidates, g.classConstants.Names(g.classTable.Name())...)
+	msg := fmt.Sprintf("Variable %s is not defined.", name.Name)
+	return &semantic.Error{Pos: ints(name.Pos()), Message: msg, Suggestion: semantic.Suggest(name.Name, candidates)}
+}
+
+func varKindToSegment(varKind string) string {
+	switch varKind {
+	case "var":
+		return "local"
+	case "field":
+		return "this"
+	case "argument":
+		return "argument"
+	case "static":
+		return "static"
+	}
+	return ""
+}
+
+// variable is a resolved entry of the symbol tables.
+type variable struct {
+	varType string
+	segment string
+	index   int
+}
+
+func (g *Generator) resolve(varName string) (variable, bool) {
+	for _, table := range []*SymbolTable{g.subroutineTable, g.classTable} {
+		if table == nil {
+			continue
+		}
+		if kind, ok := table.KindOf(varName); ok {
+			varType, _ := table.TypeOf(varName)
+			index, _ := table.IndexOf(varName)
+			return variable{varType, varKindToSegment(kind), index}, true
+		}
+	}
+	return variable{}, false
+}
+
+func (g *Generator) Generate(c *ast.Class) error {
+	g.classTable = NewSymbolTable(c.Name.Name)
+	g.subroutineTable = nil
+	g.class = semantic.NewClass("", c.Name.Name)
+	for _, d := range c.Vars {
+		for _, name := range d.Names {
+			g.classTable.Define(name.Name, d.Type.Name, d.Kind)
+		}
+	}
+	if err := g.defineConstants(c); err != nil {
+		return err
+	}
+	g.poolStrings(c)
+	for _, s := range c.Subroutines {
+		if err := g.subroutineDec(s); err != nil {
+			return err
+		}
+	}
+	g.stringsFunctionDec()
+	return nil
+}
+
+func (g *Generator) subroutineDec(s *ast.Subroutine) error {
+	name := g.classTable.Name() + "." + s.Name.Name
+	g.subroutineTable = NewSymbolTable(name)
+	g.labelManager = NewLabelManager()
+
+	params := make([]string, 0, len(s.Params))
+	// Add a dummy 1st argument for counting up the other argument's index.
+	// Note: "this" isn't a variable but a keyword in this compiler. "this" variable below doesn't be used.
+	if s.Kind == "method" {
+		g.subroutineTable.Define("this", g.classTable.Name(), "argument")
+	}
+	for _, p := range s.Params {
+		g.subroutineTable.Define(p.Name.Name, p.Type.Name, "argument")
+		params = append(params, p.Type.Name)
+	}
+	g.subroutine = &semantic.Subroutine{Name: s.Name.Name, Kind: s.Kind, ReturnType: s.ReturnType.Name, Params: params, Disposes: disposedParams(s), Pos: ints(s.Name.Pos())}
+	g.class.Subroutines = append(g.class.Subroutines, *g.subroutine)
+
+	nLocals := 0
+	for _, d := range s.Locals {
+		for _, n := range d.Names {
+			g.subroutineTable.Define(n.Name, d.Type.Name, "var")
+			nLocals++
+		}
+	}
+	defer g.at(s.Name.Pos())()
+	g.w.Add(FunctionCode(name, nLocals))
+	g.initStrings(s)
+
+	// Allocate a new object
+	if s.Kind == "constructor" {
+		nFields := g.classTable.VarCount("field")
+		g.w.Add(PushCode("constant", nFields))
+		g.w.Add(CallCode("Memory.alloc", 1))
+		// Set the pointer of the instance to the pointer segment.
+		// This will be passed to the left side variable in let statement.
+		g.w.Add(PopCode("pointer", 0))
+	}
+
+	// Set the instance(passed as 1st parameter implicitly) to this
+	if s.Kind == "method" {
+		g.w.Add(PushCode("argument", 0))
+		g.w.Add(PopCode("pointer", 0))
+	}
+
+	return g.statements(s.Body.Stmts)
+}
+
+func (g *Generator) statements(stmts []ast.Stmt) error {
+	for _, s := range stmts {
+		var err error
+		restore := g.at(s.Pos())
+		switch s := s.(type) {
+		case *ast.LetStmt:
+			err = g.letStatement(s)
+		case *ast.IfStmt:
+			err = g.ifStatement(s)
+		case *ast.WhileStmt:
+			err = g.whileStatement(s)
+		case *ast.ForStmt:
+			err = g.forStatement(s)
+		case *ast.BranchStmt:
+			err = g.branchStatement(s)
+		case *ast.SwitchStmt:
+			err = g.switchStatement(s)
+		case *ast.DoStmt:
+			err = g.doStatement(s)
+		case *ast.ReturnStmt:
+			err = g.returnStatement(s)
+		}
+		restore()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *Generator) letStatement(s *ast.LetStmt) error {
+	v, ok := g.resolve(s.Name.Name)
+	if !ok {
+		if _, isConst := g.classConstant(s.Name.Name); isConst {
+			return &semantic.Error{Pos: ints(s.Name.Pos()), Message: fmt.Sprintf("Constant %v can't be assigned.", s.Name.Name)}
+		}
+		return g.undefinedError(s.Name)
+	}
+	if s.Op != "" {
+		return g.compoundAssignment(s, v)
+	}
+	if s.Index != nil {
+		// => <array dest>
+		if err := g.elementAddress(s, v); err != nil {
+			return err
+		}
+	}
+
+	// Push a result of the right side expression. => <result>
+	exprType, err := g.expression(s.Value)
+	if err != nil {
+		return err
+	}
+
+	if s.Index != nil {
+		// Write <result> to <array dest>.
+		g.w.Add(PopCode("temp", 0))    // Pop <result> to temp 0
+		g.w.Add(PopCode("pointer", 1)) // Pop <array dest> to pointer 1 (= that 0)
+		g.w.Add(PushCode("temp", 0))   // Push <result>
+		g.w.Add(PopCode("that", 0))    // Pop <array dest> to that 0, that is, write <result> to <array dest>
+	} else {
+		g.typeChecker.Assign(ints(s.Name.Pos()), s.Name.Name, v.varType, exprType)
+		// Write <result> to the segment of variable
+		g.w.Add(PopCode(v.segment, v.index))
+	}
+	return nil
+}
+
+// Write the compound assignment such as let x += 1; and the increment and decrement.
+// The index of an array element is evaluated only once.
+func (g *Generator) compoundAssignment(s *ast.LetStmt, v variable) error {
+	if s.Op == "++" || s.Op == "--" {
+		op := binaryOpCodes[s.Op[:1]]
+		if s.Index == nil {
+			g.typeChecker.UnaryOp(ints(s.OpPos), s.Op, v.varType)
+			g.w.Add(PushCode(v.segment, v.index))
+			g.w.Add(PushCode("constant", 1))
+			g.w.Add(op)
+			g.w.Add(PopCode(v.segment, v.index))
+			return nil
+		}
+		// that 0 is the element while nothing else is evaluated.
+		if err := g.elementAddress(s, v); err != nil {
+			return err
+		}
+		g.w.Add(PopCode("pointer", 1))
+		g.w.Add(PushCode("that", 0))
+		g.w.Add(PushCode("constant", 1))
+		g.w.Add(op)
+		g.w.Add(PopCode("that", 0))
+		return nil
+	}
+
+	if s.Index == nil {
+		// Same as let x = x op (Value); so that constants are folded and -O applies.
+		exprType, err := g.expression(&ast.BinaryExpr{X: s.Name, OpPos: s.OpPos, Op: s.Op, Y: s.Value})
+		if err != nil {
+			return err
+		}
+		g.typeChecker.Assign(ints(s.Name.Pos()), s.Name.Name, v.varType, exprType)
+		g.w.Add(PopCode(v.segment, v.index))
+		return nil
+	}
+
+	// Keep <array dest> on the stack and push the element with it. => <array dest> <element>
+	if err := g.elementAddress(s, v); err != nil {
+		return err
+	}
+	g.w.Add(PopCode("pointer", 1))
+	g.w.Add(PushCode("pointer", 1))
+	g.w.Add(PushCode("that", 0))
+	valueType, err := g.expression(s.Value)
+	if err != nil {
+		return err
+	}
+	g.w.Add(binaryOpCodes[s.Op])
+	g.typeChecker.BinaryOp(ints(s.OpPos), s.Op, semantic.UNKNOWN, valueType)
+	// Write <result> to <array dest> as let a[i] = expression; does.
+	g.w.Add(PopCode("temp", 0))
+	g.w.Add(PopCode("pointer", 1))
+	g.w.Add(PushCode("temp", 0))
+	g.w.Add(PopCode("that", 0))
+	return nil
+}
+
+// Push the address of the array element which the let statement assigns to.
+func (g *Generator) elementAddress(s *ast.LetStmt, v variable) error {
+	// Push an array index: a result of the expression in [].
+	indexType, err := g.expression(s.Index)
+	if err != nil {
+		return err
+	}
+	g.typeChecker.Index(ints(s.Name.Pos()), s.Name.Name, v.varType, indexType)
+	// Push the address of array head.
+	g.w.Add(PushCode(v.segment, v.index))
+	// Calculate target address: the array head + the index.
+	g.w.Add("add")
+	return nil
+}
+
+func (g *Generator) ifStatement(s *ast.IfStmt) error {
+	if s.ElseIf != nil {
+		return g.ifChain(s)
+	}
+	condType, err := g.expression(s.Cond)
+	if err != nil {
+		return err
+	}
+	g.typeChecker.Condition(ints(s.Cond.Pos()), "if", condType)
+
+	g.labelManager.StartIf()
+	defer g.labelManager.EndIf()
+	g.w.Add(IfGotoCode(g.labelManager.IfTrueLabel()))
+	g.w.Add(GotoCode(g.labelManager.IfFalseLabel()))
+	g.w.Add(LabelCode(g.labelManager.IfTrueLabel()))
+	if err := g.statements(s.Then.Stmts); err != nil {
+		return err
+	}
+
+	if s.Else == nil {
+		// Jumped here from the if clause when the condition didn't meet
+		g.w.Add(LabelCode(g.labelManager.IfFalseLabel()))
+		return nil
+	}
+	// Skip the else clause when the if condition met. This is needed only when else clause exists.
+	g.w.Add(GotoCode(g.labelManager.IfEndLabel()))
+	g.w.Add(LabelCode(g.labelManager.IfFalseLabel()))
+	if err := g.statements(s.Else.Stmts); err != nil {
+		return err
+	}
+	g.w.Add(LabelCode(g.labelManager.IfEndLabel()))
+	return nil
+}
+
+// Write if with else if clauses. Each clause jumps to the next one if its condition isn't met
+// and to the end of the whole chain after its statements.
+func (g *Generator) ifChain(s *ast.IfStmt) error {
+	end := ""
+	for c := s; c != nil; c = c.ElseIf {
+		condType, err := g.expression(c.Cond)
+		if err != nil {
+			return err
+		}
+		g.typeChecker.Condition(ints(c.Cond.Pos()), "if", condType)
+
+		g.labelManager.StartIf()
+		next := g.labelManager.IfFalseLabel()
+		if end == "" {
+			end = g.labelManager.IfEndLabel()
+		}
+		g.labelManager.EndIf()
+
+		g.w.Add("not")
+		g.w.Add(IfGotoCode(next))
+		if err := g.statements(c.Then.Stmts); err != nil {
+			return err
+		}
+		if c.ElseIf == nil && c.Else == nil {
+			g.w.Add(LabelCode(next))
+			break
+		}
+		g.w.Add(GotoCode(end))
+		g.w.Add(LabelCode(next))
+		if c.Else != nil {
+			if err := g.statements(c.Else.Stmts); err != nil {
+				return err
+			}
+		}
+	}
+	g.w.Add(LabelCode(end))
+	return nil
+}
+
+func (g *Generator) whileStatement(s *ast.WhileStmt) error {
+	// Set a label for the starting point of the while loop
+	g.labelManager.StartWhile()
+	defer g.labelManager.EndWhile()
+	g.w.Add(LabelCode(g.labelManager.WhileExpLabel()))
+
+	condType, err := g.expression(s.Cond)
+	if err != nil {
+		return err
+	}
+	g.typeChecker.Condition(ints(s.Cond.Pos()), "while", condType)
+
+	// If the condition isn't met, jump to the end of the while.
+	g.w.Add("not")
+	g.w.Add(IfGotoCode(g.labelManager.WhileEndLabel()))
+
+	if err := g.statements(s.Body.Stmts); err != nil {
+		return err
+	}
+
+	// Back to the head of the while.
+	g.w.Add(GotoCode(g.labelManager.WhileExpLabel()))
+	g.w.Add(LabelCode(g.labelManager.WhileEndLabel()))
+	return nil
+}
+
+func (g *Generator) forStatement(s *ast.ForStmt) error {
+	if s.Init != nil {
+		if err := g.letStatement(s.Init); err != nil {
+			return err
+		}
+	}
+
+	g.labelManager.StartFor()
+	defer g.labelManager.EndFor()
+	g.w.Add(LabelCode(g.labelManager.ForExpLabel()))
+
+	// The loop runs until break if the condition is omitted.
+	if s.Cond != nil {
+		condType, err := g.expression(s.Cond)
+		if err != nil {
+			return err
+		}
+		g.typeChecker.Condition(ints(s.Cond.Pos()), "for", condType)
+		g.w.Add("not")
+		g.w.Add(IfGotoCode(g.labelManager.ForEndLabel()))
+	}
+
+	if err := g.statements(s.Body.Stmts); err != nil {
+		return err
+	}
+
+	// continue jumps here to run the post statement.
+	g.w.Add(LabelCode(g.labelManager.ForIncLabel()))
+	if s.Post != nil {
+		if err := g.letStatement(s.Post); err != nil {
+			return err
+		}
+	}
+	g.w.Add(GotoCode(g.labelManager.ForExpLabel()))
+	g.w.Add(LabelCode(g.labelManager.ForEndLabel()))
+	return nil
+}
+
+// Jump out of the innermost loop or switch, or to the next iteration of the innermost loop.
+func (g *Generator) branchStatement(s *ast.BranchStmt) error {
+	if s.Tok == "break" {
+		if !g.labelManager.CanBreak() {
+			return &semantic.Error{Pos: ints(s.Pos()), Message: "break is not in a loop or switch."}
+		}
+		g.w.Add(GotoCode(g.labelManager.BreakLabel()))
+		return nil
+	}
+	if !g.labelManager.CanContinue() {
+		return &semantic.Error{Pos: ints(s.Pos()), Message: "continue is not in a loop."}
+	}
+	g.w.Add(GotoCode(g.labelManager.ContinueLabel()))
+	return nil
+}
+
+func (g *Generator) doStatement(s *ast.DoStmt) error {
+	if _, err := g.call(s.Call); err != nil {
+		return err
+	}
+	// Pop the return value and discard it.
+	// See the text p263 and chapter 11 slide p62
+	g.w.Add(PopCode("temp", 0))
+	return nil
+}
+
+func (g *Generator) returnStatement(s *ast.ReturnStmt) error {
+	if g.subroutine.Kind == "constructor" {
+		if k, ok := s.Value.(*ast.KeywordConst); !ok || k.Value != "this" {
+			g.class.AddProblem(ints(s.Pos()), "Constructor %v must return this", g.subroutine.Name)
+		}
+	}
+	if s.Value == nil {
+		// Push 0 for void return
+		// See p263.
+		g.w.Add(PushCode("constant", 0))
+		g.w.Add(ReturnCode())
+		g.typeChecker.Return(ints(s.Pos()), g.subroutine.Name, g.subroutine.ReturnType, semantic.VOID)
+		return nil
+	}
+	exprType, err := g.expression(s.Value)
+	if err != nil {
+		return err
+	}
+	g.typeChecker.Return(ints(s.Pos()), g.subroutine.Name, g.subroutine.ReturnType, exprType)
+	g.w.Add(ReturnCode())
+	return nil
+}
+
+var binaryOpCodes = map[string]string{
+	"+": "add",
+	"-": "sub",
+	"*": CallCode("Math.multiply", 2),
+	"/": CallCode("Math.divide", 2),
+	"=": "eq",
+	">": "gt",
+	"<": "lt",
+	"&": "and",
+	"|": "or",
+}
+
+var unaryOpCodes = map[string]string{
+	"-": "neg",
+	"~": "not",
+}
+
+// Write code to push the value of the expression. It returns the type of the expression.
+func (g *Generator) expression(e ast.Expr) (string, error) {
+	// The operator is written after the operands.
+	pos := e.Pos()
+	if b, ok := e.(*ast.BinaryExpr); ok {
+		pos = b.OpPos
+	}
+	defer g.at(pos)()
+
+	switch e.(type) {
+	case *ast.UnaryExpr, *ast.BinaryExpr:
+		// Fold the constant expression with the optimization.
+		// -32768 is always folded since 32768 can't be pushed.
+		if v, ok := g.constValue(e); ok && (g.optimize || v == minInt && isNegatedLiteral(e)) {
+			g.pushConstant(v)
+			return g.constType(e), nil
+		}
+	}
+
+	switch e := e.(type) {
+	case *ast.IntLit:
+		if e.Value > maxInt {
+			// 32768 is allowed only as -32768.
+			return "", rangeError(e)
+		}
+		// Hexadecimal and binary constants can be negative.
+		g.pushConstant(e.Value)
+		return "int", nil
+	case *ast.CharLit:
+		g.w.Add(PushCode("constant", e.Value))
+		return "char", nil
+	case *ast.StringLit:
+		if i, ok := g.pooledStrings[e.Value]; ok {
+			g.w.Add(PushCode("static", i))
+		} else {
+			g.newString(e.Value)
+		}
+		return "String", nil
+	case *ast.KeywordConst:
+		// Write a keyword constant. See the spec at p263.
+		switch e.Value {
+		case "true":
+			// true = -1 (0xFFFF)
+			g.w.Add(PushCode("constant", 0))
+			g.w.Add("not")
+			return "boolean", nil
+		case "false":
+			// false = 0 (0x0000)
+			g.w.Add(PushCode("constant", 0))
+			return "boolean", nil
+		case "this":
+			// Let the virtual segment "this" point the current instance. See p262.
+			g.w.Add(PushCode("pointer", 0))
+			if g.subroutine.Kind == "function" {
+				g.class.AddProblem(ints(e.Pos()), "this can't be used in function %v", g.subroutine.Name)
+			}
+			return g.classTable.Name(), nil
+		case "null":
+			g.w.Add(PushCode("constant", 0))
+			return semantic.NULL, nil
+		}
+	case *ast.Ident:
+		v, ok := g.resolve(e.Name)
+		if !ok {
+			if c, ok := g.classConstant(e.Name); ok {
+				g.pushConstant(c.Value)
+				return c.Type, nil
+			}
+			return "", g.undefinedError(e)
+		}
+		g.w.Add(PushCode(v.segment, v.index))
+		return v.varType, nil
+	case *ast.ConstRef:
+		c, ok := g.lookupConstant(e.Class.Name, e.Name.Name)
+		if !ok {
+			return "", g.undefinedConstantError(e)
+		}
+		g.pushConstant(c.Value)
+		return c.Type, nil
+	case *ast.IndexExpr:
+		v, ok := g.resolve(e.Name.Name)
+		if !ok {
+			return "", g.undefinedError(e.Name)
+		}
+		// Push an index
+		indexType, err := g.expression(e.Index)
+		if err != nil {
+			return "", err
+		}
+		// Calculate array head + index
+		g.w.Add(PushCode(v.segment, v.index))
+		g.w.Add("add")
+		// Push array[i]
+		g.w.Add(PopCode("pointer", 1))
+		g.w.Add(PushCode("that", 0))
+		return g.typeChecker.Index(ints(e.Pos()), e.Name.Name, v.varType, indexType), nil
+	case *ast.CallExpr:
+		return g.call(e)
+	case *ast.UnaryExpr:
+		t, err := g.expression(e.X)
+		if err != nil {
+			return "", err
+		}
+		g.w.Add(unaryOpCodes[e.Op])
+		return g.typeChecker.UnaryOp(ints(e.OpPos), e.Op, t), nil
+	case *ast.ParenExpr:
+		return g.expression(e.X)
+	case *ast.BinaryExpr:
+		if e.Op == "&&" || e.Op == "||" {
+			return g.logical(e)
+		}
+		if g.optimize {
+			if t, ok, err := g.optimizedBinary(e); ok {
+				return t, err
+			}
+		}
+		left, err := g.expression(e.X)
+		if err != nil {
+			return "", err
+		}
+		right, err := g.expression(e.Y)
+		if err != nil {
+			return "", err
+		}
+		g.w.Add(binaryOpCodes[e.Op])
+		return g.typeChecker.BinaryOp(ints(e.OpPos), e.Op, left, right), nil
+	}
+	return "", fmt.Errorf("%v: Unknown expression %T", e.Pos(), e)
+}
+
+// Write && or || of the language extensions. The right operand is evaluated only if the left one is true for &&
+// or false for ||. Otherwise the value is false or true without evaluating it.
+// Any non-zero value is true as in if-goto, so && compares the left operand with 0 instead of negating it.
+func (g *Generator) logical(e *ast.BinaryExpr) (string, error) {
+	left, err := g.expression(e.X)
+	if err != nil {
+		return "", err
+	}
+	g.labelManager.StartLogical()
+	defer g.labelManager.EndLogical()
+	if e.Op == "&&" {
+		g.w.Add(PushCode("constant", 0))
+		g.w.Add("eq")
+	}
+	g.w.Add(IfGotoCode(g.labelManager.LogicalShortLabel()))
+	right, err := g.expression(e.Y)
+	if err != nil {
+		return "", err
+	}
+	g.w.Add(GotoCode(g.labelManager.LogicalEndLabel()))
+	g.w.Add(LabelCode(g.labelManager.LogicalShortLabel()))
+	g.w.Add(PushCode("constant", 0))
+	if e.Op == "||" {
+		g.w.Add("not")
+	}
+	g.w.Add(LabelCode(g.labelManager.LogicalEndLabel()))
+	return g.typeChecker.BinaryOp(ints(e.OpPos), e.Op, left, right), nil
+}
+
+// Write code to call the subroutine. It returns the type of the return value.
+func (g *Generator) call(c *ast.CallExpr) (string, error) {
+	call := semantic.Call{Caller: g.subroutine.Name, CallerKind: g.subroutine.Kind, Name: c.Name.Name, Pos: ints(c.Pos())}
+
+	// Decide whether a method or a function/constuctor by its name. See p208.
+	nArgs := 0
+	if c.Receiver == nil {
+		// foo() is a method and called inside the object. Push the address in this segment.
+		call.Class = g.classTable.Name()
+		call.Implicit = true
+		g.w.Add(PushCode("pointer", 0))
+		nArgs++
+	} else if v, ok := g.resolve(c.Receiver.Name); ok {
+		// varName.foo() is a method. Push the address in the variable.
+		call.Class = v.varType
+		call.OnVariable = true
+		g.w.Add(PushCode(v.segment, v.index))
+		nArgs++
+	} else {
+		// className.foo() is a function/consturctor
+		call.Class = c.Receiver.Name
+	}
+
+	// Push arguments
+	argTypes := make([]string, 0, len(c.Args))
+	for i, a := range c.Args {
+		if _, ok := a.(*ast.StringLit); ok && g.stringPool {
+			call.PooledArgs = append(call.PooledArgs, i)
+		}
+		t, err := g.expression(a)
+		if err != nil {
+			return "", err
+		}
+		argTypes = append(argTypes, t)
+	}
+	nArgs += len(c.Args)
+	call.NArgs = len(c.Args)
+	g.class.Calls = append(g.class.Calls, call)
+
+	g.w.Add(CallCode(call.Class+"."+call.Name, nArgs))
+	return g.typeChecker.Call(call.Pos, call.Class, call.Name, argTypes), nil
+}
@@ -0,0 +1,198 @@
+package codegen
+
+import (
+	"compiler/ast"
+	"compiler/parser"
+	"compiler/semantic"
+	"compiler/tokenizer"
+	"compiler/vmemu"
+	"compiler/vmwriter"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// Options of the compiler in the tests
+type options struct {
+	optimize   bool
+	ext        bool
+	precedence bool
+	stringPool bool
+	constants  semantic.Constants // Constants of the other classes
+}
+
+func generate(src string) ([]string, error) {
+	return generateWith(src, options{})
+}
+
+func generateWith(src string, opts options) ([]string, error) {
+	tk, _ := tokenizer.NewTokenizer(strings.NewReader(src))
+	if opts.ext {
+		tk.EnableExtensions()
+	}
+	if err := tk.Tokenize(); err != nil {
+		return nil, err
+	}
+	p := parser.NewParser(tk, "")
+	if opts.ext {
+		p.EnableExtensions()
+	}
+	class, err := p.ParseClass()
+	if err != nil {
+		return nil, err
+	}
+	if opts.precedence {
+		ast.ApplyPrecedence(class)
+	}
+	w := &vmwriter.VMWriter{}
+	g := NewGenerator(w)
+	if opts.optimize {
+		g.EnableOptimization()
+	}
+	if opts.stringPool {
+		g.EnableStringPool()
+	}
+	g.SetConstants(opts.constants)
+	err = g.Generate(class)
+	return w.Code(), err
+}
+
+// Compile the class Main with the options and load it into the VM emulator.
+func loadMachine(t *testing.T, src string, opts options) *vmemu.Machine {
+	t.Helper()
+	code, err := generateWith(src, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := vmemu.NewMachine()
+	m.MaxSteps = 1000000
+	if err := m.Load("Main", code); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// Compile the class Main with the options and return the value of the function called with the arguments.
+func run(t *testing.T, src string, opts options, function string, args ...int) int {
+	t.Helper()
+	v, err := loadMachine(t, src, opts).Call(function, args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestGenerator_Generate(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []string
+	}{
+		{
+			name: "left associative",
+			src:  "class A { function int f(int x) { return x - 2 - 1; } }",
+			want: []string{
+				"function A.f 0",
+				"push argument 0", "push constant 2", "sub", "push constant 1", "sub",
+				"return",
+			},
+		},
+		{
+			name: "implicit method call in expression",
+			src:  "class A { method int f() { return g(1) + 1; } method int g(int x) { return x; } }",
+			want: []string{
+				"function A.f 0", "push argument 0", "pop pointer 0",
+				"push pointer 0", "push constant 1", "call A.g 2", "push constant 1", "add",
+				"return",
+				"function A.g 0", "push argument 0", "pop pointer 0",
+				"push argument 1",
+				"return",
+			},
+		},
+		{
+			name: "array and labels",
+			src: `class A { field Array a;
+				constructor A new() { while (true) { if (a[0]) { let a[1] = 2; } } return this; } }`,
+			want: []string{
+				"function A.new 0", "push constant 1", "call Memory.alloc 1", "pop pointer 0",
+				"label WHILE_EXP0", "push constant 0", "not", "not", "if-goto WHILE_END0",
+				"push constant 0", "push this 0", "add", "pop pointer 1", "push that 0",
+				"if-goto IF_TRUE0", "goto IF_FALSE0", "label IF_TRUE0",
+				"push constant 1", "push this 0", "add", "push constant 2",
+				"pop temp 0", "pop pointer 1", "push temp 0", "pop that 0",
+				"label IF_FALSE0",
+				"goto WHILE_EXP0", "label WHILE_END0",
+				"push pointer 0",
+				"return",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := generate(tt.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("VM code differs: %v", diff)
+			}
+		})
+	}
+}
+
+func TestGenerator_UndefinedVariable(t *testing.T) {
+	tests := []struct {
+		name           string
+		src            string
+		wantErr        string
+		wantSuggestion string
+	}{
+		{
+			name:    "no similar variable",
+			src:     "class A { function void f() {\n  let y = 1;\n  return; } }",
+			wantErr: "line=2, column=7: Variable y is not defined.",
+		},
+		{
+			name:           "misspelled local variable",
+			src:            "class A { field int count; method void f() { var int total;\n  let totl = count;\n  return; } }",
+			wantErr:        "line=2, column=7: Variable totl is not defined.",
+			wantSuggestion: "total",
+		},
+		{
+			name:           "misspelled field",
+			src:            "class A { field int count; method int f() {\n  return cont; } }",
+			wantErr:        "line=2, column=10: Variable cont is not defined.",
+			wantSuggestion: "count",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := generate(tt.src)
+			semErr, ok := err.(*semantic.Error)
+			if !ok {
+				t.Fatalf("got %v, want *semantic.Error", err)
+			}
+			if semErr.Error() != tt.wantErr {
+				t.Errorf("got %v, want %v", semErr, tt.wantErr)
+			}
+			if semErr.Suggestion != tt.wantSuggestion {
+				t.Errorf("got suggestion %q, want %q", semErr.Suggestion, tt.wantSuggestion)
+			}
+		})
+	}
+}
+
+func TestGenerator_DeepStatements(t *testing.T) {
+	// The label stacks of if and while grow beyond the initial size.
+	const depth = 100
+	body := strings.Repeat("if (x) { while (x) { ", depth) + "let x = x - 1;" + strings.Repeat(" } }", depth)
+	code, err := generate("class A { function void f(int x) { " + body + " return; } }")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"label WHILE_END0", "label IF_FALSE0", "push constant 0", "return"}
+	if diff := cmp.Diff(want, code[len(code)-len(want):]); diff != "" {
+		t.Errorf("VM code differs: %v", diff)
+	}
+}
@@ -0,0 +1,281 @@
+package codegen
+
+import (
+	"compiler/ast"
+	"compiler/semantic"
+	. "compiler/symbol_table"
+	. "compiler/vmwriter"
+	"fmt"
+)
+
+// Jack integers are 16-bit two's complement.
+const (
+	maxInt = 32767
+	minInt = -32768
+)
+
+// Wrap the value around to a 16-bit integer like the Hack ALU.
+func wrap(v int) int {
+	return int(int16(v))
+}
+
+func boolValue(b bool) int {
+	if b {
+		// true = -1 (0xFFFF)
+		return -1
+	}
+	return 0
+}
+
+// Return the value of the expression if it's computed at compile time:
+// integer constants, true, false, null and the constants of the language extensions combined with the operators.
+// Division by zero isn't folded so that it fails at runtime as before.
+func (g *Generator) constValue(e ast.Expr) (int, bool) {
+	switch e := e.(type) {
+	case *ast.IntLit:
+		if e.Value > maxInt {
+			return 0, false
+		}
+		return e.Value, true
+	case *ast.CharLit:
+		return e.Value, true
+	case *ast.Ident:
+		if c, ok := g.classConstant(e.Name); ok {
+			return c.Value, true
+		}
+	case *ast.ConstRef:
+		if c, ok := g.lookupConstant(e.Class.Name, e.Name.Name); ok {
+			return c.Value, true
+		}
+	case *ast.KeywordConst:
+		switch e.Value {
+		case "true":
+			return -1, true
+		case "false", "null":
+			return 0, true
+		}
+	case *ast.ParenExpr:
+		return g.constValue(e.X)
+	case *ast.UnaryExpr:
+		// -32768 is written as the negation of 32768.
+		if lit, ok := e.X.(*ast.IntLit); ok && e.Op == "-" && lit.Value == -minInt {
+			return minInt, true
+		}
+		x, ok := g.constValue(e.X)
+		if !ok {
+			return 0, false
+		}
+		switch e.Op {
+		case "-":
+			return wrap(-x), true
+		case "~":
+			return wrap(^x), true
+		}
+	case *ast.BinaryExpr:
+		x, ok := g.constValue(e.X)
+		if !ok {
+			return 0, false
+		}
+		y, ok := g.constValue(e.Y)
+		if !ok {
+			return 0, false
+		}
+		switch e.Op {
+		case "+":
+			return wrap(x + y), true
+		case "-":
+			return wrap(x - y), true
+		case "*":
+			return wrap(x * y), true
+		case "/":
+			if y == 0 {
+				return 0, false
+			}
+			return wrap(x / y), true
+		case "&":
+			return x & y, true
+		case "|":
+			return x | y, true
+		// The VM translator compares the sign of x-y, which overflows. The folded value is the same.
+		case "<":
+			return boolValue(wrap(x-y) < 0), true
+		case ">":
+			return boolValue(wrap(x-y) > 0), true
+		case "=":
+			return boolValue(x == y), true
+		case "&&":
+			if x == 0 {
+				return 0, true
+			}
+			return y, true
+		case "||":
+			if x != 0 {
+				return -1, true
+			}
+			return y, true
+		}
+	}
+	return 0, false
+}
+
+// Whether the expression is - followed by an integer literal such as -32768.
+func isNegatedLiteral(e ast.Expr) bool {
+	u, ok := e.(*ast.UnaryExpr)
+	if !ok || u.Op != "-" {
+		return false
+	}
+	_, ok = u.X.(*ast.IntLit)
+	return ok
+}
+
+// Write code to push the 16-bit value. Only 0 to 32767 can be pushed directly.
+func (g *Generator) pushConstant(v int) {
+	switch {
+	case v >= 0:
+		g.w.Add(PushCode("constant", v))
+	case v > minInt:
+		g.w.Add(PushCode("constant", -v))
+		g.w.Add("neg")
+	default:
+		// -32768 = -32767 - 1
+		g.w.Add(PushCode("constant", maxInt))
+		g.w.Add("neg")
+		g.w.Add(PushCode("constant", 1))
+		g.w.Add("sub")
+	}
+}
+
+// Return the type of the constant expression. The operands are type checked as if it's compiled.
+func (g *Generator) constType(e ast.Expr) string {
+	switch e := e.(type) {
+	case *ast.IntLit:
+		return "int"
+	case *ast.CharLit:
+		return "char"
+	case *ast.Ident:
+		c, _ := g.classConstant(e.Name)
+		return c.Type
+	case *ast.ConstRef:
+		c, _ := g.lookupConstant(e.Class.Name, e.Name.Name)
+		return c.Type
+	case *ast.KeywordConst:
+		if e.Value == "null" {
+			return semantic.NULL
+		}
+		return "boolean"
+	case *ast.ParenExpr:
+		return g.constType(e.X)
+	case *ast.UnaryExpr:
+		return g.typeChecker.UnaryOp(ints(e.OpPos), e.Op, g.constType(e.X))
+	case *ast.BinaryExpr:
+		return g.typeChecker.BinaryOp(ints(e.OpPos), e.Op, g.constType(e.X), g.constType(e.Y))
+	}
+	return ""
+}
+
+func rangeError(lit *ast.IntLit) error {
+	return &semantic.Error{Pos: ints(lit.Pos()), Message: fmt.Sprintf("Integer constant must be in [0, %v], or %v only as %v: %v", maxInt, -minInt, minInt, lit.Literal)}
+}
+
+// Return the constant of the class unless a variable has the name.
+// Variables hide the constants of the class.
+func (g *Generator) classConstant(name string) (semantic.Constant, bool) {
+	if _, ok := g.resolve(name); ok {
+		return semantic.Constant{}, false
+	}
+	return g.classConstants.Lookup(g.classTable.Name(), name)
+}
+
+func (g *Generator) lookupConstant(className string, name string) (semantic.Constant, bool) {
+	if className == g.classTable.Name() {
+		return g.classConstants.Lookup(className, name)
+	}
+	return g.constants.Lookup(className, name)
+}
+
+// Compute the constants and enums of the class in the order of the declarations.
+// A value can refer to the constants declared before it and the constants of the other classes.
+// It defines all constants which can be computed and returns the first error.
+func (g *Generator) defineConstants(c *ast.Class) error {
+	g.classConstants = semantic.Constants{}
+	var firstErr error
+	record := func(err error) {
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	for _, d := range c.Decls() {
+		switch d := d.(type) {
+		case *ast.ConstDecl:
+			if err := g.constDecl(c.Name.Name, d); err != nil {
+				record(err)
+			}
+		case *ast.EnumDecl:
+			for i, m := range d.Members {
+				if err := g.defineConstant(c.Name.Name, m, "int", i); err != nil {
+					record(err)
+				}
+			}
+		}
+	}
+	return firstErr
+}
+
+func (g *Generator) constDecl(className string, d *ast.ConstDecl) error {
+	switch d.Type.Name {
+	case "int", "char", "boolean":
+	default:
+		return &semantic.Error{Pos: ints(d.Type.Pos()), Message: fmt.Sprintf("Type of constant must be int, char or boolean, got %v.", d.Type.Name)}
+	}
+	v, ok := g.constValue(d.Value)
+	if !ok {
+		if lit, isLit := d.Value.(*ast.IntLit); isLit && lit.Value > maxInt {
+			return rangeError(lit)
+		}
+		return &semantic.Error{Pos: ints(d.Value.Pos()), Message: fmt.Sprintf("Value of constant %v must be a constant expression.", d.Name.Name)}
+	}
+	g.typeChecker.Assign(ints(d.Name.Pos()), d.Name.Name, d.Type.Name, g.constType(d.Value))
+	return g.defineConstant(className, d.Name, d.Type.Name, v)
+}
+
+func (g *Generator) defineConstant(className string, name *ast.Ident, constType string, value int) error {
+	if first, ok := g.classConstants.Lookup(className, name.Name); ok {
+		return &semantic.Error{Pos: ints(name.Pos()), Message: fmt.Sprintf("Constant %v is already declared at line %v.", name.Name, first.Pos[0])}
+	}
+	if _, ok := g.classTable.KindOf(name.Name); ok {
+		return &semantic.Error{Pos: ints(name.Pos()), Message: fmt.Sprintf("Constant %v has the same name as a class variable.", name.Name)}
+	}
+	g.classConstants.Add(semantic.Constant{Class: className, Name: name.Name, Type: constType, Value: value, Pos: ints(name.Pos())})
+	return nil
+}
+
+// Return an error for the undefined constant of the class with the similar name if any.
+func (g *Generator) undefinedConstantError(e *ast.ConstRef) error {
+	names := g.constants.Names(e.Class.Name)
+	if e.Class.Name == g.classTable.Name() {
+		names = g.classConstants.Names(e.Class.Name)
+	}
+	msg := fmt.Sprintf("Constant %v.%v is not defined.", e.Class.Name, e.Name.Name)
+	return &semantic.Error{Pos: ints(e.Name.Pos()), Message: msg, Suggestion: semantic.Suggest(e.Name.Name, names)}
+}
+
+// Constants returns the constants of the class which can be computed with the constants of the other classes.
+// It's for compiling the other classes which refer to them. Errors are left to the compilation of the class.
+func Constants(c *ast.Class, others semantic.Constants) []semantic.Constant {
+	g := NewGenerator(&VMWriter{})
+	g.SetConstants(others)
+	g.classTable = NewSymbolTable(c.Name.Name)
+	for _, d := range c.Vars {
+		for _, name := range d.Names {
+			g.classTable.Define(name.Name, d.Type.Name, d.Kind)
+		}
+	}
+	// The constants with errors are omitted.
+	g.defineConstants(c)
+	constants := []semantic.Constant{}
+	for _, name := range g.classConstants.Names(c.Name.Name) {
+		constant, _ := g.classConstants.Lookup(c.Name.Name, name)
+		constants = append(constants, constant)
+	}
+	return constants
+}
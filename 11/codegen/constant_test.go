@@ -0,0 +1,192 @@
+package codegen
+
+import (
+	"compiler/semantic"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGenerator_ConstantFolding(t *testing.T) {
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{"-32768", []string{"push constant 32767", "neg", "push constant 1", "sub"}},
+		{"-32767", []string{"push constant 32767", "neg"}},
+		{"-1", []string{"push constant 1", "neg"}},
+		{"1 + (2 * 3)", []string{"push constant 7"}},
+		{"-(3 - 5)", []string{"push constant 2"}},
+		{"32767 + 1", []string{"push constant 32767", "neg", "push constant 1", "sub"}},
+		{"200 * 200", []string{"push constant 25536", "neg"}},
+		{"(-7) / 2", []string{"push constant 3", "neg"}},
+		{"~0", []string{"push constant 1", "neg"}},
+		{"12 & 10 | 1", []string{"push constant 9"}},
+		{"(1 < 2) & (3 = 3)", []string{"push constant 1", "neg"}},
+		{"-30000 < 10000", []string{"push constant 0"}},
+		{"~true | null", []string{"push constant 0"}},
+		{"1 / 0", []string{"push constant 1", "push constant 0", "call Math.divide 2"}},
+		{"x + (2 * 3)", []string{"push argument 0", "push constant 6", "add"}},
+		{"x - -32768", []string{"push argument 0", "push constant 32767", "neg", "push constant 1", "sub", "sub"}},
+		// Literals and keywords alone are written as before.
+		{"true", []string{"push constant 0", "not"}},
+		{"32767", []string{"push constant 32767"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := generateWith("class A { function int f(int x) { return "+tt.expr+"; } }", options{optimize: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := append(append([]string{"function A.f 0"}, tt.want...), "return")
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("VM code differs: %v", diff)
+			}
+		})
+	}
+}
+
+// Constant expressions are written as they are without the optimization, as the official compiler does.
+func TestGenerator_NoConstantFolding(t *testing.T) {
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{"1 + (2 * 3)", []string{"push constant 1", "push constant 2", "push constant 3", "call Math.multiply 2", "add"}},
+		{"-(3 - 5)", []string{"push constant 3", "push constant 5", "sub", "neg"}},
+		{"~0", []string{"push constant 0", "not"}},
+		{"-1", []string{"push constant 1", "neg"}},
+		// 32768 can't be pushed, so -32768 is folded anyway.
+		{"-32768", []string{"push constant 32767", "neg", "push constant 1", "sub"}},
+		{"x - -32768", []string{"push argument 0", "push constant 32767", "neg", "push constant 1", "sub", "sub"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := generate("class A { function int f(int x) { return " + tt.expr + "; } }")
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := append(append([]string{"function A.f 0"}, tt.want...), "return")
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("VM code differs: %v", diff)
+			}
+		})
+	}
+}
+
+func TestGenerator_IntegerRange(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr string
+	}{
+		{"32768", "line=1, column=42: Integer constant must be in [0, 32767], or 32768 only as -32768: 32768"},
+		{"x + 32768", "line=1, column=46: Integer constant must be in [0, 32767], or 32768 only as -32768: 32768"},
+		{"-(32768)", "line=1, column=44: Integer constant must be in [0, 32767], or 32768 only as -32768: 32768"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := generate("class A { function int f(int x) { return " + tt.expr + "; } }")
+			if _, ok := err.(*semantic.Error); !ok || err.Error() != tt.wantErr {
+				t.Errorf("got %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGenerator_ExtensionLiterals(t *testing.T) {
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{"'A'", []string{"push constant 65"}},
+		{"'\\n' + 1", []string{"push constant 128", "push constant 1", "add"}},
+		{"0x4000 + 0b11", []string{"push constant 16384", "push constant 3", "add"}},
+		{"0xFFFF", []string{"push constant 1", "neg"}},
+		{"0x8000", []string{"push constant 32767", "neg", "push constant 1", "sub"}},
+		{"x + 0x7FFF", []string{"push argument 0", "push constant 32767", "add"}},
+		{`"a\"\n"`, []string{
+			"push constant 3", "call String.new 1",
+			"push constant 97", "call String.appendChar 2",
+			"push constant 34", "call String.appendChar 2",
+			"push constant 128", "call String.appendChar 2",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := generateWith("class A { function int f(int x) { return "+tt.expr+"; } }", options{ext: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := append(append([]string{"function A.f 0"}, tt.want...), "return")
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("VM code differs: %v", diff)
+			}
+		})
+	}
+}
+
+func TestGenerator_Constants(t *testing.T) {
+	screen := semantic.Constants{}
+	screen.Add(semantic.Constant{Class: "Screen", Name: "WIDTH", Type: "int", Value: 512})
+	tests := []struct {
+		name string
+		src  string
+		want []string // Code of A.f
+	}{
+		{"const", "const int N = 10; function int f(int x) { return N; }", []string{"push constant 10"}},
+		{"folded", "const int N = 10; const int M = -N * 2; function int f(int x) { return x + (M / 4); }", []string{"push argument 0", "push constant 5", "neg", "add"}},
+		{"char and boolean", "const char C = 'a'; const boolean B = ~false; function int f(int x) { return C | B; }", []string{"push constant 1", "neg"}},
+		{"enum", "enum Direction { UP, DOWN, LEFT, RIGHT } function int f(int x) { return RIGHT + A.DOWN; }", []string{"push constant 4"}},
+		{"other class", "const int HALF = Screen.WIDTH / 2; function int f(int x) { return Screen.WIDTH - HALF; }", []string{"push constant 256"}},
+		{"hidden by a variable", "const int x = 1; function int f(int x) { return x; }", []string{"push argument 0"}},
+		{"in switch", "enum E { P, Q } function int f(int x) { switch (x) { case Q: return 1; } return 0; }", []string{
+			"push argument 0", "pop temp 0", "push temp 0", "push constant 1", "eq", "if-goto SWITCH_CASE0_0", "goto SWITCH_END0",
+			"label SWITCH_CASE0_0", "push constant 1", "return", "label SWITCH_END0", "push constant 0",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := generateWith("class A { "+tt.src+" }", options{ext: true, optimize: true, constants: screen})
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := append(append([]string{"function A.f 0"}, tt.want...), "return")
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("VM code differs: %v", diff)
+			}
+		})
+	}
+}
+
+func TestGenerator_ConstantErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		wantErr string
+	}{
+		{"assignment", "const int N = 1; function void f() { let N = 2; return; }", "line=1, column=52: Constant N can't be assigned."},
+		{"increment", "enum E { P } function void f() { let P++; return; }", "line=1, column=48: Constant P can't be assigned."},
+		{"not constant", "function int g() { return 1; } const int N = A.g();", "line=1, column=56: Value of constant N must be a constant expression."},
+		{"later constant", "const int N = M; const int M = 1;", "line=1, column=25: Value of constant N must be a constant expression."},
+		{"type", "const String S = null;", "line=1, column=17: Type of constant must be int, char or boolean, got String."},
+		{"range", "const int N = 32768;", "line=1, column=25: Integer constant must be in [0, 32767], or 32768 only as -32768: 32768"},
+		{"duplicate", "const int N = 1;\nenum E { M, N }", "line=2, column=13: Constant N is already declared at line 1."},
+		{"class variable", "static int N; const int N = 1;", "line=1, column=35: Constant N has the same name as a class variable."},
+		{"undefined", "function int f() { return Screen.WIDE; }", "line=1, column=44: Constant Screen.WIDE is not defined."},
+		{"undefined class", "function int f() { return Foo.N; }", "line=1, column=41: Constant Foo.N is not defined."},
+	}
+	screen := semantic.Constants{}
+	screen.Add(semantic.Constant{Class: "Screen", Name: "WIDTH", Type: "int", Value: 512})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := generateWith("class A { "+tt.src+" }", options{ext: true, constants: screen})
+			if _, ok := err.(*semantic.Error); !ok || err.Error() != tt.wantErr {
+				t.Errorf("got %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+	_, err := generateWith("class A { function int f() { return Screen.WIDT; } }", options{ext: true, constants: screen})
+	if e, ok := err.(*semantic.Error); !ok || e.Suggestion != "WIDTH" {
+		t.Errorf("got %#v, want the suggestion WIDTH", err)
+	}
+}
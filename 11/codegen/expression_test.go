@@ -0,0 +1,201 @@
+package codegen
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+)
+
+// exprNode is a random Jack expression for the property-based tests.
+type exprNode struct {
+	kind  string // int, var, unary, binary, call or paren
+	value int    // Value of int. Index of a, b, c for var.
+	op    string
+	x, y  *exprNode
+}
+
+var (
+	testVars   = []string{"a", "b", "c"}
+	testBinOps = []string{"+", "-", "*", "/", "&", "|", "<", ">", "="}
+)
+
+// Source of the test class. Main.sub is called in expressions to check the order of arguments.
+const exprTestClass = "class Main { function int f(int a, int b, int c) { return %v; } function int sub(int x, int y) { return x - y; } }"
+
+func randExprNode(r *rand.Rand, depth int) *exprNode {
+	if depth <= 0 || r.Intn(4) == 0 {
+		if r.Intn(2) == 0 {
+			return &exprNode{kind: "var", value: r.Intn(len(testVars))}
+		}
+		// Small values make the comparisons and the division meaningful.
+		if r.Intn(3) == 0 {
+			return &exprNode{kind: "int", value: r.Intn(32768)}
+		}
+		return &exprNode{kind: "int", value: r.Intn(10)}
+	}
+	switch r.Intn(10) {
+	case 0, 1:
+		return &exprNode{kind: "unary", op: []string{"-", "~"}[r.Intn(2)], x: randExprNode(r, depth-1)}
+	case 2:
+		return &exprNode{kind: "paren", x: randExprNode(r, depth-1)}
+	case 3:
+		return &exprNode{kind: "call", x: randExprNode(r, depth-1), y: randExprNode(r, depth-1)}
+	}
+	return &exprNode{kind: "binary", op: testBinOps[r.Intn(len(testBinOps))], x: randExprNode(r, depth-1), y: randExprNode(r, depth-1)}
+}
+
+// Write the expression in Jack. Jack has no operator precedence and binary operators are left associative,
+// so only a binary expression on the right side and the operand of a unary operator need parentheses.
+func (n *exprNode) String() string {
+	switch n.kind {
+	case "int":
+		return fmt.Sprint(n.value)
+	case "var":
+		return testVars[n.value]
+	case "unary":
+		return n.op + n.x.term()
+	case "paren":
+		return "(" + n.x.String() + ")"
+	case "call":
+		return fmt.Sprintf("Main.sub(%v, %v)", n.x, n.y)
+	}
+	return fmt.Sprintf("%v %v %v", n.x, n.op, n.y.term())
+}
+
+func (n *exprNode) term() string {
+	if n.kind == "binary" {
+		return "(" + n.String() + ")"
+	}
+	return n.String()
+}
+
+func wrapTest(v int) int {
+	return int(int16(v))
+}
+
+func boolTest(b bool) int {
+	if b {
+		return -1
+	}
+	return 0
+}
+
+// Evaluate the expression with 16-bit integers. It returns false if it divides by zero.
+func (n *exprNode) eval(vars []int) (int, bool) {
+	switch n.kind {
+	case "int":
+		return n.value, true
+	case "var":
+		return vars[n.value], true
+	case "paren":
+		return n.x.eval(vars)
+	case "unary":
+		x, ok := n.x.eval(vars)
+		if n.op == "-" {
+			return wrapTest(-x), ok
+		}
+		return wrapTest(^x), ok
+	}
+	x, ok := n.x.eval(vars)
+	if !ok {
+		return 0, false
+	}
+	y, ok := n.y.eval(vars)
+	if !ok {
+		return 0, false
+	}
+	switch n.op {
+	case "+":
+		return wrapTest(x + y), true
+	case "-":
+		return wrapTest(x - y), true
+	case "*":
+		return wrapTest(x * y), true
+	case "/":
+		if y == 0 {
+			return 0, false
+		}
+		return wrapTest(x / y), true
+	case "&":
+		return x & y, true
+	case "|":
+		return x | y, true
+	// The VM translator compares the sign of x-y, which overflows.
+	case "<":
+		return boolTest(wrapTest(x-y) < 0), true
+	case ">":
+		return boolTest(wrapTest(x-y) > 0), true
+	case "=":
+		return boolTest(x == y), true
+	}
+	// Main.sub
+	return wrapTest(x - y), true
+}
+
+// exprCase is a random expression and the values of the variables. It implements quick.Generator.
+type exprCase struct {
+	expr *exprNode
+	vars []int
+	want int
+}
+
+func (exprCase) Generate(r *rand.Rand, size int) reflect.Value {
+	for {
+		c := exprCase{expr: randExprNode(r, 1+r.Intn(6))}
+		for range testVars {
+			c.vars = append(c.vars, r.Intn(65536)-32768)
+		}
+		if want, ok := c.expr.eval(c.vars); ok {
+			c.want = want
+			return reflect.ValueOf(c)
+		}
+	}
+}
+
+// Compile the expression as Main.f and run it with the arguments in the VM emulator.
+func runExpr(t *testing.T, expr string, args []int, optimize bool) int {
+	t.Helper()
+	return run(t, fmt.Sprintf(exprTestClass, expr), options{optimize: optimize}, "Main.f", args...)
+}
+
+func TestGenerator_ExpressionProperty(t *testing.T) {
+	for _, optimize := range []bool{false, true} {
+		property := func(c exprCase) bool {
+			if got := runExpr(t, c.expr.String(), c.vars, optimize); got != c.want {
+				t.Logf("%v with a, b, c = %v: got %v, want %v", c.expr, c.vars, got, c.want)
+				return false
+			}
+			return true
+		}
+		config := &quick.Config{MaxCount: 1000, Rand: rand.New(rand.NewSource(1))}
+		if err := quick.Check(property, config); err != nil {
+			t.Errorf("optimize=%v: %v", optimize, err)
+		}
+	}
+}
+
+func TestGenerator_DeepExpression(t *testing.T) {
+	const depth = 1000
+	tests := []struct {
+		name string
+		expr string
+		want int
+	}{
+		{"parentheses", strings.Repeat("(", depth) + "a" + strings.Repeat(")", depth), 3},
+		{"right operands", strings.Repeat("a + (", depth) + "1" + strings.Repeat(")", depth), 3001},
+		{"left operands", "a" + strings.Repeat(" - 1", depth), -997},
+		{"unary operators", strings.Repeat("-", depth+1) + "a", -3},
+		{"arguments", strings.Repeat("Main.sub(a, ", depth) + "0" + strings.Repeat(")", depth), 0},
+		{"constant", strings.Repeat("1 + (", depth) + "1" + strings.Repeat(")", depth), 1001},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := runExpr(t, tt.expr, []int{3, 0, 0}, false); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
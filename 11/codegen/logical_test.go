@@ -0,0 +1,145 @@
+package codegen
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// Compile Main.f(int x, int y) returning the expression and call it with the arguments.
+// Main.hit() counts its calls in Main.count() and returns true.
+func runLogical(t *testing.T, expr string, opts options, x int, y int) (value int, hits int) {
+	t.Helper()
+	src := `class Main {
+		static int hits;
+		function boolean hit() { let hits = hits + 1; return true; }
+		function int count() { return hits; }
+		function boolean f(int x, int y) { var Array a; let a = Memory.alloc(3); return ` + expr + `; }
+	}`
+	opts.ext = true
+	m := loadMachine(t, src, opts)
+	value, err := m.Call("Main.f", x, y)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hits, err = m.Call("Main.count"); err != nil {
+		t.Fatal(err)
+	}
+	return value, hits
+}
+
+func TestGenerator_Logical(t *testing.T) {
+	tests := []struct {
+		expr string
+		want [4]int // Values for (x, y) = (0, 0), (0, 1), (1, 0), (1, 1)
+	}{
+		{"(x = 1) && (y = 1)", [4]int{0, 0, 0, -1}},
+		{"(x = 1) || (y = 1)", [4]int{0, -1, -1, -1}},
+		{"~((x = 1) && (y = 1)) || (x = y)", [4]int{-1, -1, -1, -1}},
+		{"(x = 1) && ((y = 1) || (x = 0))", [4]int{0, 0, 0, -1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got := [4]int{}
+			for i, args := range [][2]int{{0, 0}, {0, 1}, {1, 0}, {1, 1}} {
+				got[i], _ = runLogical(t, tt.expr, options{}, args[0], args[1])
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerator_ShortCircuit(t *testing.T) {
+	tests := []struct {
+		expr     string
+		x        int
+		want     int
+		wantHits int
+	}{
+		{"(x = 1) && Main.hit()", 0, 0, 0},
+		{"(x = 1) && Main.hit()", 1, -1, 1},
+		{"(x = 1) || Main.hit()", 1, -1, 0},
+		{"(x = 1) || Main.hit()", 0, -1, 1},
+		{"(x = 1) & Main.hit()", 0, 0, 1},
+		{"Main.hit() && Main.hit() && (x = 1) && Main.hit()", 0, 0, 2},
+		{"false && Main.hit()", 0, 0, 0},
+		// a[x] isn't read when x is out of the array.
+		{"(x < 3) && (a[x] = 0) && Main.hit()", 3, 0, 0},
+		{"(x < 3) && (a[x] = 0) && Main.hit()", 2, -1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%v with x = %v", tt.expr, tt.x), func(t *testing.T) {
+			got, hits := runLogical(t, tt.expr, options{}, tt.x, 0)
+			if got != tt.want || hits != tt.wantHits {
+				t.Errorf("got %v with %v calls, want %v with %v calls", got, hits, tt.want, tt.wantHits)
+			}
+		})
+	}
+}
+
+func TestGenerator_LogicalTruthy(t *testing.T) {
+	// Any non-zero value is true as in if statements, with or without folding.
+	tests := []struct {
+		expr string
+		x    int
+		want int
+	}{
+		{"1 && true", 0, -1},
+		{"4 && true", 0, -1},
+		{"(x & 4) && true", 5, -1},
+		{"(x & 4) && true", 3, 0},
+		{"true && 1", 0, 1},
+		{"0 && true", 0, 0},
+		{"1 || false", 0, -1},
+		{"(x & 4) || false", 4, -1},
+	}
+	for _, tt := range tests {
+		for _, optimize := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%v with x = %v optimize=%v", tt.expr, tt.x, optimize), func(t *testing.T) {
+				if got, _ := runLogical(t, tt.expr, options{optimize: optimize}, tt.x, 0); got != tt.want {
+					t.Errorf("got %v, want %v", got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestGenerator_LogicalCode(t *testing.T) {
+	tests := []struct {
+		expr string
+		opts options
+		want []string
+	}{
+		{"x && y", options{ext: true}, []string{
+			"push argument 0", "push constant 0", "eq", "if-goto LOGICAL_SHORT0", "push argument 1", "goto LOGICAL_END0",
+			"label LOGICAL_SHORT0", "push constant 0", "label LOGICAL_END0",
+		}},
+		{"x || y", options{ext: true}, []string{
+			"push argument 0", "if-goto LOGICAL_SHORT0", "push argument 1", "goto LOGICAL_END0",
+			"label LOGICAL_SHORT0", "push constant 0", "not", "label LOGICAL_END0",
+		}},
+		{"true && false || true", options{ext: true, optimize: true}, []string{"push constant 1", "neg"}},
+		// x || (y && false) with the precedence
+		{"x || y && false", options{ext: true, precedence: true}, []string{
+			"push argument 0", "if-goto LOGICAL_SHORT0",
+			"push argument 1", "push constant 0", "eq", "if-goto LOGICAL_SHORT1", "push constant 0", "goto LOGICAL_END1",
+			"label LOGICAL_SHORT1", "push constant 0", "label LOGICAL_END1",
+			"goto LOGICAL_END0", "label LOGICAL_SHORT0", "push constant 0", "not", "label LOGICAL_END0",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			code, err := generateWith("class A { function boolean f(boolean x, boolean y) { return "+tt.expr+"; } }", tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := append(append([]string{"function A.f 0"}, tt.want...), "return")
+			if diff := cmp.Diff(want, code); diff != "" {
+				t.Errorf("VM code differs: %v", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package codegen
+
+import (
+	"compiler/semantic"
+	"testing"
+)
+
+func TestGenerator_Loops(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{
+			name: "for",
+			body: "for (let i = 0; i < n; let i = i + 1) { let s = s + i; }",
+			want: 45,
+		},
+		{
+			name: "continue in for runs the post statement",
+			body: "for (let i = 0; i < n; let i = i + 1) { if ((i & 1) = 1) { continue; } let s = s + i; }",
+			want: 20,
+		},
+		{
+			name: "break in for",
+			body: "for (let i = 0; i < n; let i = i + 1) { if (i = 5) { break; } let s = s + i; }",
+			want: 10,
+		},
+		{
+			name: "for without condition",
+			body: "for (; ; let i = i + 1) { if (i > 3) { break; } let s = s + 1; }",
+			want: 4,
+		},
+		{
+			name: "while",
+			body: "while (true) { let i = i + 1; if (i > 5) { break; } if ((i & 1) = 0) { continue; } let s = s + i; }",
+			want: 9,
+		},
+		{
+			name: "break of the inner loop",
+			body: "for (let i = 0; i < 4; let i = i + 1) { let j = 0; while (true) { if (j = i) { break; } let s = s + 1; let j = j + 1; } }",
+			want: 6,
+		},
+		{
+			name: "continue of the inner loop",
+			body: "while (i < 3) { let i = i + 1; for (let j = 0; j < 3; let j = j + 1) { if (j = 1) { continue; } let s = s + 1; } }",
+			want: 6,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := "class Main { function int f(int n) { var int s, i, j; " + tt.body + " return s; } }"
+			if got := run(t, src, options{ext: true}, "Main.f", 10); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerator_BranchOutsideLoop(t *testing.T) {
+	tests := []struct {
+		body string
+		want string
+	}{
+		{"break;", "line=1, column=31: break is not in a loop or switch."},
+		{"if (true) { continue; }", "line=1, column=43: continue is not in a loop."},
+		{"while (true) { } break;", "line=1, column=48: break is not in a loop or switch."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.body, func(t *testing.T) {
+			_, err := generateWith("class A { function void f() { "+tt.body+" return; } }", options{ext: true})
+			semErr, ok := err.(*semantic.Error)
+			if !ok {
+				t.Fatalf("got %v, want *semantic.Error", err)
+			}
+			if semErr.Error() != tt.want {
+				t.Errorf("got %v, want %v", semErr, tt.want)
+			}
+		})
+	}
+}
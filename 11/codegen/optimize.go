@@ -0,0 +1,119 @@
+package codegen
+
+import (
+	"compiler/ast"
+	. "compiler/vmwriter"
+	"math/bits"
+)
+
+// Multipliers with more set bits than this are left to Math.multiply since the add chain gets long.
+// Powers of two are always written as additions.
+const maxChainAdds = 3
+
+// Write code of the multiplication or division by a constant without calling the OS if it's cheaper.
+// ok is false if the expression isn't optimized and nothing is written.
+func (g *Generator) optimizedBinary(e *ast.BinaryExpr) (exprType string, ok bool, err error) {
+	switch e.Op {
+	case "*":
+		if c, ok := g.constValue(e.Y); ok {
+			return g.multiply(e, e.X, c, false)
+		}
+		if c, ok := g.constValue(e.X); ok {
+			return g.multiply(e, e.Y, c, true)
+		}
+	case "/":
+		// Only x / 1 and x / -1 are written without Math.divide. VM has no shift, so even x / 2^k would need
+		// a test of every remaining bit of x and a correction for rounding negative x toward zero.
+		if c, ok := g.constValue(e.Y); ok && (c == 1 || c == -1) {
+			t, err := g.expression(e.X)
+			if err != nil {
+				return "", true, err
+			}
+			if c == -1 {
+				g.w.Add("neg")
+			}
+			return g.typeChecker.BinaryOp(ints(e.OpPos), e.Op, t, g.constType(e.Y)), true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// Write x * c with additions. constLeft is true if the constant is the left operand.
+func (g *Generator) multiply(e *ast.BinaryExpr, x ast.Expr, c int, constLeft bool) (string, bool, error) {
+	// Multiply by the absolute value and negate the result.
+	// -32768 is multiplied as 32768 since they are the same in 16 bits.
+	n, negate := c, false
+	if c == minInt {
+		n = -minInt
+	} else if c < 0 {
+		n, negate = -c, true
+	}
+	if n > 1 && bits.OnesCount(uint(n))-1 > maxChainAdds {
+		return "", false, nil
+	}
+
+	typeOf := func(xType string) string {
+		if constLeft {
+			return g.typeChecker.BinaryOp(ints(e.OpPos), e.Op, g.constType(e.X), xType)
+		}
+		return g.typeChecker.BinaryOp(ints(e.OpPos), e.Op, xType, g.constType(e.Y))
+	}
+
+	if n == 0 {
+		var xType string
+		var err error
+		if hasCall(x) {
+			// The calls in x must be made even though the value isn't used.
+			xType, err = g.expression(x)
+			g.w.Add(PopCode("temp", 0))
+		} else {
+			// Check x without writing code.
+			w := g.w
+			g.w = &VMWriter{}
+			xType, err = g.expression(x)
+			g.w = w
+		}
+		if err != nil {
+			return "", true, err
+		}
+		g.w.Add(PushCode("constant", 0))
+		return typeOf(xType), true, nil
+	}
+
+	xType, err := g.expression(x)
+	if err != nil {
+		return "", true, err
+	}
+	if n > 1 {
+		// x is kept in temp 0 and the partial product in temp 1.
+		// Double the partial product from the highest bit of n and add x at each set bit.
+		g.w.Add(PopCode("temp", 0))
+		g.w.Add(PushCode("temp", 0))
+		for bit := bits.Len(uint(n)) - 2; bit >= 0; bit-- {
+			g.w.Add(PopCode("temp", 1))
+			g.w.Add(PushCode("temp", 1))
+			g.w.Add(PushCode("temp", 1))
+			g.w.Add("add")
+			if n&(1<<bit) != 0 {
+				g.w.Add(PushCode("temp", 0))
+				g.w.Add("add")
+			}
+		}
+	}
+	if negate {
+		g.w.Add("neg")
+	}
+	return typeOf(xType), true, nil
+}
+
+// Whether the expression has a subroutine call.
+func hasCall(e ast.Expr) bool {
+	found := false
+	ast.Inspect(e, func(n ast.Node) bool {
+		if _, ok := n.(*ast.CallExpr); ok {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
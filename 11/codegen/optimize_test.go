@@ -0,0 +1,85 @@
+package codegen
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGenerator_Optimization(t *testing.T) {
+	double := []string{"pop temp 1", "push temp 1", "push temp 1", "add"}
+	addX := []string{"push temp 0", "add"}
+	concat := func(codes ...[]string) []string {
+		s := []string{}
+		for _, c := range codes {
+			s = append(s, c...)
+		}
+		return s
+	}
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{"x * 0", []string{"push constant 0"}},
+		{"0 * x", []string{"push constant 0"}},
+		{"Main.g() * 0", []string{"call Main.g 0", "pop temp 0", "push constant 0"}},
+		{"x * 1", []string{"push argument 0"}},
+		{"1 * x", []string{"push argument 0"}},
+		{"x * -1", []string{"push argument 0", "neg"}},
+		{"x / 1", []string{"push argument 0"}},
+		{"x / -1", []string{"push argument 0", "neg"}},
+		{"x * 2", concat([]string{"push argument 0", "pop temp 0", "push temp 0"}, double)},
+		{"4 * x", concat([]string{"push argument 0", "pop temp 0", "push temp 0"}, double, double)},
+		{"x * 5", concat([]string{"push argument 0", "pop temp 0", "push temp 0"}, double, double, addX)},
+		{"x * -3", concat([]string{"push argument 0", "pop temp 0", "push temp 0"}, double, addX, []string{"neg"})},
+		// 15 needs 3 additions.
+		{"x * 15", concat([]string{"push argument 0", "pop temp 0", "push temp 0"}, double, addX, double, addX, double, addX)},
+		// 31 needs 4 additions.
+		{"x * 31", []string{"push argument 0", "push constant 31", "call Math.multiply 2"}},
+		{"x / 2", []string{"push argument 0", "push constant 2", "call Math.divide 2"}},
+		{"x * y", []string{"push argument 0", "push argument 1", "call Math.multiply 2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			src := fmt.Sprintf("class Main { function int f(int x, int y) { return %v; } function int g() { return 1; } }", tt.expr)
+			code, err := generateWith(src, options{optimize: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The body of Main.f between the function and return commands
+			got := code[1:indexOf(code, "return")]
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Code differs: %v", diff)
+			}
+		})
+	}
+}
+
+func indexOf(code []string, cmd string) int {
+	for i, c := range code {
+		if c == cmd {
+			return i
+		}
+	}
+	return len(code)
+}
+
+func TestGenerator_OptimizationValue(t *testing.T) {
+	constants := []int{0, 1, 2, 3, 5, 7, 8, 10, 15, 16, 25, 31, 50, 100, 1024, 16384, 32767, -1, -2, -10, -50, -32767, -32768}
+	values := []int{0, 1, -1, 7, -7, 181, 32767, -32768}
+	for _, c := range constants {
+		for _, constLeft := range []bool{false, true} {
+			expr := fmt.Sprintf("a * (%v)", c)
+			if constLeft {
+				expr = fmt.Sprintf("(%v) * a", c)
+			}
+			for _, v := range values {
+				got := runExpr(t, expr, []int{v, 0, 0}, true)
+				if want := wrapTest(v * c); got != want {
+					t.Errorf("%v with a = %v: got %v, want %v", expr, v, got, want)
+				}
+			}
+		}
+	}
+}
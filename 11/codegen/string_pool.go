@@ -0,0 +1,116 @@
+package codegen
+
+import (
+	"compiler/ast"
+	. "compiler/vmwriter"
+	"unicode/utf8"
+)
+
+// Name of the generated function which builds the pooled string literals of the class.
+// $ can't be in a Jack identifier, so it doesn't collide with the subroutines.
+const stringsFunction = "$strings"
+
+// Build each distinct string literal once per class and reuse it instead of allocating a new String at every evaluation.
+// The literals are kept in hidden statics following the static variables.
+func (g *Generator) EnableStringPool() {
+	g.stringPool = true
+}
+
+// Assign a hidden static to each distinct string literal in the class in the source order.
+func (g *Generator) poolStrings(c *ast.Class) {
+	g.pooledStrings = map[string]int{}
+	g.stringValues = []string{}
+	if !g.stringPool {
+		return
+	}
+	nStatics := g.classTable.VarCount("static")
+	ast.Inspect(c, func(n ast.Node) bool {
+		if s, ok := n.(*ast.StringLit); ok {
+			if _, ok := g.pooledStrings[s.Value]; !ok {
+				g.pooledStrings[s.Value] = nStatics + len(g.stringValues)
+				g.stringValues = append(g.stringValues, s.Value)
+			}
+		}
+		return true
+	})
+}
+
+// Write the call of the strings function at the entry of the subroutine if it uses the pooled literals.
+// The first pooled static is null until the function runs.
+func (g *Generator) initStrings(s *ast.Subroutine) {
+	if len(g.stringValues) == 0 || !hasString(s.Body) {
+		return
+	}
+	g.w.Add(PushCode("static", g.pooledStrings[g.stringValues[0]]))
+	g.w.Add(IfGotoCode(g.labelManager.StringsReadyLabel()))
+	g.w.Add(CallCode(g.classTable.Name()+"."+stringsFunction, 0))
+	g.w.Add(PopCode("temp", 0))
+	g.w.Add(LabelCode(g.labelManager.StringsReadyLabel()))
+}
+
+// Write the strings function which builds all pooled literals into their statics.
+func (g *Generator) stringsFunctionDec() {
+	if len(g.stringValues) == 0 {
+		return
+	}
+	g.w.Add(FunctionCode(g.classTable.Name()+"."+stringsFunction, 0))
+	for _, v := range g.stringValues {
+		g.newString(v)
+		g.w.Add(PopCode("static", g.pooledStrings[v]))
+	}
+	g.w.Add(PushCode("constant", 0))
+	g.w.Add(ReturnCode())
+}
+
+// Whether the node has a string literal.
+func hasString(node ast.Node) bool {
+	found := false
+	ast.Inspect(node, func(n ast.Node) bool {
+		if _, ok := n.(*ast.StringLit); ok {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// Return the indices of the parameters which the subroutine disposes by p.dispose() or Memory.deAlloc(p).
+func disposedParams(s *ast.Subroutine) []int {
+	params := map[string]int{}
+	for i, p := range s.Params {
+		params[p.Name.Name] = i
+	}
+	var disposed []int
+	seen := map[int]bool{}
+	ast.Inspect(s.Body, func(n ast.Node) bool {
+		c, ok := n.(*ast.CallExpr)
+		if !ok || c.Receiver == nil {
+			return true
+		}
+		var arg ast.Expr
+		switch {
+		case c.Name.Name == "dispose" && len(c.Args) == 0:
+			arg = c.Receiver
+		case c.Receiver.Name == "Memory" && c.Name.Name == "deAlloc" && len(c.Args) == 1:
+			arg = c.Args[0]
+		}
+		if id, ok := arg.(*ast.Ident); ok {
+			if i, ok := params[id.Name]; ok && !seen[i] {
+				seen[i] = true
+				disposed = append(disposed, i)
+			}
+		}
+		return true
+	})
+	return disposed
+}
+
+// Write code to build a new String of the value.
+func (g *Generator) newString(value string) {
+	g.w.Add(PushCode("constant", utf8.RuneCountInString(value)))
+	g.w.Add(CallCode("String.new", 1))
+	for _, r := range value {
+		g.w.Add(PushCode("constant", int(r)))
+		g.w.Add(CallCode("String.appendChar", 2))
+	}
+}
@@ -0,0 +1,83 @@
+package codegen
+
+import (
+	"compiler/vmemu"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGenerator_StringPool(t *testing.T) {
+	src := `class Main {
+		static int n;
+		function void f() { do Output.printString("ab"); do Output.printString("c"); return; }
+		function int g() { return 1; }
+		method void h() { do Output.printString("ab"); return; }
+	}`
+	code, err := generateWith(src, options{stringPool: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"function Main.f 0",
+		"push static 1", "if-goto STRINGS_READY", "call Main.$strings 0", "pop temp 0", "label STRINGS_READY",
+		"push static 1", "call Output.printString 1", "pop temp 0",
+		"push static 2", "call Output.printString 1", "pop temp 0",
+		"push constant 0", "return",
+		"function Main.g 0",
+		"push constant 1", "return",
+		"function Main.h 0",
+		"push static 1", "if-goto STRINGS_READY", "call Main.$strings 0", "pop temp 0", "label STRINGS_READY",
+		"push argument 0", "pop pointer 0",
+		"push static 1", "call Output.printString 1", "pop temp 0",
+		"push constant 0", "return",
+		"function Main.$strings 0",
+		"push constant 2", "call String.new 1",
+		"push constant 97", "call String.appendChar 2", "push constant 98", "call String.appendChar 2",
+		"pop static 1",
+		"push constant 1", "call String.new 1", "push constant 99", "call String.appendChar 2",
+		"pop static 2",
+		"push constant 0", "return",
+	}
+	if diff := cmp.Diff(want, code); diff != "" {
+		t.Errorf("Code differs: %v", diff)
+	}
+}
+
+// Evaluating the literals again must not allocate new strings.
+func TestGenerator_StringPoolAllocation(t *testing.T) {
+	src := `class Main {
+		function String f() { var String s; let s = "ab"; let s = "ab"; return "cd"; }
+	}`
+	for _, tt := range []struct {
+		stringPool bool
+		want       int
+	}{
+		{false, 9},
+		{true, 2},
+	} {
+		m := loadMachine(t, src, options{stringPool: tt.stringPool})
+		news := 0
+		m.Builtins["String.new"] = func(m *vmemu.Machine, args []int) (int, error) {
+			news++
+			return m.Builtins["Memory.alloc"](m, args)
+		}
+		m.Builtins["String.appendChar"] = func(m *vmemu.Machine, args []int) (int, error) {
+			return args[0], nil
+		}
+		results := map[int]bool{}
+		for i := 0; i < 3; i++ {
+			s, err := m.Call("Main.f")
+			if err != nil {
+				t.Fatal(err)
+			}
+			results[s] = true
+		}
+		if news != tt.want {
+			t.Errorf("stringPool=%v: String.new is called %v times, want %v", tt.stringPool, news, tt.want)
+		}
+		if tt.stringPool && len(results) != 1 {
+			t.Errorf("stringPool=%v: f returns different strings: %v", tt.stringPool, results)
+		}
+	}
+}
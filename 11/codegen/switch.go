@@ -0,0 +1,138 @@
+package codegen
+
+import (
+	"compiler/ast"
+	"compiler/semantic"
+	. "compiler/vmwriter"
+	"fmt"
+	"sort"
+)
+
+// Cases more than this are searched by the binary search instead of comparing one by one.
+const maxLinearCases = 3
+
+// switchCase is a case value and the index of the clause in the switch.
+type switchCase struct {
+	value  int
+	clause int
+}
+
+// Write the switch statement. The value is compared with the cases one by one if there are a few of them.
+// Otherwise the sorted cases are searched by the binary search since VM has no indirect jump for a jump table.
+// The statements of each clause jump to the end of the switch. They don't fall through.
+func (g *Generator) switchStatement(s *ast.SwitchStmt) error {
+	tagType, err := g.expression(s.Tag)
+	if err != nil {
+		return err
+	}
+	g.typeChecker.Switch(ints(s.Tag.Pos()), tagType)
+
+	cases, defaultClause, err := g.switchCases(s)
+	if err != nil {
+		return err
+	}
+
+	g.labelManager.StartSwitch()
+	defer g.labelManager.EndSwitch()
+
+	// Keep the value in temp 0 while searching. Only the comparisons run in the meantime.
+	g.w.Add(PopCode("temp", 0))
+	otherwise := g.labelManager.SwitchEndLabel()
+	if defaultClause >= 0 {
+		otherwise = g.labelManager.SwitchCaseLabel(defaultClause)
+	}
+	nLabels := 0
+	g.searchCase(cases, otherwise, &nLabels)
+
+	for i, c := range s.Cases {
+		g.w.Add(LabelCode(g.labelManager.SwitchCaseLabel(i)))
+		if err := g.statements(c.Body); err != nil {
+			return err
+		}
+		if i < len(s.Cases)-1 {
+			g.w.Add(GotoCode(g.labelManager.SwitchEndLabel()))
+		}
+	}
+	g.w.Add(LabelCode(g.labelManager.SwitchEndLabel()))
+	return nil
+}
+
+// Return the case values sorted and the index of the default clause. It's -1 if there is no default.
+// The values must be distinct constants.
+func (g *Generator) switchCases(s *ast.SwitchStmt) ([]switchCase, int, error) {
+	cases := []switchCase{}
+	defaultClause := -1
+	seen := map[int]*ast.CaseClause{}
+	for i, c := range s.Cases {
+		if c.Value == nil {
+			if defaultClause >= 0 {
+				first := s.Cases[defaultClause].Pos()
+				return nil, 0, &semantic.Error{Pos: ints(c.Pos()), Message: fmt.Sprintf("Multiple defaults in switch. The first one is at line %v.", first.Line)}
+			}
+			defaultClause = i
+			continue
+		}
+		v, ok := g.constValue(c.Value)
+		if !ok {
+			if lit, isLit := c.Value.(*ast.IntLit); isLit && lit.Value > maxInt {
+				return nil, 0, rangeError(lit)
+			}
+			return nil, 0, &semantic.Error{Pos: ints(c.Value.Pos()), Message: "Case value must be a constant."}
+		}
+		g.typeChecker.Switch(ints(c.Value.Pos()), g.constType(c.Value))
+		if first, ok := seen[v]; ok {
+			return nil, 0, &semantic.Error{Pos: ints(c.Value.Pos()), Message: fmt.Sprintf("Duplicate case %v in switch. The first one is at line %v.", v, first.Pos().Line)}
+		}
+		seen[v] = c
+		cases = append(cases, switchCase{v, i})
+	}
+	sort.Slice(cases, func(i, j int) bool { return cases[i].value < cases[j].value })
+	return cases, defaultClause, nil
+}
+
+// Jump to the clause of the case whose value is in temp 0, or to otherwise if no case matches.
+// nLabels counts the labels of the search.
+func (g *Generator) searchCase(cases []switchCase, otherwise string, nLabels *int) {
+	if len(cases) <= maxLinearCases {
+		for _, c := range cases {
+			g.w.Add(PushCode("temp", 0))
+			g.pushConstant(c.value)
+			g.w.Add("eq")
+			g.w.Add(IfGotoCode(g.labelManager.SwitchCaseLabel(c.clause)))
+		}
+		g.w.Add(GotoCode(otherwise))
+		return
+	}
+	// Search the lower half if the value is less than the middle.
+	mid := len(cases) / 2
+	lower := g.labelManager.SwitchSearchLabel(*nLabels)
+	*nLabels++
+	g.lessThan(cases[mid].value)
+	g.w.Add(IfGotoCode(lower))
+	g.searchCase(cases[mid:], otherwise, nLabels)
+	g.w.Add(LabelCode(lower))
+	g.searchCase(cases[:mid], otherwise, nLabels)
+}
+
+// Write code to push whether the value in temp 0 is less than v.
+// The VM translator computes lt from x-y, which overflows if the values are more than 32767 apart,
+// so the sign of the value is checked first. x-v doesn't overflow if x has the same sign as v.
+func (g *Generator) lessThan(v int) {
+	// temp 0 < 0
+	g.w.Add(PushCode("temp", 0))
+	g.w.Add(PushCode("constant", 0))
+	g.w.Add("lt")
+	if v == 0 {
+		return
+	}
+	g.w.Add(PushCode("temp", 0))
+	g.pushConstant(v)
+	g.w.Add("lt")
+	if v > 0 {
+		// A negative value is less than v. Otherwise x-v doesn't overflow.
+		g.w.Add("or")
+	} else {
+		// A non-negative value isn't less than v. Otherwise x-v doesn't overflow.
+		g.w.Add("and")
+	}
+}
@@ -0,0 +1,196 @@
+package codegen
+
+import (
+	"compiler/semantic"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// Compile the body of Main.f(int x) with the language extensions and return its results for the arguments.
+func runExt(t *testing.T, body string, args []int) []int {
+	t.Helper()
+	src := "class Main { function int f(int x) { var int s, i; " + body + " } }"
+	got := []int{}
+	for _, a := range args {
+		got = append(got, run(t, src, options{ext: true}, "Main.f", a))
+	}
+	return got
+}
+
+func TestGenerator_ElseIf(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []int // Results for x = 0, 1, 2, 3
+	}{
+		{
+			name: "with else",
+			body: "if (x = 1) { let s = 10; } else if (x = 2) { let s = 20; } else if (x > 2) { let s = 30; } else { let s = 40; } return s;",
+			want: []int{40, 10, 20, 30},
+		},
+		{
+			name: "without else",
+			body: "let s = 5; if (x = 1) { let s = 10; } else if (x = 2) { let s = 20; } return s;",
+			want: []int{5, 10, 20, 5},
+		},
+		{
+			name: "nested",
+			body: "if (x < 2) { if (x = 0) { let s = 1; } else if (x = 1) { let s = 2; } } else if (x = 2) { let s = 3; } return s;",
+			want: []int{1, 2, 3, 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := runExt(t, tt.body, []int{0, 1, 2, 3})
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("results differ: %v", diff)
+			}
+		})
+	}
+}
+
+func TestGenerator_Switch(t *testing.T) {
+	args := []int{-32768, -5, -1, 0, 1, 2, 3, 4, 7, 9, 10, 100, 32767}
+	// Switch with n cases 0, 1, ..., n-1 returning 10 times the value, and 32767 returning 1 as a sparse case.
+	dense := func(n int, withDefault bool) (string, []int) {
+		var b strings.Builder
+		b.WriteString("switch (x) {")
+		for i := n - 1; i >= 0; i-- {
+			fmt.Fprintf(&b, " case %v: return %v;", i, i*10)
+		}
+		b.WriteString(" case 32767: return 1;")
+		if withDefault {
+			b.WriteString(" default: return -1;")
+		}
+		b.WriteString(" } return -2;")
+		want := []int{}
+		for _, a := range args {
+			switch {
+			case a >= 0 && a < n:
+				want = append(want, a*10)
+			case a == 32767:
+				want = append(want, 1)
+			case withDefault:
+				want = append(want, -1)
+			default:
+				want = append(want, -2)
+			}
+		}
+		return b.String(), want
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want []int
+	}{
+		{
+			name: "few cases",
+			body: "switch (x) { case 1: let s = 10; case -5: let s = 20; default: let s = 30; } return s;",
+			want: []int{30, 20, 30, 30, 10, 30, 30, 30, 30, 30, 30, 30, 30},
+		},
+		{
+			name: "default first",
+			body: "switch (x - 1) { default: let s = 30; case 0: let s = 10; case 2: let s = 20; } return s;",
+			want: []int{30, 30, 30, 30, 10, 30, 20, 30, 30, 30, 30, 30, 30},
+		},
+		{
+			name: "negative and constant expressions",
+			body: "switch (x) { case -32768: return 1; case -(2 + 3): return 2; case 3 * 3: return 3; case 100: return 4; case ~0: return 5; } return 0;",
+			want: []int{1, 2, 5, 0, 0, 0, 0, 0, 0, 3, 0, 4, 0},
+		},
+		{
+			name: "break",
+			body: "let s = 1; switch (x) { case 1: if (true) { break; } let s = 2; case 2: let s = 3; } return s;",
+			want: []int{1, 1, 1, 1, 1, 3, 1, 1, 1, 1, 1, 1, 1},
+		},
+		{
+			name: "continue and break in a loop",
+			body: "for (let i = 0; i < 5; let i = i + 1) { switch (i) { case 1: continue; case 3: break; } let s = s + i; } return s;",
+			want: []int{9, 9, 9, 9, 9, 9, 9, 9, 9, 9, 9, 9, 9},
+		},
+		{
+			name: "empty",
+			body: "switch (x) { } return 7;",
+			want: []int{7, 7, 7, 7, 7, 7, 7, 7, 7, 7, 7, 7, 7},
+		},
+	}
+	for _, n := range []int{1, 3, 4, 8, 20} {
+		for _, withDefault := range []bool{false, true} {
+			body, want := dense(n, withDefault)
+			tests = append(tests, struct {
+				name string
+				body string
+				want []int
+			}{fmt.Sprintf("%v cases default=%v", n, withDefault), body, want})
+		}
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := runExt(t, tt.body, args)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("results differ: %v", diff)
+			}
+		})
+	}
+}
+
+func TestGenerator_SwitchFarCases(t *testing.T) {
+	// The differences of the values overflow 16 bits, such as -30000 - 10000.
+	body := "switch (x) { case -30000: return 1; case -29999: return 2; case 10000: return 3; case 10001: return 4; } return 0;"
+	args := []int{-30000, -29999, 10000, 10001, -32768, -1, 0, 32767}
+	want := []int{1, 2, 3, 4, 0, 0, 0, 0}
+	if diff := cmp.Diff(want, runExt(t, body, args)); diff != "" {
+		t.Errorf("results differ: %v", diff)
+	}
+}
+
+func TestGenerator_SwitchSearch(t *testing.T) {
+	// The number of comparisons grows logarithmically with the cases.
+	var b strings.Builder
+	b.WriteString("switch (x) {")
+	for i := 0; i < 64; i++ {
+		fmt.Fprintf(&b, " case %v: return %v;", i, i)
+	}
+	b.WriteString(" } return -1;")
+	m := loadMachine(t, "class Main { function int f(int x) { "+b.String()+" } }", options{ext: true})
+	for _, x := range []int{0, 31, 63} {
+		m.Steps = 0
+		if _, err := m.Call("Main.f", x); err != nil {
+			t.Fatal(err)
+		}
+		// 5 levels of at most 9 commands, 3 comparisons of 4 commands and a few more
+		if m.Steps > 70 {
+			t.Errorf("x=%v: %v steps to find the case", x, m.Steps)
+		}
+	}
+}
+
+func TestGenerator_SwitchErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"duplicate", "switch (x) {\n case 1: return 1;\n case 2: return 2;\n case 1: return 3; }", "line=4, column=7: Duplicate case 1 in switch. The first one is at line 2."},
+		{"duplicate by folding", "switch (x) {\n case 2: return 1;\n case 1 + 1: return 3; }", "line=3, column=7: Duplicate case 2 in switch. The first one is at line 2."},
+		{"not constant", "switch (x) {\n case x: return 1; }", "line=2, column=7: Case value must be a constant."},
+		{"out of range", "switch (x) {\n case 32768: return 1; }", "line=2, column=7: Integer constant must be in [0, 32767], or 32768 only as -32768: 32768"},
+		{"multiple defaults", "switch (x) {\n default: return 1;\n default: return 2; }", "line=3, column=2: Multiple defaults in switch. The first one is at line 2."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := generateWith("class A { function int f(int x) { "+tt.body+" return 0; } }", options{ext: true})
+			semErr, ok := err.(*semantic.Error)
+			if !ok {
+				t.Fatalf("got %v, want *semantic.Error", err)
+			}
+			if semErr.Error() != tt.want {
+				t.Errorf("got %v, want %v", semErr, tt.want)
+			}
+		})
+	}
+}
@@ -2,12 +2,15 @@ package compilation_engine
 
 import (
 	. "compiler/tokenizer"
+	"compiler/vmemu"
 	"compiler/vmwriter"
+	"fmt"
 	"io/ioutil"
 	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 )
 
 func setupTokenizer(content string) *Tokenizer {
@@ -51,3 +54,216 @@ func TestCompilationEngine_XML(t *testing.T) {
 		})
 	}
 }
+
+func TestCompilationEngine_WriteAnnotatedCode(t *testing.T) {
+	src := `class Main {
+    function int f(int x) {
+        var int y;
+        let y = x +
+            1;
+        return y;
+    }
+}`
+	ce := NewCompilationEngine(setupTokenizer(src), &vmwriter.VMWriter{})
+	ce.SetPath("/src/Main.jack")
+	if err := ce.Compile(); err != nil {
+		t.Fatal(err)
+	}
+	path := t.TempDir() + "/Main.vm"
+	if err := ce.WriteAnnotatedCode(path, []byte(src)); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"// Main.jack:2 function int f(int x) {",
+		"function Main.f 1",
+		"// Main.jack:4 let y = x +",
+		"push argument 0",
+		"// Main.jack:5 1;",
+		"push constant 1",
+		"// Main.jack:4 let y = x +",
+		"add",
+		"pop local 0",
+		"// Main.jack:6 return y;",
+		"push local 0",
+		"return",
+	}
+	if diff := cmp.Diff(want, strings.Split(readAsString(path), "\n")); diff != "" {
+		t.Errorf("Code differs: %v", diff)
+	}
+}
+
+func TestCompilationEngine_Precedence(t *testing.T) {
+	tests := []struct {
+		expr         string
+		wantJack     int // a = 7, b = 3
+		wantPrec     int
+		wantWarnings []string
+	}{
+		{"a + b * 2", 20, 13, []string{"1:58: '*' is applied after '+'"}},
+		{"a * b + 2", 23, 23, nil},
+		{"a - b - 1", 3, 3, nil},
+		{"a - b + 1 * 2", 10, 6, []string{"1:62: '*' is applied after '+'"}},
+		{"a + 1 < b * 3", 0, -1, []string{"1:62: '*' is applied after '<'"}},
+		{"a | b & 1", 1, 7, []string{"1:58: '&' is applied after '|'"}},
+		{"a = 7 & (b = 3)", -1, -1, nil},
+		{"-a + b * 2", -8, -1, []string{"1:59: '*' is applied after '+'"}},
+		{"(a + b) * 2", 20, 20, nil},
+		{"a + (b + 1 * 2)", 15, 12, []string{"1:63: '*' is applied after '+'"}},
+		{"Main.id(a + b * 2) + 1", 21, 14, []string{"1:66: '*' is applied after '+'"}},
+		{"1 + 2 * 3", 9, 7, []string{"1:58: '*' is applied after '+'"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			src := "class Main { function int f(int a, int b) { return " + tt.expr + "; } function int id(int x) { return x; } }"
+			for _, prec := range []bool{false, true} {
+				w := &vmwriter.VMWriter{}
+				ce := NewCompilationEngine(setupTokenizer(src), w)
+				ce.EnablePrecedenceWarning()
+				if prec {
+					ce.EnablePrecedence()
+				}
+				if err := ce.Compile(); err != nil {
+					t.Fatal(err)
+				}
+				m := vmemu.NewMachine()
+				if err := m.Load("Main", w.Code()); err != nil {
+					t.Fatal(err)
+				}
+				got, err := m.Call("Main.f", 7, 3)
+				if err != nil {
+					t.Fatal(err)
+				}
+				want := tt.wantJack
+				if prec {
+					want = tt.wantPrec
+				}
+				if got != want {
+					t.Errorf("precedence=%v: got %v, want %v", prec, got, want)
+				}
+
+				warnings := []string{}
+				for _, w := range ce.Class().Warnings {
+					msg := w.Message[:strings.Index(w.Message, " in Jack")]
+					warnings = append(warnings, fmt.Sprintf("%v:%v: %v", w.Pos[0], w.Pos[1], msg))
+				}
+				if diff := cmp.Diff(tt.wantWarnings, warnings, cmpopts.EquateEmpty()); diff != "" {
+					t.Errorf("precedence=%v: warnings differ: %v", prec, diff)
+				}
+			}
+		})
+	}
+}
+
+// Compile the source of the class and load it to the machine.
+func loadClass(m *vmemu.Machine, className string, src string, optimize bool) error {
+	w := &vmwriter.VMWriter{}
+	ce := NewCompilationEngine(setupTokenizer(src), w)
+	if optimize {
+		ce.EnableOptimization()
+	}
+	if err := ce.Compile(); err != nil {
+		return err
+	}
+	return m.Load(className, w.Code())
+}
+
+// Math.multiply by the shift-and-add algorithm of chapter 12, which adds x shifted to the set bits of y.
+const multiplySource = `class Math {
+    function int multiply(int x, int y) {
+        var int sum, shiftedX, mask, i;
+        let shiftedX = x;
+        let mask = 1;
+        while (i < 16) {
+            if (~((y & mask) = 0)) {
+                let sum = sum + shiftedX;
+            }
+            let shiftedX = shiftedX + shiftedX;
+            let mask = mask + mask;
+            let i = i + 1;
+        }
+        return sum;
+    }
+}`
+
+// Move and bounce the ball of Pong. It returns the cycles spent in Ball.setDestination and Ball.bounce,
+// which multiply and divide, and the fields of the ball.
+func runPongBall(optimize bool) (int, []int, error) {
+	m := vmemu.NewMachine()
+	noop := func(m *vmemu.Machine, args []int) (int, error) { return 0, nil }
+	m.Builtins["Screen.setColor"] = noop
+	m.Builtins["Screen.drawRectangle"] = noop
+	// Math.divide is the builtin of the emulator since the optimization doesn't change divisions.
+	// Math.multiply, which the optimization saves, runs the algorithm of chapter 12 on another machine
+	// and costs the cycles measured there.
+	math := vmemu.NewMachine()
+	if err := loadClass(math, "Math", multiplySource, false); err != nil {
+		return 0, nil, err
+	}
+	m.Builtins["Math.multiply"] = func(m *vmemu.Machine, args []int) (int, error) {
+		cycles := math.Cycles
+		v, err := math.Call("Math.multiply", args...)
+		m.Cycles += math.Cycles - cycles
+		return v, err
+	}
+	m.Builtins["Math.abs"] = func(m *vmemu.Machine, args []int) (int, error) {
+		if args[0] < 0 {
+			return -args[0], nil
+		}
+		return args[0], nil
+	}
+	if err := loadClass(m, "Ball", readAsString("../test/Pong/Ball.jack"), optimize); err != nil {
+		return 0, nil, err
+	}
+
+	ball, err := m.Call("Ball.new", 253, 222, 0, 511, 0, 229)
+	if err != nil {
+		return 0, nil, err
+	}
+	cycles := m.Cycles
+	if _, err := m.Call("Ball.setDestination", ball, 400, 0); err != nil {
+		return 0, nil, err
+	}
+	bounceCycles := m.Cycles - cycles
+	// Move the ball to the walls and bounce it in every direction like PongGame.moveBall.
+	for _, direction := range []int{1, 0, -1, 1, -1, 0} {
+		for i := 0; i < 200; i++ {
+			wall, err := m.Call("Ball.move", ball)
+			if err != nil {
+				return 0, nil, err
+			}
+			if wall > 0 {
+				break
+			}
+		}
+		cycles := m.Cycles
+		if _, err := m.Call("Ball.bounce", ball, direction); err != nil {
+			return 0, nil, err
+		}
+		bounceCycles += m.Cycles - cycles
+	}
+
+	// x, y, lengthx, lengthy, d, straightD, diagonalD, invert, positivex, positivey, the walls and wall
+	fields := []int{}
+	for i := 0; i < 15; i++ {
+		fields = append(fields, m.Peek(ball+i))
+	}
+	return bounceCycles, fields, nil
+}
+
+func TestCompilationEngine_OptimizationCycles(t *testing.T) {
+	cycles, fields, err := runPongBall(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	optCycles, optFields, err := runPongBall(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(fields, optFields); diff != "" {
+		t.Errorf("Fields of the ball differ: %v", diff)
+	}
+	t.Logf("cycles: %v -> %v (%.1f%%)", cycles, optCycles, 100*float64(optCycles-cycles)/float64(cycles))
+	if optCycles >= cycles {
+		t.Errorf("Optimization doesn't reduce cycles: %v -> %v", cycles, optCycles)
+	}
+}
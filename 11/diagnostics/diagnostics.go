@@ -0,0 +1,82 @@
+package diagnostics
+
+import (
+	"compiler/parser"
+	"compiler/semantic"
+	"compiler/tokenizer"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Severities of diagnostics
+const (
+	ERROR   = "error"
+	WARNING = "warning"
+)
+
+// Diagnostic is an error or a warning at a position of a source file.
+type Diagnostic struct {
+	Path       string `json:"file"`
+	Line       int    `json:"line"` // 0 if the position is unknown
+	Column     int    `json:"column"`
+	Severity   string `json:"severity"`
+	Message    string `json:"message"`
+	Suggestion string `json:"suggestion,omitempty"` // The name which may be meant instead of a misspelled one
+}
+
+func (d *Diagnostic) Error() string {
+	var b strings.Builder
+	if d.Path != "" {
+		b.WriteString(d.Path + ":")
+	}
+	if d.Line > 0 {
+		fmt.Fprintf(&b, "%d:%d:", d.Line, d.Column)
+	}
+	if b.Len() > 0 {
+		b.WriteString(" ")
+	}
+	b.WriteString(d.Message)
+	return b.String()
+}
+
+// Convert the error returned by the compiler to diagnostics.
+// path is used if the error doesn't have the path of the file.
+func FromError(path string, err error) []*Diagnostic {
+	var (
+		lexErrs  tokenizer.LexErrorList
+		lexErr   *tokenizer.LexError
+		errList  parser.ErrorList
+		parseErr *parser.Error
+		semErr   *semantic.Error
+		diag     *Diagnostic
+	)
+	ds := []*Diagnostic{}
+	switch {
+	case errors.As(err, &lexErrs):
+		for _, e := range lexErrs {
+			ds = append(ds, FromError(path, e)...)
+		}
+		return ds
+	case errors.As(err, &lexErr):
+		diag = &Diagnostic{"", lexErr.Pos.Line, lexErr.Pos.Column, ERROR, lexErr.Msg, ""}
+	case errors.As(err, &errList):
+		for _, e := range errList {
+			ds = append(ds, FromError(path, e)...)
+		}
+		return ds
+	case errors.As(err, &parseErr):
+		diag = &Diagnostic{parseErr.Path, parseErr.Pos.Line, parseErr.Pos.Column, ERROR, parseErr.Msg, ""}
+	case errors.As(err, &semErr):
+		diag = &Diagnostic{semErr.Path, semErr.Pos[0], semErr.Pos[1], ERROR, semErr.Message, semErr.Suggestion}
+	case errors.As(err, &diag):
+		d := *diag
+		diag = &d
+	default:
+		diag = &Diagnostic{Severity: ERROR, Message: err.Error()}
+	}
+	if diag.Path == "" {
+		diag.Path = path
+	}
+	return append(ds, diag)
+}
@@ -0,0 +1,125 @@
+package diagnostics
+
+import (
+	"bytes"
+	"compiler/ast"
+	"compiler/parser"
+	"compiler/semantic"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFromError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want []*Diagnostic
+	}{
+		{
+			name: "syntax errors",
+			err: parser.ErrorList{
+				{Path: "A.jack", Pos: ast.Pos{Line: 1, Column: 2}, Msg: "expected symbol ';'"},
+				{Pos: ast.Pos{Line: 3, Column: 4}, Msg: "expected term"},
+			},
+			want: []*Diagnostic{
+				{"A.jack", 1, 2, ERROR, "expected symbol ';'", ""},
+				{"B.jack", 3, 4, ERROR, "expected term", ""},
+			},
+		},
+		{
+			name: "semantic error with a suggestion",
+			err:  &semantic.Error{Pos: []int{5, 6}, Message: "Variable cout is not defined.", Suggestion: "count"},
+			want: []*Diagnostic{{"B.jack", 5, 6, ERROR, "Variable cout is not defined.", "count"}},
+		},
+		{
+			name: "error without a position",
+			err:  errors.New("Failed to open .jack"),
+			want: []*Diagnostic{{"B.jack", 0, 0, ERROR, "Failed to open .jack", ""}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FromError("B.jack", tt.err)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("FromError() differs: %v", diff)
+			}
+		})
+	}
+}
+
+func TestRenderer_Render(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Main.jack")
+	src := "class Main {\r\n\tfunction void main() {\r\n\t\tlet cout = 1;\r\n\t\treturn;\r\n\t}\r\n}\r\n"
+	if err := os.WriteFile(path, []byte(src), 0666); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		format string
+		color  bool
+		diag   *Diagnostic
+		want   string
+	}{
+		{
+			name:   "source line and caret",
+			format: TEXT,
+			diag:   &Diagnostic{path, 3, 7, ERROR, "Variable cout is not defined.", "count"},
+			want: path + ":3:7: error: Variable cout is not defined.\n" +
+				"\t\tlet cout = 1;\n" +
+				"\t\t    ^\n" +
+				path + ":3:7: note: did you mean 'count'?\n",
+		},
+		{
+			name:   "warning without a position",
+			format: TEXT,
+			diag:   &Diagnostic{"", 0, 0, WARNING, "No .jack files", ""},
+			want:   "warning: No .jack files\n",
+		},
+		{
+			name:   "line out of the file",
+			format: TEXT,
+			diag:   &Diagnostic{path, 100, 1, ERROR, "expected symbol '}'", ""},
+			want:   path + ":100:1: error: expected symbol '}'\n",
+		},
+		{
+			name:   "color",
+			format: TEXT,
+			color:  true,
+			diag:   &Diagnostic{path, 4, 3, ERROR, "expected term", ""},
+			want: bold + path + ":4:3:" + reset + " " + red + "error:" + reset + " " + bold + "expected term" + reset + "\n" +
+				"\t\treturn;\n" +
+				"\t\t" + green + "^" + reset + "\n",
+		},
+		{
+			name:   "json",
+			format: JSON,
+			diag:   &Diagnostic{path, 3, 7, ERROR, "Variable cout is not defined.", "count"},
+			want:   `{"file":"` + path + `","line":3,"column":7,"severity":"error","message":"Variable cout is not defined.","suggestion":"count"}` + "\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			r, err := NewRenderer(&b, tt.format, tt.color)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := r.Render(tt.diag); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, b.String()); diff != "" {
+				t.Errorf("Render() differs: %v", diff)
+			}
+		})
+	}
+}
+
+func TestNewRenderer_UnknownFormat(t *testing.T) {
+	if _, err := NewRenderer(&bytes.Buffer{}, "xml", false); err == nil {
+		t.Error("NewRenderer() succeeded with an unknown format")
+	}
+}
@@ -0,0 +1,126 @@
+package diagnostics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Output formats of Renderer
+const (
+	TEXT = "text" // Message, source line and caret for humans
+	JSON = "json" // A JSON object per line for editors
+)
+
+// ANSI escape sequences
+const (
+	bold    = "\x1b[1m"
+	red     = "\x1b[1;31m"
+	magenta = "\x1b[1;35m"
+	cyan    = "\x1b[1;36m"
+	green   = "\x1b[1;32m"
+	reset   = "\x1b[0m"
+)
+
+// Renderer writes diagnostics.
+type Renderer struct {
+	w      io.Writer
+	format string
+	color  bool
+	lines  map[string][]string // Source lines of the files read so far
+}
+
+func NewRenderer(w io.Writer, format string, color bool) (*Renderer, error) {
+	if format != TEXT && format != JSON {
+		return nil, fmt.Errorf("Unknown diagnostics format: %v", format)
+	}
+	return &Renderer{w, format, color, map[string][]string{}}, nil
+}
+
+func (r *Renderer) Render(d *Diagnostic) error {
+	if r.format == JSON {
+		buf, err := json.Marshal(d)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(r.w, "%s\n", buf)
+		return err
+	}
+
+	var b strings.Builder
+	location := ""
+	if d.Path != "" {
+		location = displayPath(d.Path) + ":"
+	}
+	if d.Line > 0 {
+		location += fmt.Sprintf("%d:%d:", d.Line, d.Column)
+	}
+	if location != "" {
+		location = r.paint(bold, location) + " "
+	}
+	severityColor := red
+	if d.Severity == WARNING {
+		severityColor = magenta
+	}
+	b.WriteString(location + r.paint(severityColor, d.Severity+":") + " " + r.paint(bold, d.Message) + "\n")
+	if line, ok := r.sourceLine(d.Path, d.Line); ok {
+		b.WriteString(line + "\n")
+		b.WriteString(caretIndent(line, d.Column) + r.paint(green, "^") + "\n")
+	}
+	if d.Suggestion != "" {
+		b.WriteString(location + r.paint(cyan, "note:") + fmt.Sprintf(" did you mean '%v'?\n", d.Suggestion))
+	}
+	_, err := io.WriteString(r.w, b.String())
+	return err
+}
+
+func (r *Renderer) paint(color string, s string) string {
+	if !r.color {
+		return s
+	}
+	return color + s + reset
+}
+
+// Return the line of the file. Line starts with 1.
+func (r *Renderer) sourceLine(path string, line int) (string, bool) {
+	lines, ok := r.lines[path]
+	if !ok {
+		src, err := os.ReadFile(path)
+		if err == nil {
+			lines = strings.Split(strings.ReplaceAll(string(src), "\r\n", "\n"), "\n")
+		}
+		r.lines[path] = lines
+	}
+	if line < 1 || line > len(lines) {
+		return "", false
+	}
+	return lines[line-1], true
+}
+
+// Spaces to put the caret under the column. Tabs are kept to align with the source line.
+func caretIndent(line string, column int) string {
+	var b strings.Builder
+	for i := 0; i < column-1 && i < len(line); i++ {
+		if line[i] == '\t' {
+			b.WriteByte('\t')
+		} else {
+			b.WriteByte(' ')
+		}
+	}
+	return b.String()
+}
+
+// Show the path relative to the working directory if the file is under it.
+func displayPath(path string) string {
+	wd, err := os.Getwd()
+	if err != nil || !filepath.IsAbs(path) {
+		return path
+	}
+	if rel, err := filepath.Rel(wd, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
@@ -0,0 +1,367 @@
+// Package format formats Jack source in the canonical style.
+// It works on the token stream of the tokenizer, so the comments are kept and the tokens never change.
+//
+// The style:
+//   - 4 spaces per indentation level. The statements of a case are indented one more level than the case.
+//   - The opening brace is at the end of the line of the declaration or statement. The closing brace is on its own line
+//     except for enums, which are written on a line.
+//   - One statement or declaration per line. An expression is joined into a line unless a comment breaks it.
+//   - A space around binary operators and after commas. No spaces inside parentheses and brackets.
+//   - One blank line between subroutines. Other blank lines are kept up to one, except after { and before }.
+package format
+
+import (
+	"bytes"
+	"compiler/parser"
+	"compiler/tokenizer"
+	"fmt"
+	"strings"
+)
+
+const indentUnit = "    "
+
+// The kinds of braces
+const (
+	classBrace      = "class"
+	subroutineBrace = "subroutine"
+	switchBrace     = "switch"
+	enumBrace       = "enum" // Written on a line
+	blockBrace      = "block"
+)
+
+// Separators between two tokens
+const (
+	none = iota
+	space
+	newline
+)
+
+type brace struct {
+	kind   string
+	inCase bool // Whether the statements of a case are being written in the switch
+}
+
+type formatter struct {
+	src     []byte
+	eol     string
+	b       strings.Builder
+	indent  int
+	braces  []brace
+	parens  int    // Depth of ( and [
+	pending string // Kind of the next brace decided by the keyword before it
+	unary   bool   // Whether the previous token is a unary operator
+	closed  string // Kind of the brace the previous token closed
+}
+
+// Source formats the Jack source. ext enables the language extensions of the tokenizer and the parser.
+// The line endings of the source are kept.
+// It returns the syntax errors without formatting if the source doesn't parse, e.g. its braces don't balance.
+func Source(src []byte, ext bool) ([]byte, error) {
+	if err := parse(src, ext); err != nil {
+		return nil, err
+	}
+	t, _ := tokenizer.NewTokenizer(bytes.NewReader(src))
+	if ext {
+		t.EnableExtensions()
+	}
+	if err := t.Tokenize(); err != nil {
+		return nil, err
+	}
+	f := &formatter{src: src, eol: "\n"}
+	if bytes.Contains(src, []byte("\r\n")) {
+		f.eol = "\r\n"
+	}
+	var prev *tokenizer.Item
+	for _, item := range t.Items() {
+		if f.closed == classBrace {
+			// The parser stops at the end of the class.
+			return nil, fmt.Errorf("%v:%v: expected end of file, found %v '%v'", item.Start.Line, item.Start.Column, item.Type(), item.String())
+		}
+		f.item(prev, item)
+		prev = item
+	}
+	f.comments(prev, t.TrailingComments(), nil, newline, false, false, f.indent)
+	if f.b.Len() > 0 {
+		f.b.WriteString(f.eol)
+	}
+	return []byte(f.b.String()), nil
+}
+
+// Parse the source to check the syntax. The formatter only follows the tokens and would indent a broken source
+// as if it were complete.
+func parse(src []byte, ext bool) error {
+	t, err := tokenizer.NewTokenizer(bytes.NewReader(src))
+	if err != nil {
+		return err
+	}
+	if ext {
+		t.EnableExtensions()
+	}
+	if err := t.Tokenize(); err != nil {
+		return err
+	}
+	p := parser.NewParser(t, "")
+	if ext {
+		p.EnableExtensions()
+	}
+	_, err = p.ParseClass()
+	return err
+}
+
+func (f *formatter) text(item *tokenizer.Item) string {
+	return string(f.src[item.Start.Offset:item.End.Offset])
+}
+
+func (f *formatter) top() *brace {
+	if len(f.braces) == 0 {
+		return nil
+	}
+	return &f.braces[len(f.braces)-1]
+}
+
+// Whether the token is the symbol s.
+func is(item *tokenizer.Item, s string) bool {
+	return item != nil && item.Type() == tokenizer.SYMBOL && item.String() == s
+}
+
+func isKeyword(item *tokenizer.Item, keywords ...string) bool {
+	if item == nil || item.Type() != tokenizer.KEYWORD {
+		return false
+	}
+	for _, k := range keywords {
+		if item.String() == k {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether the token ends an operand, after which - is the binary operator.
+func endsOperand(item *tokenizer.Item) bool {
+	switch item.Type() {
+	case tokenizer.SYMBOL:
+		return item.String() == ")" || item.String() == "]"
+	case tokenizer.KEYWORD:
+		return isKeyword(item, tokenizer.TRUE, tokenizer.FALSE, tokenizer.NULL, tokenizer.THIS)
+	}
+	return true
+}
+
+// Write the comments before the token and the token.
+func (f *formatter) item(prev *tokenizer.Item, item *tokenizer.Item) {
+	sep := f.separator(prev, item)
+
+	// Blank lines are forced between subroutines and removed after { and before }.
+	forceBlank := false
+	if len(f.braces) == 1 && prev != nil && !is(prev, "{") {
+		forceBlank = isKeyword(item, tokenizer.CONSTRUCTOR, tokenizer.FUNCTION, tokenizer.METHOD) || f.closed == subroutineBrace && !is(item, "}")
+	}
+	noBlankBefore := false
+
+	// Dedent before writing the closing brace and the case label.
+	commentIndent := f.indent
+	if top := f.top(); top != nil {
+		switch {
+		case is(item, "}") && top.kind != enumBrace:
+			if top.inCase {
+				f.indent--
+			}
+			f.indent--
+			noBlankBefore = true
+		case top.kind == switchBrace && top.inCase && isKeyword(item, tokenizer.CASE, tokenizer.DEFAULT) && f.parens == 0:
+			f.indent--
+			top.inCase = false
+			commentIndent = f.indent
+		}
+	}
+
+	f.comments(prev, item.Comments, item, sep, forceBlank, noBlankBefore, commentIndent)
+	f.b.WriteString(f.text(item))
+	f.update(item)
+	f.unary = is(item, "~") || is(item, "-") && (prev == nil || !endsOperand(prev))
+}
+
+// Return the separator between the previous token and the token.
+func (f *formatter) separator(prev *tokenizer.Item, item *tokenizer.Item) int {
+	top := f.top()
+	switch {
+	case prev == nil:
+		return newline
+	case is(item, "}"):
+		if top != nil && top.kind == enumBrace {
+			return space
+		}
+		return newline
+	case is(prev, "{"):
+		if top != nil && top.kind == enumBrace {
+			return space
+		}
+		return newline
+	case is(prev, "}") && f.closed != enumBrace:
+		if isKeyword(item, tokenizer.ELSE) {
+			return space
+		}
+		return newline
+	case is(prev, "}"):
+		return newline
+	case is(prev, ";") && f.parens == 0:
+		return newline
+	case is(prev, ":") && f.parens == 0:
+		// The end of a case label
+		return newline
+	case top != nil && top.kind == switchBrace && isKeyword(item, tokenizer.CASE, tokenizer.DEFAULT) && f.parens == 0:
+		return newline
+	}
+
+	if item.Type() == tokenizer.SYMBOL {
+		switch item.String() {
+		case ";", ",", ")", "]", ".", "[", ":", "++", "--":
+			return none
+		case "(":
+			if prev.Type() == tokenizer.IDENTIFIER {
+				return none
+			}
+		}
+	}
+	if is(prev, "(") || is(prev, "[") || is(prev, ".") || f.unary {
+		return none
+	}
+	return space
+}
+
+// Update the state after writing the token.
+func (f *formatter) update(item *tokenizer.Item) {
+	f.closed = ""
+	if item.Type() == tokenizer.KEYWORD {
+		switch item.String() {
+		case tokenizer.CLASS:
+			f.pending = classBrace
+		case tokenizer.CONSTRUCTOR, tokenizer.FUNCTION, tokenizer.METHOD:
+			f.pending = subroutineBrace
+		case tokenizer.ENUM:
+			f.pending = enumBrace
+		case tokenizer.SWITCH:
+			f.pending = switchBrace
+		}
+		return
+	}
+	if item.Type() != tokenizer.SYMBOL {
+		return
+	}
+	switch item.String() {
+	case "{":
+		kind := f.pending
+		if kind == "" {
+			kind = blockBrace
+		}
+		f.pending = ""
+		f.braces = append(f.braces, brace{kind: kind})
+		if kind != enumBrace {
+			f.indent++
+		}
+	case "}":
+		if top := f.top(); top != nil {
+			f.closed = top.kind
+			f.braces = f.braces[:len(f.braces)-1]
+		}
+	case "(", "[":
+		f.parens++
+	case ")", "]":
+		f.parens--
+	case ":":
+		if top := f.top(); top != nil && top.kind == switchBrace && f.parens == 0 {
+			top.inCase = true
+			f.indent++
+		}
+	}
+}
+
+// Write the comments between prev and next followed by the separator before next. next is nil at the end of the source.
+// A comment on the line of the previous token stays there. The others are written on their own lines.
+// forceBlank puts a blank line after prev and noBlank removes the one before next.
+func (f *formatter) comments(prev *tokenizer.Item, comments []*tokenizer.Comment, next *tokenizer.Item, sep int, forceBlank bool, noBlank bool, commentIndent int) {
+	lastLine := 0
+	if prev != nil {
+		lastLine = prev.End.Line
+	}
+	// Whether a line break was written since the previous token
+	broken := false
+	// Whether the next token must be on a new line because of a line comment
+	mustBreak := false
+	newlines := 0
+	blankLines := func(line int) int {
+		if lastLine == 0 || line-lastLine <= 1 {
+			return 0
+		}
+		return 1
+	}
+	writeNewline := func(blank int, indent int) {
+		if !broken {
+			if forceBlank {
+				blank = 1
+			}
+			if is(prev, "{") {
+				blank = 0
+			}
+		}
+		if f.b.Len() > 0 {
+			f.b.WriteString(f.eol)
+			if blank > 0 {
+				f.b.WriteString(f.eol)
+			}
+		}
+		f.b.WriteString(strings.Repeat(indentUnit, indent))
+		broken = true
+		newlines++
+	}
+
+	for _, c := range comments {
+		if prev != nil && c.Start.Line == lastLine {
+			if !is(prev, "(") && !is(prev, "[") || broken {
+				f.b.WriteString(" ")
+			}
+		} else {
+			writeNewline(blankLines(c.Start.Line), commentIndent)
+		}
+		f.b.WriteString(f.commentText(c, commentIndent))
+		lastLine = c.End.Line
+		mustBreak = strings.HasPrefix(c.Text, "//")
+	}
+
+	if next == nil {
+		return
+	}
+	switch {
+	case sep == newline:
+		blank := blankLines(next.Start.Line)
+		if noBlank {
+			blank = 0
+		}
+		writeNewline(blank, f.indent)
+	case mustBreak || len(comments) > 0 && next.Start.Line != lastLine:
+		// A comment breaks the line in the middle of a statement. Indent the rest one more level.
+		writeNewline(0, f.indent+1)
+	case len(comments) > 0:
+		if !strings.Contains(";,)].", next.String()) || next.Type() != tokenizer.SYMBOL {
+			f.b.WriteString(" ")
+		}
+	case sep == space:
+		f.b.WriteString(" ")
+	}
+}
+
+// Return the comment without trailing spaces and with the continuation lines of a block comment indented.
+// The lines starting with * are aligned under the first * of the comment and the other lines are kept.
+func (f *formatter) commentText(c *tokenizer.Comment, indent int) string {
+	lines := strings.Split(c.Text, "\n")
+	for i, line := range lines {
+		line = strings.TrimRight(line, " \t\r")
+		if i > 0 {
+			if trimmed := strings.TrimLeft(line, " \t"); strings.HasPrefix(trimmed, "*") {
+				line = strings.Repeat(indentUnit, indent) + " " + trimmed
+			}
+		}
+		lines[i] = line
+	}
+	return strings.Join(lines, f.eol)
+}
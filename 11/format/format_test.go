@@ -0,0 +1,222 @@
+package format
+
+import (
+	"bytes"
+	"compiler/tokenizer"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		ext  bool
+		want string
+	}{
+		{
+			name: "indentation and braces",
+			src:  "class Main{\nfunction void f(){\nif(x){return;}\nelse{while(x){let x=x-1;}}\nreturn;}}",
+			want: `class Main {
+    function void f() {
+        if (x) {
+            return;
+        } else {
+            while (x) {
+                let x = x - 1;
+            }
+        }
+        return;
+    }
+}
+`,
+		},
+		{
+			name: "spacing",
+			src:  "class Main { function int f(int a,int b) { let a[ b+1 ]=- a*~b; do Output.printInt( Main.g(a , b) ); return (-a)-(b); } }",
+			want: `class Main {
+    function int f(int a, int b) {
+        let a[b + 1] = -a * ~b;
+        do Output.printInt(Main.g(a, b));
+        return (-a) - (b);
+    }
+}
+`,
+		},
+		{
+			name: "blank lines",
+			src:  "class Main {\n\n  field int x;\n\n\n  field int y;\n  method int x() { return x; }\n  method int y() {\n\n    let y = 1;\n\n\n    return y;\n\n  }\n}",
+			want: `class Main {
+    field int x;
+
+    field int y;
+
+    method int x() {
+        return x;
+    }
+
+    method int y() {
+        let y = 1;
+
+        return y;
+    }
+}
+`,
+		},
+		{
+			name: "comments",
+			src: `// File comment
+class Main { // Main class
+  /**
+     * Doc comment
+     */
+  function void f() {
+    do f(/* x */ 1, 2); // trailing
+    let x = 1 + // broken
+    2;
+  // before the brace
+  }
+} // end
+// last
+`,
+			want: `// File comment
+class Main { // Main class
+    /**
+     * Doc comment
+     */
+    function void f() {
+        do f(/* x */ 1, 2); // trailing
+        let x = 1 + // broken
+            2;
+        // before the brace
+    }
+} // end
+// last
+`,
+		},
+		{
+			name: "CRLF",
+			src:  "class Main {\r\n  // c\r\n  field int x;\r\n}\r\n",
+			want: "class Main {\r\n    // c\r\n    field int x;\r\n}\r\n",
+		},
+		{
+			name: "extensions",
+			src:  "class Main { const int N=0x10; enum Color{RED,GREEN}\nfunction void f(int x) { for(let i=0;i<N;let i++){ if((x>1)&&(x<3)){break;} }\nswitch(x){case -1: let x+=1; default: return; }\nreturn; } }",
+			ext:  true,
+			want: `class Main {
+    const int N = 0x10;
+    enum Color { RED, GREEN }
+
+    function void f(int x) {
+        for (let i = 0; i < N; let i++) {
+            if ((x > 1) && (x < 3)) {
+                break;
+            }
+        }
+        switch (x) {
+            case -1:
+                let x += 1;
+            default:
+                return;
+        }
+        return;
+    }
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Source([]byte(tt.src), tt.ext)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("Source() differs: %v", diff)
+			}
+		})
+	}
+}
+
+func TestSource_Error(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		ext  bool
+	}{
+		{"lexical error", "class Main { /* unterminated", false},
+		{"missing closing brace", "class Main {\nfunction void f() { return; }\n", false},
+		{"missing closing brace of a subroutine", "class Main {\nfunction void f() { return;\n}\n", false},
+		{"extra closing brace", "class Main {\nfunction void f() { return; } }\n}\n", false},
+		{"tokens after the class", "class Main { } class A { }", false},
+		{"syntax error", "class Main { function void f() { let x = ; return; } }", false},
+		{"extension without -ext", "class Main { function void f() { for (;;) { } return; } }", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := Source([]byte(tt.src), tt.ext); err == nil {
+				t.Errorf("Source() = %q, want an error", got)
+			}
+		})
+	}
+}
+
+// Return the tokens and the comments of the source.
+func tokens(t *testing.T, src []byte) []string {
+	tk, _ := tokenizer.NewTokenizer(bytes.NewReader(src))
+	if err := tk.Tokenize(); err != nil {
+		t.Fatal(err)
+	}
+	tokens := []string{}
+	for _, item := range tk.Items() {
+		for _, c := range item.Comments {
+			tokens = append(tokens, strings.Fields(c.Text)...)
+		}
+		tokens = append(tokens, item.Type()+" "+item.String())
+	}
+	for _, c := range tk.TrailingComments() {
+		tokens = append(tokens, strings.Fields(c.Text)...)
+	}
+	return tokens
+}
+
+// Formatting the OS and the test programs keeps the tokens and the words of the comments, and formatting again doesn't change them.
+func TestSource_Programs(t *testing.T) {
+	paths := []string{}
+	for _, pattern := range []string{"../../12/*.jack", "../test/*/*.jack", "../compilation_engine/test/*.jack"} {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, matches...)
+	}
+	if len(paths) == 0 {
+		t.Fatal("No programs found")
+	}
+	for _, path := range paths {
+		t.Run(path, func(t *testing.T) {
+			src, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			formatted, err := Source(src, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tokens(t, src), tokens(t, formatted)); diff != "" {
+				t.Errorf("Tokens differ: %v", diff)
+			}
+			again, err := Source(formatted, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(string(formatted), string(again)); diff != "" {
+				t.Errorf("Formatting isn't idempotent: %v", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+// jackfmt formats Jack source in the canonical style. See package format for the style.
+//
+// Without paths, it formats the standard input. A directory is searched for .jack files recursively.
+// A file which doesn't parse, e.g. whose braces don't balance, is reported and left unchanged.
+package main
+
+import (
+	"bytes"
+	"compiler/format"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+var (
+	write = flag.Bool("w", false, "Write the result to the source file instead of the standard output")
+	diff  = flag.Bool("d", false, "Print the diff of the result instead of the result")
+	list  = flag.Bool("l", false, "List the files whose formatting differs")
+	ext   = flag.Bool("ext", false, "Tokenize the language extensions of the compiler")
+)
+
+// Format the source read from in and write the result to out by the flags. path is the name shown in the outputs.
+func processFile(path string, in io.Reader, out io.Writer, stdin bool) error {
+	src, err := io.ReadAll(in)
+	if err != nil {
+		return err
+	}
+	res, err := format.Source(src, *ext)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(src, res) {
+		if *list {
+			fmt.Fprintln(out, path)
+		}
+		if *write && !stdin {
+			info, err := os.Stat(path)
+			if err != nil {
+				return err
+			}
+			if err := os.WriteFile(path, res, info.Mode().Perm()); err != nil {
+				return err
+			}
+		}
+		if *diff {
+			fmt.Fprint(out, unifiedDiff(path+".orig", path, string(src), string(res)))
+		}
+	}
+	if !*list && !*write && !*diff {
+		_, err = out.Write(res)
+	}
+	return err
+}
+
+// Return the .jack files in the path. The path itself is returned if it's a file.
+func findSources(path string) ([]string, error) {
+	paths := []string{}
+	err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == path && !d.IsDir() || !d.IsDir() && filepath.Ext(p) == ".jack" {
+			paths = append(paths, p)
+		}
+		return nil
+	})
+	return paths, err
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %v [-w] [-d] [-l] [-ext] [.jack/dir ...]\n", filepath.Base(os.Args[0]))
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(os.Stderr, "Can't use -w with the standard input")
+			os.Exit(2)
+		}
+		if err := processFile("<standard input>", os.Stdin, os.Stdout, true); err != nil {
+			fmt.Fprintf(os.Stderr, "<standard input>: %v\n", err)
+			os.Exit(2)
+		}
+		return
+	}
+
+	failed := false
+	for _, arg := range flag.Args() {
+		paths, err := findSources(arg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed = true
+			continue
+		}
+		for _, path := range paths {
+			f, err := os.Open(path)
+			if err == nil {
+				err = processFile(path, f, os.Stdout, false)
+				f.Close()
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%v: %v\n", path, err)
+				failed = true
+			}
+		}
+	}
+	if failed {
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	b := "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n"
+	want := `--- a
++++ b
+@@ -1,6 +1,6 @@
+ 1
+ 2
+-3
++three
+ 4
+ 5
+ 6
+@@ -10,3 +10,4 @@
+ 10
+ 11
+ 12
++13
+`
+	if diff := cmp.Diff(want, unifiedDiff("a", "b", a, b)); diff != "" {
+		t.Errorf("unifiedDiff() differs: %v", diff)
+	}
+	if got := unifiedDiff("a", "b", a, a); got != "" {
+		t.Errorf("unifiedDiff() of the same texts = %q, want empty", got)
+	}
+}
+
+func TestProcessFile(t *testing.T) {
+	src := "class Main {\nfunction void f() { return; }\n}\n"
+	formatted := "class Main {\n    function void f() {\n        return;\n    }\n}\n"
+	tests := []struct {
+		name     string
+		list     bool
+		write    bool
+		src      string
+		wantOut  string
+		wantFile string
+	}{
+		{name: "print", src: src, wantOut: formatted, wantFile: src},
+		{name: "list", list: true, src: src, wantOut: "PATH\n", wantFile: src},
+		{name: "list formatted", list: true, src: formatted, wantOut: "", wantFile: formatted},
+		{name: "write", write: true, src: src, wantOut: "", wantFile: formatted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			*list, *write = tt.list, tt.write
+			defer func() { *list, *write = false, false }()
+			path := filepath.Join(t.TempDir(), "Main.jack")
+			if err := os.WriteFile(path, []byte(tt.src), 0666); err != nil {
+				t.Fatal(err)
+			}
+			var out bytes.Buffer
+			if err := processFile(path, strings.NewReader(tt.src), &out, false); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := out.String(), strings.ReplaceAll(tt.wantOut, "PATH", path); got != want {
+				t.Errorf("Output = %q, want %q", got, want)
+			}
+			file, _ := os.ReadFile(path)
+			if string(file) != tt.wantFile {
+				t.Errorf("File = %q, want %q", file, tt.wantFile)
+			}
+		})
+	}
+}
+
+func TestProcessFile_Error(t *testing.T) {
+	// The class misses its closing brace.
+	src := "class Main {\nfunction void f() { return; }\n"
+	*write = true
+	defer func() { *write = false }()
+	path := filepath.Join(t.TempDir(), "Main.jack")
+	if err := os.WriteFile(path, []byte(src), 0666); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := processFile(path, strings.NewReader(src), &out, false); err == nil {
+		t.Error("processFile() returns no error for the unbalanced braces")
+	}
+	if file, _ := os.ReadFile(path); string(file) != src {
+		t.Errorf("File = %q, want it unchanged", file)
+	}
+}
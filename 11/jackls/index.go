@@ -0,0 +1,297 @@
+package main
+
+import (
+	"compiler/ast"
+	. "compiler/symbol_table"
+	"fmt"
+	"strings"
+)
+
+// Keys of declarations. Names are unique in each of them.
+//   - Class: "Main"
+//   - Subroutine: "Main.run"
+//   - Static or field: "Main#x"
+//   - Parameter or local variable: "Main.run#i"
+//   - Constant or enum of the language extensions: "Main:N"
+func subroutineKey(className string, name string) string {
+	return className + "." + name
+}
+
+func classVarKey(className string, name string) string {
+	return className + "#" + name
+}
+
+func localKey(className string, subroutine string, name string) string {
+	return className + "." + subroutine + "#" + name
+}
+
+func constKey(className string, name string) string {
+	return className + ":" + name
+}
+
+// declaration is a class, subroutine, variable or constant declared in a class.
+type declaration struct {
+	key      string
+	name     string
+	kind     int     // Kind of DocumentSymbol
+	detail   string  // The declaration shown in the hover such as "field int x"
+	doc      string  // Doc comment
+	start    ast.Pos // Position of the first token
+	pos      ast.Pos // Position of the name
+	end      ast.Pos // Position of the last character. It's the same as pos for a name only declaration.
+	children []*declaration
+}
+
+// reference is a name in the source with the declaration it refers to.
+type reference struct {
+	key  string
+	name string
+	pos  ast.Pos
+	decl bool // Whether it's the name of the declaration
+}
+
+// scope is the variables visible in a subroutine.
+type scope struct {
+	subroutine *ast.Subroutine
+	start      ast.Pos
+	end        ast.Pos
+	table      *SymbolTable
+}
+
+// index is the declarations and references of a class.
+type index struct {
+	class      *declaration
+	decls      map[string]*declaration
+	refs       []reference
+	classTable *SymbolTable
+	scopes     []scope
+}
+
+// Return the position of the closing brace of the subroutine. It's the name if the body is missing.
+func endOf(s *ast.Subroutine) ast.Pos {
+	if s.Body != nil {
+		return s.Body.Rbrace
+	}
+	return s.Name.Pos()
+}
+
+// Return the name of the type. It's empty if a syntax error left the type out.
+func typeName(t *ast.Type) string {
+	if t == nil {
+		return ""
+	}
+	return t.Name
+}
+
+func signature(s *ast.Subroutine, className string) string {
+	params := make([]string, 0, len(s.Params))
+	for _, p := range s.Params {
+		if p.Name != nil {
+			params = append(params, typeName(p.Type)+" "+p.Name.Name)
+		}
+	}
+	return fmt.Sprintf("%v %v %v.%v(%v)", s.Kind, typeName(s.ReturnType), className, s.Name.Name, strings.Join(params, ", "))
+}
+
+// Return the index of the class. end is the position of the last character of the source.
+// The names and types which a syntax error left out are skipped.
+func newIndex(c *ast.Class, end ast.Pos) *index {
+	x := &index{decls: map[string]*declaration{}, classTable: NewSymbolTable(c.Name.Name)}
+	className := c.Name.Name
+	x.class = &declaration{key: className, name: className, kind: symbolClass, detail: "class " + className, doc: c.Doc, start: c.ClassPos, pos: c.Name.Pos(), end: end}
+	x.decls[x.class.key] = x.class
+	x.refs = append(x.refs, reference{className, className, c.Name.Pos(), true})
+
+	declare := func(parent *declaration, d *declaration) {
+		parent.children = append(parent.children, d)
+		x.decls[d.key] = d
+		x.refs = append(x.refs, reference{d.key, d.name, d.pos, true})
+	}
+	consts := map[string]bool{}
+	for _, n := range c.Decls() {
+		switch n := n.(type) {
+		case *ast.ClassVarDec:
+			kind := symbolField
+			if n.Kind == "static" {
+				kind = symbolVariable
+			}
+			for _, name := range n.Names {
+				if name == nil {
+					continue
+				}
+				x.classTable.Define(name.Name, typeName(n.Type), n.Kind)
+				e, _ := x.classTable.Lookup(name.Name)
+				declare(x.class, &declaration{key: classVarKey(className, name.Name), name: name.Name, kind: kind, detail: entryDetail(e), doc: n.Doc, start: name.Pos(), pos: name.Pos(), end: name.Pos()})
+			}
+		case *ast.ConstDecl:
+			if n.Name == nil {
+				continue
+			}
+			consts[n.Name.Name] = true
+			declare(x.class, &declaration{key: constKey(className, n.Name.Name), name: n.Name.Name, kind: symbolConstant, detail: fmt.Sprintf("const %v %v.%v", typeName(n.Type), className, n.Name.Name), doc: n.Doc, start: n.Name.Pos(), pos: n.Name.Pos(), end: n.Name.Pos()})
+		case *ast.EnumDecl:
+			if n.Name == nil {
+				continue
+			}
+			enum := &declaration{key: constKey(className, n.Name.Name), name: n.Name.Name, kind: symbolEnum, detail: "enum " + n.Name.Name, doc: n.Doc, start: n.EnumPos, pos: n.Name.Pos(), end: n.Rbrace}
+			declare(x.class, enum)
+			for i, m := range n.Members {
+				if m == nil {
+					continue
+				}
+				consts[m.Name] = true
+				declare(enum, &declaration{key: constKey(className, m.Name), name: m.Name, kind: symbolEnumMember, detail: fmt.Sprintf("const int %v.%v = %v", className, m.Name, i), doc: n.Doc, start: m.Pos(), pos: m.Pos(), end: m.Pos()})
+			}
+		}
+	}
+	kinds := map[string]int{"constructor": symbolConstructor, "function": symbolFunction, "method": symbolMethod}
+	for _, s := range c.Subroutines {
+		if s.Name == nil {
+			continue
+		}
+		declare(x.class, &declaration{key: subroutineKey(className, s.Name.Name), name: s.Name.Name, kind: kinds[s.Kind], detail: signature(s, className), doc: s.Doc, start: s.DeclPos, pos: s.Name.Pos(), end: endOf(s)})
+	}
+
+	// Names which are declared above or resolved by their parents
+	done := map[*ast.Ident]bool{}
+	var current *scope
+	// Return the key of the variable in the current scope.
+	variable := func(name string) (string, Entry, bool) {
+		if current != nil {
+			if e, ok := current.table.Lookup(name); ok {
+				return localKey(className, current.subroutine.Name.Name, name), e, true
+			}
+		}
+		if e, ok := x.classTable.Lookup(name); ok {
+			return classVarKey(className, name), e, true
+		}
+		return "", Entry{}, false
+	}
+	refer := func(key string, id *ast.Ident) {
+		x.refs = append(x.refs, reference{key, id.Name, id.Pos(), false})
+		done[id] = true
+	}
+
+	ast.Inspect(c, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Class:
+			done[n.Name] = true
+		case *ast.ClassVarDec:
+			for _, name := range n.Names {
+				done[name] = true
+			}
+		case *ast.ConstDecl:
+			done[n.Name] = true
+		case *ast.EnumDecl:
+			done[n.Name] = true
+			for _, m := range n.Members {
+				done[m] = true
+			}
+		case *ast.Subroutine:
+			if n.Name == nil {
+				return false
+			}
+			x.scopes = append(x.scopes, scope{n, n.Pos(), endOf(n), NewSymbolTable(subroutineKey(className, n.Name.Name))})
+			current = &x.scopes[len(x.scopes)-1]
+			done[n.Name] = true
+			for _, p := range n.Params {
+				if p.Name == nil {
+					continue
+				}
+				current.table.Define(p.Name.Name, typeName(p.Type), "argument")
+				e, _ := current.table.Lookup(p.Name.Name)
+				x.decls[localKey(className, n.Name.Name, p.Name.Name)] = &declaration{key: localKey(className, n.Name.Name, p.Name.Name), name: p.Name.Name, kind: symbolVariable, detail: entryDetail(e), start: p.Name.Pos(), pos: p.Name.Pos(), end: p.Name.Pos()}
+				x.refs = append(x.refs, reference{localKey(className, n.Name.Name, p.Name.Name), p.Name.Name, p.Name.Pos(), true})
+				done[p.Name] = true
+			}
+			for _, d := range n.Locals {
+				for _, name := range d.Names {
+					if name == nil {
+						continue
+					}
+					current.table.Define(name.Name, typeName(d.Type), "var")
+					e, _ := current.table.Lookup(name.Name)
+					x.decls[localKey(className, n.Name.Name, name.Name)] = &declaration{key: localKey(className, n.Name.Name, name.Name), name: name.Name, kind: symbolVariable, detail: entryDetail(e), start: name.Pos(), pos: name.Pos(), end: name.Pos()}
+					x.refs = append(x.refs, reference{localKey(className, n.Name.Name, name.Name), name.Name, name.Pos(), true})
+					done[name] = true
+				}
+			}
+		case *ast.Type:
+			if n != nil && !n.IsKeyword() {
+				x.refs = append(x.refs, reference{n.Name, n.Name, n.Pos(), false})
+			}
+		case *ast.CallExpr:
+			if n.Name == nil {
+				break
+			}
+			if n.Receiver == nil {
+				refer(subroutineKey(className, n.Name.Name), n.Name)
+			} else if key, e, ok := variable(n.Receiver.Name); ok {
+				refer(key, n.Receiver)
+				refer(subroutineKey(e.Type(), n.Name.Name), n.Name)
+			} else {
+				refer(n.Receiver.Name, n.Receiver)
+				refer(subroutineKey(n.Receiver.Name, n.Name.Name), n.Name)
+			}
+		case *ast.ConstRef:
+			if n.Class == nil || n.Name == nil {
+				break
+			}
+			refer(n.Class.Name, n.Class)
+			refer(constKey(n.Class.Name, n.Name.Name), n.Name)
+		case *ast.Ident:
+			if n == nil || done[n] {
+				break
+			}
+			// A variable in an expression, let statement or array element
+			if key, _, ok := variable(n.Name); ok {
+				refer(key, n)
+			} else if consts[n.Name] {
+				refer(constKey(className, n.Name), n)
+			}
+		}
+		return true
+	})
+	return x
+}
+
+// Return the declaration shown in the hover such as "field int x".
+func entryDetail(e Entry) string {
+	return fmt.Sprintf("%v %v %v", e.Kind(), e.Type(), e.Name())
+}
+
+// Return the reference at the position.
+func (x *index) referenceAt(pos ast.Pos) (reference, bool) {
+	for _, r := range x.refs {
+		if r.pos.Line == pos.Line && r.pos.Column <= pos.Column && pos.Column <= r.pos.Column+len(r.name) {
+			return r, true
+		}
+	}
+	return reference{}, false
+}
+
+// Return the scope of the subroutine at the position. nil if the position is out of subroutines.
+func (x *index) scopeAt(pos ast.Pos) *scope {
+	for i := range x.scopes {
+		s := &x.scopes[i]
+		if before(s.start, pos) && before(pos, s.end) {
+			return s
+		}
+	}
+	return nil
+}
+
+// Whether p is before q or the same position.
+func before(p ast.Pos, q ast.Pos) bool {
+	return p.Line < q.Line || p.Line == q.Line && p.Column <= q.Column
+}
+
+// Return the type of the variable visible at the position.
+func (x *index) typeOf(name string, pos ast.Pos) (string, bool) {
+	if s := x.scopeAt(pos); s != nil {
+		if t, ok := s.table.TypeOf(name); ok {
+			return t, true
+		}
+	}
+	return x.classTable.TypeOf(name)
+}
@@ -0,0 +1,25 @@
+// jackls is a language server of Jack. It speaks the Language Server Protocol over the standard input and output.
+//
+// It provides the diagnostics of the compiler, go to definition, hover, completion of members, document symbols
+// and find references. The .jack files in the directory of an open file are analyzed together as a program.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+)
+
+var ext = flag.Bool("ext", false, "Parse the language extensions of the compiler")
+
+func main() {
+	flag.Parse()
+	s := newServer(os.Stdin, os.Stdout, *ext)
+	if err := s.run(); err != nil {
+		log.Fatal(err)
+	}
+	// The exit code is 1 if the client exits without the shutdown request.
+	if !s.shutdown {
+		os.Exit(1)
+	}
+}
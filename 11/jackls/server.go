@@ -0,0 +1,666 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compiler/ast"
+	"compiler/compilation_engine"
+	"compiler/diagnostics"
+	"compiler/semantic"
+	"compiler/tokenizer"
+	"compiler/vmwriter"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// document is a .jack file of a program. The files in the same directory are a program as the compiler does.
+// The open documents have the text of the editor and the others are read from the disk.
+type document struct {
+	path  string
+	text  string
+	lines []string // Lines of the text without the line endings
+	open  bool
+	index *index // nil if the class can't be parsed
+	diags []*diagnostics.Diagnostic
+}
+
+type server struct {
+	in       *bufio.Reader
+	out      io.Writer
+	ext      bool
+	docs     map[string]*document // Keyed by the path
+	shutdown bool                 // Whether the shutdown request was received
+}
+
+func newServer(in io.Reader, out io.Writer, ext bool) *server {
+	return &server{in: bufio.NewReader(in), out: out, ext: ext, docs: map[string]*document{}}
+}
+
+// Serve the requests until the exit notification or the end of the input.
+func (s *server) run() error {
+	for {
+		req, err := readMessage(s.in)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			if e, ok := err.(*responseError); ok {
+				if err := s.reply(nil, nil, e); err != nil {
+					return err
+				}
+				continue
+			}
+			return err
+		}
+		if req.Method == "exit" {
+			return nil
+		}
+		if err := s.handle(req); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *server) reply(id *json.RawMessage, result interface{}, e *responseError) error {
+	if id == nil {
+		null := json.RawMessage("null")
+		id = &null
+	}
+	msg := &message{ID: id, Error: e}
+	if e == nil {
+		res, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		msg.Result = res
+	}
+	return writeMessage(s.out, msg)
+}
+
+func (s *server) notify(method string, params interface{}) error {
+	p, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return writeMessage(s.out, &message{Method: method, Params: p})
+}
+
+// Handle a request or a notification. Only the errors of writing the output are returned.
+func (s *server) handle(msg *message) error {
+	if msg.ID == nil {
+		return s.handleNotification(msg)
+	}
+	if s.shutdown {
+		return s.reply(msg.ID, nil, &responseError{invalidRequest, "The server is shut down"})
+	}
+	var result interface{}
+	var err error
+	switch msg.Method {
+	case "initialize":
+		result = s.initialize()
+	case "shutdown":
+		s.shutdown = true
+	case "textDocument/definition":
+		var params TextDocumentPositionParams
+		if err = json.Unmarshal(msg.Params, &params); err == nil {
+			result = s.definition(params)
+		}
+	case "textDocument/hover":
+		var params TextDocumentPositionParams
+		if err = json.Unmarshal(msg.Params, &params); err == nil {
+			result = s.hover(params)
+		}
+	case "textDocument/completion":
+		var params TextDocumentPositionParams
+		if err = json.Unmarshal(msg.Params, &params); err == nil {
+			result = s.completion(params)
+		}
+	case "textDocument/documentSymbol":
+		var params DocumentSymbolParams
+		if err = json.Unmarshal(msg.Params, &params); err == nil {
+			result = s.documentSymbol(params)
+		}
+	case "textDocument/references":
+		var params ReferenceParams
+		if err = json.Unmarshal(msg.Params, &params); err == nil {
+			result = s.references(params)
+		}
+	default:
+		return s.reply(msg.ID, nil, &responseError{methodNotFound, fmt.Sprintf("Method %v is not supported", msg.Method)})
+	}
+	if err != nil {
+		return s.reply(msg.ID, nil, &responseError{invalidParams, err.Error()})
+	}
+	return s.reply(msg.ID, result, nil)
+}
+
+func (s *server) handleNotification(msg *message) error {
+	switch msg.Method {
+	case "textDocument/didOpen":
+		var params DidOpenTextDocumentParams
+		if json.Unmarshal(msg.Params, &params) != nil {
+			return nil
+		}
+		return s.didOpen(params)
+	case "textDocument/didChange":
+		var params DidChangeTextDocumentParams
+		if json.Unmarshal(msg.Params, &params) != nil {
+			return nil
+		}
+		return s.didChange(params)
+	case "textDocument/didClose":
+		var params DidCloseTextDocumentParams
+		if json.Unmarshal(msg.Params, &params) != nil {
+			return nil
+		}
+		return s.didClose(params)
+	}
+	// The other notifications such as initialized are ignored.
+	return nil
+}
+
+func (s *server) initialize() interface{} {
+	return map[string]interface{}{
+		"capabilities": map[string]interface{}{
+			"textDocumentSync":       1, // The full text is sent on change.
+			"definitionProvider":     true,
+			"hoverProvider":          true,
+			"referencesProvider":     true,
+			"documentSymbolProvider": true,
+			"completionProvider":     map[string]interface{}{"triggerCharacters": []string{"."}},
+		},
+		"serverInfo": map[string]string{"name": "jackls"},
+	}
+}
+
+// Documents
+
+func uriToPath(uri string) (string, bool) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return "", false
+	}
+	return filepath.FromSlash(u.Path), true
+}
+
+func pathToURI(path string) string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
+
+func newDocument(path string, text string) *document {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+	return &document{path: path, text: text, lines: lines}
+}
+
+// Return the document of the URI. It's nil if the document isn't known.
+func (s *server) document(uri string) *document {
+	path, ok := uriToPath(uri)
+	if !ok {
+		return nil
+	}
+	return s.docs[path]
+}
+
+// Return the documents of the directory ordered by the path.
+func (s *server) program(dir string) []*document {
+	docs := []*document{}
+	for path, d := range s.docs {
+		if filepath.Dir(path) == dir {
+			docs = append(docs, d)
+		}
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i].path < docs[j].path })
+	return docs
+}
+
+// Load the .jack files in the directory which aren't loaded yet.
+func (s *server) loadProgram(dir string) {
+	paths, _ := filepath.Glob(filepath.Join(dir, "*.jack"))
+	for _, path := range paths {
+		if _, ok := s.docs[path]; ok {
+			continue
+		}
+		if src, err := os.ReadFile(path); err == nil {
+			s.docs[path] = newDocument(path, string(src))
+		}
+	}
+}
+
+func (s *server) didOpen(params DidOpenTextDocumentParams) error {
+	path, ok := uriToPath(params.TextDocument.URI)
+	if !ok {
+		return nil
+	}
+	s.loadProgram(filepath.Dir(path))
+	d := newDocument(path, params.TextDocument.Text)
+	d.open = true
+	s.docs[path] = d
+	return s.update(filepath.Dir(path))
+}
+
+func (s *server) didChange(params DidChangeTextDocumentParams) error {
+	d := s.document(params.TextDocument.URI)
+	if d == nil || len(params.ContentChanges) == 0 {
+		return nil
+	}
+	changed := newDocument(d.path, params.ContentChanges[len(params.ContentChanges)-1].Text)
+	changed.open = true
+	s.docs[d.path] = changed
+	return s.update(filepath.Dir(d.path))
+}
+
+// The closed document goes back to the file on the disk. Its diagnostics are cleared.
+func (s *server) didClose(params DidCloseTextDocumentParams) error {
+	d := s.document(params.TextDocument.URI)
+	if d == nil {
+		return nil
+	}
+	delete(s.docs, d.path)
+	if src, err := os.ReadFile(d.path); err == nil {
+		s.docs[d.path] = newDocument(d.path, string(src))
+	}
+	if err := s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{pathToURI(d.path), []Diagnostic{}}); err != nil {
+		return err
+	}
+	return s.update(filepath.Dir(d.path))
+}
+
+// Analyze the program in the directory and publish the diagnostics of the open documents.
+func (s *server) update(dir string) error {
+	docs := s.program(dir)
+	analyze(docs, s.ext)
+	for _, d := range docs {
+		if !d.open {
+			continue
+		}
+		params := PublishDiagnosticsParams{pathToURI(d.path), []Diagnostic{}}
+		for _, diag := range d.diags {
+			params.Diagnostics = append(params.Diagnostics, d.diagnostic(diag))
+		}
+		if err := s.notify("textDocument/publishDiagnostics", params); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Analysis
+
+func newCompilationEngine(d *document, ext bool, constants semantic.Constants) (*compilation_engine.CompilationEngine, error) {
+	t, err := tokenizer.NewTokenizer(bytes.NewReader([]byte(d.text)))
+	if err != nil {
+		return nil, err
+	}
+	if ext {
+		t.EnableExtensions()
+	}
+	if err := t.Tokenize(); err != nil {
+		return nil, err
+	}
+	w, _ := vmwriter.NewVMWriter()
+	ce := compilation_engine.NewCompilationEngine(t, w)
+	ce.SetPath(d.path)
+	if ext {
+		ce.EnableExtensions()
+		ce.SetConstants(constants)
+	}
+	return ce, nil
+}
+
+// Return the constants of the program. The values can refer to the constants of the other classes.
+func programConstants(docs []*document) semantic.Constants {
+	constants := semantic.Constants{}
+	engines := []*compilation_engine.CompilationEngine{}
+	for _, d := range docs {
+		if ce, err := newCompilationEngine(d, true, nil); err == nil {
+			engines = append(engines, ce)
+		}
+	}
+	for n := -1; n != len(constants); {
+		n = len(constants)
+		for _, ce := range engines {
+			cs, err := ce.Constants(constants)
+			if err != nil {
+				continue
+			}
+			for _, c := range cs {
+				constants.Add(c)
+			}
+		}
+	}
+	return constants
+}
+
+// Compile the documents of a program and update their indices and diagnostics.
+func analyze(docs []*document, ext bool) {
+	var constants semantic.Constants
+	if ext {
+		constants = programConstants(docs)
+	}
+	classes := []*semantic.Class{}
+	// Undefined classes are reported only if every class is known.
+	closed := true
+	for _, d := range docs {
+		d.index = nil
+		d.diags = []*diagnostics.Diagnostic{}
+		ce, err := newCompilationEngine(d, ext, constants)
+		if err == nil {
+			err = ce.Compile()
+			d.index = buildIndex(ce.AST(), ast.Pos{Line: len(d.lines), Column: len(d.lines[len(d.lines)-1])})
+		}
+		if err != nil {
+			d.diags = append(d.diags, diagnostics.FromError(d.path, err)...)
+			closed = false
+			continue
+		}
+		class := ce.Class()
+		class.Path = d.path
+		classes = append(classes, class)
+		for _, w := range class.Warnings {
+			d.diags = append(d.diags, &diagnostics.Diagnostic{Path: d.path, Line: w.Pos[0], Column: w.Pos[1], Severity: diagnostics.WARNING, Message: w.Message})
+		}
+	}
+	for _, err := range semantic.NewProgram(classes, closed).Check(classes) {
+		for _, d := range docs {
+			if d.path == err.Path {
+				d.diags = append(d.diags, diagnostics.FromError(d.path, err)...)
+			}
+		}
+	}
+}
+
+// Return the index of the parsed class. It's nil if the class isn't parsed enough.
+func buildIndex(c *ast.Class, end ast.Pos) *index {
+	if c == nil || c.Name == nil {
+		return nil
+	}
+	return newIndex(c, end)
+}
+
+// Positions
+
+// Convert the LSP position to the position of the tokenizer, whose column is the 1-based byte offset.
+func (d *document) pos(p Position) ast.Pos {
+	if p.Line < 0 || p.Line >= len(d.lines) {
+		return ast.Pos{Line: p.Line + 1, Column: 1}
+	}
+	line := d.lines[p.Line]
+	offset, units := 0, 0
+	for offset < len(line) && units < p.Character {
+		r, size := utf8.DecodeRuneInString(line[offset:])
+		offset += size
+		units += len(utf16.Encode([]rune{r}))
+	}
+	return ast.Pos{Line: p.Line + 1, Column: offset + 1}
+}
+
+// Convert the position of the tokenizer to the LSP position.
+func (d *document) position(pos ast.Pos) Position {
+	if pos.Line < 1 {
+		return Position{}
+	}
+	if pos.Line > len(d.lines) {
+		return Position{Line: pos.Line - 1}
+	}
+	line := d.lines[pos.Line-1]
+	offset := pos.Column - 1
+	if offset > len(line) {
+		offset = len(line)
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return Position{pos.Line - 1, len(utf16.Encode([]rune(line[:offset])))}
+}
+
+// Return the range of n bytes from the position.
+func (d *document) rangeOf(pos ast.Pos, n int) Range {
+	return Range{d.position(pos), d.position(ast.Pos{Line: pos.Line, Column: pos.Column + n})}
+}
+
+// Return the range of the identifier or the character at the position.
+func (d *document) tokenRange(pos ast.Pos) Range {
+	if pos.Line < 1 {
+		pos = ast.Pos{Line: 1, Column: 1}
+	}
+	n := 1
+	if pos.Line <= len(d.lines) {
+		if m := identPattern.FindString(d.lines[pos.Line-1][min(pos.Column-1, len(d.lines[pos.Line-1])):]); m != "" {
+			n = len(m)
+		}
+	}
+	return d.rangeOf(pos, n)
+}
+
+var identPattern = regexp.MustCompile(`^\w+`)
+
+func min(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func (d *document) diagnostic(diag *diagnostics.Diagnostic) Diagnostic {
+	severity := severityError
+	if diag.Severity == diagnostics.WARNING {
+		severity = severityWarning
+	}
+	message := diag.Message
+	if diag.Suggestion != "" {
+		message += fmt.Sprintf(" Did you mean '%v'?", diag.Suggestion)
+	}
+	return Diagnostic{d.tokenRange(ast.Pos{Line: diag.Line, Column: diag.Column}), severity, "jackls", message}
+}
+
+// Declaration range: from the first token to the last character, or the name.
+func (d *document) declarationRange(decl *declaration) Range {
+	if decl.end == decl.pos {
+		return d.rangeOf(decl.pos, len(decl.name))
+	}
+	return Range{d.position(decl.start), d.position(ast.Pos{Line: decl.end.Line, Column: decl.end.Column + 1})}
+}
+
+// Features
+
+// Return the reference at the position of the request and its document.
+func (s *server) referenceAt(params TextDocumentPositionParams) (reference, *document, bool) {
+	d := s.document(params.TextDocument.URI)
+	if d == nil || d.index == nil {
+		return reference{}, nil, false
+	}
+	r, ok := d.index.referenceAt(d.pos(params.Position))
+	return r, d, ok
+}
+
+// Return the declaration in the program of the directory.
+func (s *server) declaration(dir string, key string) (*declaration, *document) {
+	for _, d := range s.program(dir) {
+		if d.index == nil {
+			continue
+		}
+		if decl, ok := d.index.decls[key]; ok {
+			return decl, d
+		}
+	}
+	return nil, nil
+}
+
+// Return the location of the declaration. The OS classes without the source have none.
+func (s *server) definition(params TextDocumentPositionParams) interface{} {
+	r, d, ok := s.referenceAt(params)
+	if !ok {
+		return nil
+	}
+	decl, declDoc := s.declaration(filepath.Dir(d.path), r.key)
+	if decl == nil {
+		return nil
+	}
+	return []Location{{pathToURI(declDoc.path), declDoc.rangeOf(decl.pos, len(decl.name))}}
+}
+
+var docCommentLine = regexp.MustCompile(`^\s*\*? ?`)
+
+// Return the text of the doc comment without /** */ and the leading *.
+func docText(doc string) string {
+	doc = strings.TrimSuffix(strings.TrimPrefix(doc, "/**"), "*/")
+	lines := strings.Split(strings.ReplaceAll(doc, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(docCommentLine.ReplaceAllString(line, ""), " \t")
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// Return the signature of the OS subroutine or class of the key such as "function int Math.multiply(int, int)".
+func osDetail(key string) (string, bool) {
+	className, name, isSubroutine := cut(key, ".")
+	if strings.ContainsAny(key, "#:") {
+		return "", false
+	}
+	c, ok := semantic.NewProgram(nil, false).Class(className)
+	if !ok {
+		return "", false
+	}
+	if !isSubroutine {
+		return "class " + className, true
+	}
+	sub, ok := c.Subroutine(name)
+	if !ok {
+		return "", false
+	}
+	return fmt.Sprintf("%v %v %v.%v(%v)", sub.Kind, sub.ReturnType, className, sub.Name, strings.Join(sub.Params, ", ")), true
+}
+
+func (s *server) hover(params TextDocumentPositionParams) interface{} {
+	r, d, ok := s.referenceAt(params)
+	if !ok {
+		return nil
+	}
+	var detail, doc string
+	if decl, _ := s.declaration(filepath.Dir(d.path), r.key); decl != nil {
+		detail, doc = decl.detail, docText(decl.doc)
+	} else if detail, ok = osDetail(r.key); !ok {
+		return nil
+	}
+	value := "```jack\n" + detail + "\n```"
+	if doc != "" {
+		value += "\n\n" + doc
+	}
+	rng := d.rangeOf(r.pos, len(r.name))
+	return Hover{MarkupContent{"markdown", value}, &rng}
+}
+
+func (s *server) references(params ReferenceParams) interface{} {
+	r, d, ok := s.referenceAt(params.TextDocumentPositionParams)
+	if !ok {
+		return nil
+	}
+	locations := []Location{}
+	for _, doc := range s.program(filepath.Dir(d.path)) {
+		if doc.index == nil {
+			continue
+		}
+		refs := []reference{}
+		for _, ref := range doc.index.refs {
+			if ref.key == r.key && (!ref.decl || params.Context.IncludeDeclaration) {
+				refs = append(refs, ref)
+			}
+		}
+		sort.SliceStable(refs, func(i, j int) bool {
+			return refs[i].pos.Line < refs[j].pos.Line || refs[i].pos.Line == refs[j].pos.Line && refs[i].pos.Column < refs[j].pos.Column
+		})
+		for _, ref := range refs {
+			locations = append(locations, Location{pathToURI(doc.path), doc.rangeOf(ref.pos, len(ref.name))})
+		}
+	}
+	return locations
+}
+
+var memberAccess = regexp.MustCompile(`(\w+)\.(\w*)$`)
+
+// Complete the members after "ClassName." or "varName.". Methods are listed for a variable and
+// the functions, constructors and constants for a class. The OS classes are included.
+func (s *server) completion(params TextDocumentPositionParams) interface{} {
+	items := []CompletionItem{}
+	d := s.document(params.TextDocument.URI)
+	if d == nil || params.Position.Line >= len(d.lines) {
+		return items
+	}
+	pos := d.pos(params.Position)
+	m := memberAccess.FindStringSubmatch(d.lines[pos.Line-1][:pos.Column-1])
+	if m == nil {
+		return items
+	}
+	className, onVariable := m[1], false
+	if d.index != nil {
+		if t, ok := d.index.typeOf(m[1], pos); ok {
+			className, onVariable = t, true
+		}
+	}
+	add := func(name string, kind string, detail string) {
+		if !strings.HasPrefix(name, m[2]) || (kind == "method") != onVariable {
+			return
+		}
+		switch kind {
+		case "method":
+			items = append(items, CompletionItem{name, completionMethod, detail})
+		case "function":
+			items = append(items, CompletionItem{name, completionFunction, detail})
+		case "constructor":
+			items = append(items, CompletionItem{name, completionConstructor, detail})
+		case "const":
+			items = append(items, CompletionItem{name, completionConstant, detail})
+		}
+	}
+	if decl, _ := s.declaration(filepath.Dir(d.path), className); decl != nil {
+		kinds := map[int]string{symbolMethod: "method", symbolFunction: "function", symbolConstructor: "constructor", symbolConstant: "const", symbolEnumMember: "const"}
+		var walk func(decls []*declaration)
+		walk = func(decls []*declaration) {
+			for _, c := range decls {
+				if c.kind == symbolConstant || c.kind == symbolEnumMember {
+					if s.ext {
+						add(c.name, "const", c.detail)
+					}
+				} else if kind, ok := kinds[c.kind]; ok {
+					add(c.name, kind, c.detail)
+				}
+				walk(c.children)
+			}
+		}
+		walk(decl.children)
+	} else if c, ok := semantic.NewProgram(nil, false).Class(className); ok {
+		for _, sub := range c.Subroutines {
+			detail, _ := osDetail(subroutineKey(className, sub.Name))
+			add(sub.Name, sub.Kind, detail)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Label < items[j].Label })
+	return items
+}
+
+func (s *server) documentSymbol(params DocumentSymbolParams) interface{} {
+	d := s.document(params.TextDocument.URI)
+	if d == nil || d.index == nil {
+		return []DocumentSymbol{}
+	}
+	var symbol func(decl *declaration) DocumentSymbol
+	symbol = func(decl *declaration) DocumentSymbol {
+		ds := DocumentSymbol{Name: decl.name, Detail: decl.detail, Kind: decl.kind, Range: d.declarationRange(decl), SelectionRange: d.rangeOf(decl.pos, len(decl.name))}
+		for _, c := range decl.children {
+			ds.Children = append(ds.Children, symbol(c))
+		}
+		return ds
+	}
+	return []DocumentSymbol{symbol(d.index.class)}
+}
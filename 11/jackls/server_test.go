@@ -0,0 +1,435 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compiler/ast"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const pointSource = `/** A point on the screen. */
+class Point {
+    field int x, y;
+
+    /** Make a point at (ax, ay). */
+    constructor Point new(int ax, int ay) {
+        let x = ax;
+        let y = ay;
+        return this;
+    }
+
+    method int getX() {
+        return x;
+    }
+
+    function int distance(Point p, Point q) {
+        return Math.abs(p.getX() - q.getX());
+    }
+}
+`
+
+const mainSource = `class Main {
+    function void main() {
+        var Point p;
+        let p = Point.new(1, 2);
+        do Output.printInt(p.getX());
+        do p.
+        do Math.
+        return;
+    }
+}
+`
+
+// script is the JSON-RPC messages sent to the server.
+type script struct {
+	b  bytes.Buffer
+	id int
+}
+
+// Add a request and return its ID.
+func (s *script) request(method string, params interface{}) int {
+	s.id++
+	s.write(map[string]interface{}{"jsonrpc": "2.0", "id": s.id, "method": method, "params": params})
+	return s.id
+}
+
+func (s *script) notify(method string, params interface{}) {
+	s.write(map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+func (s *script) write(msg interface{}) {
+	body, _ := json.Marshal(msg)
+	fmt.Fprintf(&s.b, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+// response is a message the server sent.
+type response struct {
+	ID     *int            `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *responseError  `json:"error"`
+}
+
+// Run the server with the script and return the responses by ID and the notifications in the order.
+func runScript(t *testing.T, s *script, ext bool) (map[int]response, []response) {
+	t.Helper()
+	var out bytes.Buffer
+	srv := newServer(&s.b, &out, ext)
+	if err := srv.run(); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+	responses := map[int]response{}
+	notifications := []response{}
+	r := bufio.NewReader(&out)
+	for {
+		msg, err := readMessage(r)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("readMessage() error: %v", err)
+		}
+		body, _ := json.Marshal(msg)
+		var res response
+		if err := json.Unmarshal(body, &res); err != nil {
+			t.Fatal(err)
+		}
+		if res.ID != nil {
+			responses[*res.ID] = res
+		} else {
+			notifications = append(notifications, res)
+		}
+	}
+	return responses, notifications
+}
+
+// Write the sources to a temporary directory and return the URIs by file name.
+func writeProgram(t *testing.T, sources map[string]string) map[string]string {
+	t.Helper()
+	dir := t.TempDir()
+	uris := map[string]string{}
+	for name, src := range sources {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(src), 0666); err != nil {
+			t.Fatal(err)
+		}
+		uris[name] = pathToURI(path)
+	}
+	return uris
+}
+
+func position(uri string, line int, character int) map[string]interface{} {
+	return map[string]interface{}{"textDocument": map[string]string{"uri": uri}, "position": Position{line, character}}
+}
+
+func decode(t *testing.T, res response, v interface{}) {
+	t.Helper()
+	if res.Error != nil {
+		t.Fatalf("error response: %v", res.Error.Message)
+	}
+	if err := json.Unmarshal(res.Result, v); err != nil {
+		t.Fatalf("Unmarshal(%s) error: %v", res.Result, err)
+	}
+}
+
+func TestServer_Lifecycle(t *testing.T) {
+	s := &script{}
+	initialize := s.request("initialize", map[string]interface{}{"capabilities": map[string]interface{}{}})
+	s.notify("initialized", map[string]interface{}{})
+	unknown := s.request("workspace/symbol", map[string]string{"query": ""})
+	shutdown := s.request("shutdown", nil)
+	s.notify("exit", nil)
+	after := s.request("shutdown", nil)
+
+	responses, _ := runScript(t, s, false)
+	var result struct {
+		Capabilities struct {
+			TextDocumentSync   int  `json:"textDocumentSync"`
+			DefinitionProvider bool `json:"definitionProvider"`
+			CompletionProvider struct {
+				TriggerCharacters []string `json:"triggerCharacters"`
+			} `json:"completionProvider"`
+		} `json:"capabilities"`
+	}
+	decode(t, responses[initialize], &result)
+	if result.Capabilities.TextDocumentSync != 1 || !result.Capabilities.DefinitionProvider || !cmp.Equal(result.Capabilities.CompletionProvider.TriggerCharacters, []string{"."}) {
+		t.Errorf("initialize result = %+v", result)
+	}
+	if res := responses[unknown]; res.Error == nil || res.Error.Code != methodNotFound {
+		t.Errorf("unknown method response = %+v, want the error %v", res, methodNotFound)
+	}
+	if res := responses[shutdown]; res.Error != nil || string(res.Result) != "null" {
+		t.Errorf("shutdown response = %+v, want the null result", res)
+	}
+	if _, ok := responses[after]; ok {
+		t.Errorf("The server responded after exit")
+	}
+}
+
+func TestServer_Diagnostics(t *testing.T) {
+	uris := writeProgram(t, map[string]string{"Point.jack": pointSource, "Main.jack": "class Main {\n    function void main() {\n        do Point.nwe(1, 2);\n        return;\n    }\n}\n"})
+	s := &script{}
+	s.notify("textDocument/didOpen", map[string]interface{}{"textDocument": TextDocumentItem{uris["Main.jack"], "jack", 1, "class Main {\n    function void main() {\n        do Point.nwe(1, 2);\n        return;\n    }\n}\n"}})
+	s.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": uris["Main.jack"], "version": 2},
+		"contentChanges": []map[string]string{{"text": "class Main {\n    function void main() {\n        let x = 1\n    }\n}\n"}},
+	})
+	s.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": uris["Main.jack"], "version": 3},
+		"contentChanges": []map[string]string{{"text": "class Main {\n    function void main() {\n        do Point.new(1, 2);\n        return;\n    }\n}\n"}},
+	})
+	s.notify("textDocument/didClose", map[string]interface{}{"textDocument": map[string]string{"uri": uris["Main.jack"]}})
+
+	_, notifications := runScript(t, s, false)
+	got := [][]Diagnostic{}
+	for _, n := range notifications {
+		var params PublishDiagnosticsParams
+		if err := json.Unmarshal(n.Params, &params); err != nil {
+			t.Fatal(err)
+		}
+		if n.Method != "textDocument/publishDiagnostics" || params.URI != uris["Main.jack"] {
+			t.Fatalf("notification = %v %+v", n.Method, params)
+		}
+		got = append(got, params.Diagnostics)
+	}
+	if len(got) != 4 {
+		t.Fatalf("got %v publications, want 4: %+v", len(got), got)
+	}
+	want := []Diagnostic{{Range{Position{2, 11}, Position{2, 16}}, severityError, "jackls", "Subroutine Point.nwe is not defined"}}
+	if diff := cmp.Diff(want, got[0]); diff != "" {
+		t.Errorf("Diagnostics of the undefined subroutine differ: %v", diff)
+	}
+	if len(got[1]) == 0 || got[1][0].Range.Start.Line != 2 && got[1][0].Range.Start.Line != 3 {
+		t.Errorf("Diagnostics of the syntax error = %+v, want an error around line 3", got[1])
+	}
+	if len(got[2]) != 0 || len(got[3]) != 0 {
+		t.Errorf("Diagnostics after the fix and close = %+v, %+v, want none", got[2], got[3])
+	}
+}
+
+func TestServer_Navigation(t *testing.T) {
+	uris := writeProgram(t, map[string]string{"Point.jack": pointSource, "Main.jack": mainSource})
+	s := &script{}
+	s.notify("textDocument/didOpen", map[string]interface{}{"textDocument": TextDocumentItem{uris["Main.jack"], "jack", 1, mainSource}})
+	tests := []struct {
+		name   string
+		method string
+		params interface{}
+		want   interface{}
+	}{
+		{
+			name:   "definition of a class",
+			method: "textDocument/definition",
+			params: position(uris["Main.jack"], 2, 13),
+			want:   []Location{{uris["Point.jack"], Range{Position{1, 6}, Position{1, 11}}}},
+		},
+		{
+			name:   "definition of a constructor in another file",
+			method: "textDocument/definition",
+			params: position(uris["Main.jack"], 3, 24),
+			want:   []Location{{uris["Point.jack"], Range{Position{5, 22}, Position{5, 25}}}},
+		},
+		{
+			name:   "definition of a method called on a variable",
+			method: "textDocument/definition",
+			params: position(uris["Main.jack"], 4, 31),
+			want:   []Location{{uris["Point.jack"], Range{Position{11, 15}, Position{11, 19}}}},
+		},
+		{
+			name:   "definition of a local variable",
+			method: "textDocument/definition",
+			params: position(uris["Main.jack"], 4, 27),
+			want:   []Location{{uris["Main.jack"], Range{Position{2, 18}, Position{2, 19}}}},
+		},
+		{
+			name:   "definition of an OS subroutine",
+			method: "textDocument/definition",
+			params: position(uris["Main.jack"], 4, 20),
+			want:   nil,
+		},
+		{
+			name:   "hover on a local variable",
+			method: "textDocument/hover",
+			params: position(uris["Main.jack"], 3, 12),
+			want:   Hover{MarkupContent{"markdown", "```jack\nvar Point p\n```"}, &Range{Position{3, 12}, Position{3, 13}}},
+		},
+		{
+			name:   "hover on a constructor with the doc comment",
+			method: "textDocument/hover",
+			params: position(uris["Main.jack"], 3, 22),
+			want:   Hover{MarkupContent{"markdown", "```jack\nconstructor Point Point.new(int ax, int ay)\n```\n\nMake a point at (ax, ay)."}, &Range{Position{3, 22}, Position{3, 25}}},
+		},
+		{
+			name:   "hover on an OS function",
+			method: "textDocument/hover",
+			params: position(uris["Main.jack"], 4, 19),
+			want:   Hover{MarkupContent{"markdown", "```jack\nfunction void Output.printInt(int)\n```"}, &Range{Position{4, 18}, Position{4, 26}}},
+		},
+		{
+			name:   "references of a method with the declaration",
+			method: "textDocument/references",
+			params: map[string]interface{}{"textDocument": map[string]string{"uri": uris["Main.jack"]}, "position": Position{4, 32}, "context": map[string]bool{"includeDeclaration": true}},
+			want: []Location{
+				{uris["Main.jack"], Range{Position{4, 29}, Position{4, 33}}},
+				{uris["Point.jack"], Range{Position{11, 15}, Position{11, 19}}},
+				{uris["Point.jack"], Range{Position{16, 26}, Position{16, 30}}},
+				{uris["Point.jack"], Range{Position{16, 37}, Position{16, 41}}},
+			},
+		},
+		{
+			name:   "references of a field without the declaration",
+			method: "textDocument/references",
+			params: map[string]interface{}{"textDocument": map[string]string{"uri": uris["Point.jack"]}, "position": Position{2, 14}, "context": map[string]bool{"includeDeclaration": false}},
+			want: []Location{
+				{uris["Point.jack"], Range{Position{6, 12}, Position{6, 13}}},
+				{uris["Point.jack"], Range{Position{12, 15}, Position{12, 16}}},
+			},
+		},
+		{
+			name:   "completion of methods on a variable",
+			method: "textDocument/completion",
+			params: position(uris["Main.jack"], 5, 13),
+			want:   []CompletionItem{{"getX", completionMethod, "method int Point.getX()"}},
+		},
+		{
+			name:   "completion of an OS class",
+			method: "textDocument/completion",
+			params: position(uris["Main.jack"], 6, 16),
+			want: []CompletionItem{
+				{"abs", completionFunction, "function int Math.abs(int)"},
+				{"divide", completionFunction, "function int Math.divide(int, int)"},
+				{"init", completionFunction, "function void Math.init()"},
+				{"max", completionFunction, "function int Math.max(int, int)"},
+				{"min", completionFunction, "function int Math.min(int, int)"},
+				{"multiply", completionFunction, "function int Math.multiply(int, int)"},
+				{"sqrt", completionFunction, "function int Math.sqrt(int)"},
+			},
+		},
+		{
+			name:   "completion of a class with a prefix",
+			method: "textDocument/completion",
+			params: position(uris["Main.jack"], 3, 26),
+			want:   []CompletionItem{},
+		},
+	}
+	ids := make([]int, len(tests))
+	for i, tt := range tests {
+		ids[i] = s.request(tt.method, tt.params)
+	}
+	responses, _ := runScript(t, s, false)
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := responses[ids[i]]
+			want, _ := json.Marshal(tt.want)
+			if res.Error != nil {
+				t.Fatalf("error response: %v", res.Error.Message)
+			}
+			if diff := cmp.Diff(string(want), string(res.Result)); diff != "" {
+				t.Errorf("%v result differs: %v", tt.method, diff)
+			}
+		})
+	}
+}
+
+func TestServer_CompletionOfClass(t *testing.T) {
+	src := "class Main {\n    const int SIZE = 4;\n    enum Color { RED, GREEN }\n    function void main() {\n        do Main.\n    }\n    method void draw() {\n        return;\n    }\n}\n"
+	uris := writeProgram(t, map[string]string{"Main.jack": src})
+	s := &script{}
+	s.notify("textDocument/didOpen", map[string]interface{}{"textDocument": TextDocumentItem{uris["Main.jack"], "jack", 1, src}})
+	completion := s.request("textDocument/completion", position(uris["Main.jack"], 4, 16))
+	symbols := s.request("textDocument/documentSymbol", map[string]interface{}{"textDocument": map[string]string{"uri": uris["Main.jack"]}})
+
+	responses, _ := runScript(t, s, true)
+	var items []CompletionItem
+	decode(t, responses[completion], &items)
+	labels := []string{}
+	for _, item := range items {
+		labels = append(labels, item.Label)
+	}
+	if diff := cmp.Diff([]string{"GREEN", "RED", "SIZE", "main"}, labels); diff != "" {
+		t.Errorf("Completion labels differ: %v", diff)
+	}
+
+	var got []DocumentSymbol
+	decode(t, responses[symbols], &got)
+	var names func(ds []DocumentSymbol) string
+	names = func(ds []DocumentSymbol) string {
+		s := []string{}
+		for _, d := range ds {
+			n := fmt.Sprintf("%v:%v", d.Name, d.Kind)
+			if len(d.Children) > 0 {
+				n += "(" + names(d.Children) + ")"
+			}
+			s = append(s, n)
+		}
+		return strings.Join(s, " ")
+	}
+	if diff := cmp.Diff("Main:5(SIZE:14 Color:10(RED:22 GREEN:22) main:12 draw:6)", names(got)); diff != "" {
+		t.Errorf("Document symbols differ: %v", diff)
+	}
+	if diff := cmp.Diff(Range{Position{6, 4}, Position{8, 5}}, got[0].Children[3].Range); diff != "" {
+		t.Errorf("Range of the method differs: %v", diff)
+	}
+}
+
+func TestDocument_Position(t *testing.T) {
+	d := newDocument("A.jack", "let s = \"héllo\"; let x = 1;\r\nlet y = 2;")
+	// é is 2 bytes in UTF-8 and 1 code unit in UTF-16.
+	for _, p := range []Position{{0, 0}, {0, 10}, {0, 21}, {1, 4}} {
+		if got := d.position(d.pos(p)); got != p {
+			t.Errorf("position(pos(%v)) = %v", p, got)
+		}
+	}
+	if got := d.pos(Position{0, 21}).Column; got != 23 {
+		t.Errorf("pos() column = %v, want 23", got)
+	}
+}
+
+func TestBuildIndex_Incomplete(t *testing.T) {
+	// Nodes which a syntax error left without their names or types
+	ident := func(name string, line int) *ast.Ident {
+		return &ast.Ident{NamePos: ast.Pos{Line: line, Column: 5}, Name: name}
+	}
+	class := &ast.Class{
+		Name:   ident("Main", 1),
+		Vars:   []*ast.ClassVarDec{{Kind: "field", Names: []*ast.Ident{ident("x", 2), nil}}},
+		Consts: []*ast.ConstDecl{{}},
+		Enums:  []*ast.EnumDecl{{Name: ident("Color", 3), Members: []*ast.Ident{nil}}},
+		Subroutines: []*ast.Subroutine{
+			{Kind: "function"},
+			{
+				Kind:   "function",
+				Name:   ident("run", 4),
+				Params: []*ast.Param{{Name: ident("a", 4)}, {Type: &ast.Type{Name: "int"}}},
+				Locals: []*ast.VarDec{{Names: []*ast.Ident{ident("b", 5)}}},
+				Body: &ast.Block{Stmts: []ast.Stmt{
+					&ast.DoStmt{Call: &ast.CallExpr{Receiver: ident("a", 6)}},
+					&ast.ReturnStmt{Value: &ast.ConstRef{Class: ident("Main", 7)}},
+				}},
+			},
+		},
+	}
+	x := buildIndex(class, ast.Pos{Line: 8, Column: 1})
+	if x == nil {
+		t.Fatal("buildIndex() = nil")
+	}
+	got := []string{}
+	for _, d := range x.class.children {
+		got = append(got, d.detail)
+	}
+	want := []string{"field  x", "enum Color", "function  Main.run( a)"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Declarations differ: %v", diff)
+	}
+	if _, ok := x.decls[localKey("Main", "run", "b")]; !ok {
+		t.Errorf("Local variable b isn't declared: %v", x.decls)
+	}
+}
@@ -1,118 +1,489 @@
 package main
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
 
+	"compiler/build_cache"
 	"compiler/compilation_engine"
+	"compiler/diagnostics"
+	"compiler/semantic"
 	"compiler/tokenizer"
 	"compiler/vmwriter"
 )
 
+// Return the version of the compiler for the cache keys. It's the hash of the executable
+// so that the cache entries of another build aren't used even if the generated code is the same.
+func compilerVersion() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Open(exe)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
 var (
 	tokenizeOnly = flag.Bool("tokenize", false, "Tokenization only mode")
+	cacheDir     = flag.String("cache-dir", build_cache.DefaultDir(), "Directory of the build cache")
+	cleanCache   = flag.Bool("clean", false, "Remove all entries in the build cache before compiling")
+	jobs         = flag.Int("j", runtime.NumCPU(), "Number of .jack files compiled in parallel")
+	typeCheck    = flag.Bool("typecheck", false, "Check types of assignments, return values, conditions, operators, array indexing and arguments")
+	maxErrors    = flag.Int("max-errors", 10, "Maximum number of errors reported. 0 means no limit")
+	diagFormat   = flag.String("diagnostics-format", diagnostics.TEXT, "Format of errors: text or json")
+	color        = flag.Bool("color", false, "Colorize errors in text format")
+	precedence   = flag.Bool("precedence", false, "Apply the conventional operator precedence: unary, * /, + -, < > =, &, |, && and then ||. Jack applies operators from left to right without it")
+	warnPrec     = flag.Bool("warn-precedence", false, "Warn expressions whose value depends on the operator precedence")
+	ext          = flag.Bool("ext", false, "Enable the language extensions: for loops, break, continue, else if, switch, compound assignments, ++, --, short-circuit && and ||, character literals, string escapes, hexadecimal and binary integers, constants and enums")
+	optimize     = flag.Bool("O", false, "Fold constant expressions and write multiplications by small constants with additions instead of calling Math.multiply. Divisions except by 1 and -1, even by powers of two, still call Math.divide")
+	debugInfo    = flag.Bool("g", false, "Write a comment of the .jack file name, line and source before the VM commands of each line")
+	stringPool   = flag.Bool("string-pool", false, "Build each distinct string literal once per class and reuse it instead of allocating a new String at every evaluation")
 )
 
-func compile(srcPath string) error {
-	f, err := os.Open(srcPath)
+var buildCache *build_cache.Cache
+
+// Constants of the classes by directory with the language extensions.
+// A class refers to the constants of the other classes in the same directory.
+var programConstants = map[string]semantic.Constants{}
+
+// Return the compile options which affect the output of the source. They are a part of the cache key.
+func cacheOptions(srcPath string) string {
+	options := fmt.Sprintf("precedence=%v,warn-precedence=%v,O=%v,ext=%v,string-pool=%v,g=%v", *precedence, *warnPrec, *optimize, *ext, *stringPool, *debugInfo)
+	if *ext {
+		// The values of the constants in the other classes are compiled into the code.
+		options += ",constants=" + programConstants[filepath.Dir(srcPath)].String()
+	}
+	return options
+}
+
+// Make a tokenizer with the options of the command line.
+func newTokenizer(src []byte) (*tokenizer.Tokenizer, error) {
+	t, err := tokenizer.NewTokenizer(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	if *ext {
+		t.EnableExtensions()
+	}
+	return t, nil
+}
+
+// Make a compilation engine of the source with the options of the command line.
+func newCompilationEngine(t *tokenizer.Tokenizer, w *vmwriter.VMWriter, srcPath string) *compilation_engine.CompilationEngine {
+	ce := compilation_engine.NewCompilationEngine(t, w)
+	if *precedence {
+		ce.EnablePrecedence()
+	}
+	if *warnPrec {
+		ce.EnablePrecedenceWarning()
+	}
+	if *ext {
+		ce.EnableExtensions()
+		ce.SetConstants(programConstants[filepath.Dir(srcPath)])
+	}
+	if *optimize {
+		ce.EnableOptimization()
+	}
+	if *stringPool {
+		ce.EnableStringPool()
+	}
+	return ce
+}
+
+// Artifact names in the build cache
+const (
+	tokenArtifact = "T.xml"
+	treeArtifact  = "xml"
+	vmArtifact    = "vm"
+	classArtifact = "class.json" // semantic.Class for the whole-program check
+)
+
+func outputPaths(srcPath string) (tokenDstPath string, treeDstPath string, vmDstPath string) {
+	base := filepath.Base(srcPath)
+	name := base[:strings.LastIndex(base, ".")]
+	dir := filepath.Dir(srcPath)
+	return filepath.Join(dir, name+"T.xml.out"), filepath.Join(dir, name+".xml.out"), filepath.Join(dir, name+".vm.out")
+}
+
+// Write the cached outputs if the source is unchanged since the last compilation.
+// It returns false if the cache can't be used.
+func restoreFromCache(srcPath string, key string) (*semantic.Class, bool) {
+	if buildCache == nil || *tokenizeOnly {
+		return nil, false
+	}
+	artifacts, ok := buildCache.Get(key, tokenArtifact, treeArtifact, vmArtifact, classArtifact)
+	if !ok {
+		return nil, false
+	}
+	class := &semantic.Class{}
+	if err := json.Unmarshal(artifacts[classArtifact], class); err != nil {
+		return nil, false
+	}
+	class.Path = srcPath
+	tokenDstPath, treeDstPath, vmDstPath := outputPaths(srcPath)
+	for path, artifact := range map[string]string{tokenDstPath: tokenArtifact, treeDstPath: treeArtifact, vmDstPath: vmArtifact} {
+		if err := ioutil.WriteFile(path, artifacts[artifact], 0666); err != nil {
+			return nil, false
+		}
+	}
+	if os.Getenv("LOGLEVEL") == "debug" {
+		log.Printf("Cache hit: src=%v, key=%v\n", srcPath, key)
+	}
+	return class, true
+}
+
+// Compile the .jack file and return the declarations and calls in it.
+// The returned class is nil in tokenization only mode.
+func compile(srcPath string) (*semantic.Class, error) {
+	src, err := os.ReadFile(srcPath)
 	if err != nil {
-		log.Fatalf("Failed to open .jack: %v", err)
+		return nil, fmt.Errorf("Failed to open .jack: %v", err)
+	}
+
+	var key string
+	if buildCache != nil {
+		key = buildCache.Key(src, cacheOptions(srcPath))
+		if class, ok := restoreFromCache(srcPath, key); ok {
+			return class, nil
+		}
 	}
 
 	// Tokenize
-	tokenizer, err := tokenizer.NewTokenizer(f)
+	tokenizer, err := newTokenizer(src)
 	if err != nil {
-		log.Fatalf("Failed to initialize tokenizer: %v", err)
+		return nil, fmt.Errorf("Failed to initialize tokenizer: %v", err)
 	}
 	err = tokenizer.Tokenize()
 	if err != nil {
-		return fmt.Errorf("Failed to tokenize: src=%v: %v", srcPath, err)
+		// The lexical errors have their positions.
+		return nil, err
 	}
 
 	tokenXML := tokenizer.XML()
-	base := filepath.Base(srcPath)
-	tokenFilename := base[:strings.LastIndex(base, ".")] + "T.xml.out"
-	tokenDstPath := filepath.Join(filepath.Dir(srcPath), tokenFilename)
+	tokenDstPath, treeDstPath, vmDstPath := outputPaths(srcPath)
 	if os.Getenv("LOGLEVEL") == "debug" {
 		log.Printf("Tokenize output path=%v\n", tokenDstPath)
 	}
 	err = ioutil.WriteFile(tokenDstPath, []byte(tokenXML), 0666)
 	if err != nil {
-		return err
+		return nil, err
 	} else if *tokenizeOnly {
-		return nil
+		return nil, nil
 	}
 
 	vmWriter, err := vmwriter.NewVMWriter()
 
 	// Compile
-	ce := compilation_engine.NewCompilationEngine(tokenizer, vmWriter)
+	ce := newCompilationEngine(tokenizer, vmWriter, srcPath)
+	ce.SetPath(srcPath)
 	err = ce.Compile()
 	if err != nil {
-		return fmt.Errorf("Failed to parse: src=%v: %v", srcPath, err)
+		// The syntax and semantic errors have their positions. They are rendered by diagnostics.
+		return nil, err
 	}
+	class := ce.Class()
+	class.Path = srcPath
 
 	// Write parse tree
 	treeXML := ce.XML()
-	treeFileName := base[:strings.LastIndex(base, ".")] + ".xml.out"
-	treeDstPath := filepath.Join(filepath.Dir(srcPath), treeFileName)
 	if os.Getenv("LOGLEVEL") == "debug" {
 		log.Printf("Parse output path=%v\n", treeDstPath)
 	}
 
 	err = ioutil.WriteFile(treeDstPath, []byte(treeXML), 0666)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Write VM code
-	vmFilename := base[:strings.LastIndex(base, ".")] + ".vm.out"
-	vmDstPath := filepath.Join(filepath.Dir(srcPath), vmFilename)
-	ce.WriteCode(vmDstPath)
+	if *debugInfo {
+		err = ce.WriteAnnotatedCode(vmDstPath, src)
+	} else {
+		err = ce.WriteCode(vmDstPath)
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	return nil
+	if buildCache != nil {
+		vmCode, err := os.ReadFile(vmDstPath)
+		if err != nil {
+			return nil, err
+		}
+		classJSON, err := json.Marshal(class)
+		if err != nil {
+			return nil, err
+		}
+		err = buildCache.Put(key, map[string][]byte{tokenArtifact: []byte(tokenXML), treeArtifact: []byte(treeXML), vmArtifact: vmCode, classArtifact: classJSON})
+		if err != nil {
+			// The cache is only for speed. Compilation itself succeeded.
+			log.Printf("Warning: %v", err)
+		}
+	}
+	return class, nil
+}
+
+// Return .jack files in the directory in lexical order, or the file itself.
+func findSources(srcPath string) ([]string, error) {
+	finfo, err := os.Stat(srcPath)
+	if err != nil {
+		return nil, err
+	}
+	if !finfo.IsDir() {
+		if filepath.Ext(srcPath) != ".jack" {
+			return nil, fmt.Errorf("Not a .jack file")
+		}
+		return []string{srcPath}, nil
+	}
+	srcPaths := make([]string, 0)
+	err = filepath.Walk(srcPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && filepath.Ext(info.Name()) == ".jack" {
+			srcPaths = append(srcPaths, path)
+		}
+		return nil
+	})
+	return srcPaths, err
+}
+
+// Compute the constants of the classes in the directories of the sources, including the sources not compiled.
+// A constant can refer to the constants of the other classes, so it repeats until no more constants are computed.
+// Errors are left to the compilation.
+func collectConstants(srcPaths []string) map[string]semantic.Constants {
+	constants := map[string]semantic.Constants{}
+	for _, srcPath := range srcPaths {
+		dir := filepath.Dir(srcPath)
+		if _, ok := constants[dir]; ok {
+			continue
+		}
+		constants[dir] = semantic.Constants{}
+		paths, _ := filepath.Glob(filepath.Join(dir, "*.jack"))
+		engines := []*compilation_engine.CompilationEngine{}
+		for _, path := range paths {
+			src, err := os.ReadFile(path)
+			if err != nil {
+				continue
+			}
+			tokenizer, err := newTokenizer(src)
+			if err != nil || tokenizer.Tokenize() != nil {
+				continue
+			}
+			vmWriter, _ := vmwriter.NewVMWriter()
+			engines = append(engines, newCompilationEngine(tokenizer, vmWriter, path))
+		}
+		for n := -1; n != len(constants[dir]); {
+			n = len(constants[dir])
+			for _, ce := range engines {
+				cs, err := ce.Constants(constants[dir])
+				if err != nil {
+					continue
+				}
+				for _, c := range cs {
+					constants[dir].Add(c)
+				}
+			}
+		}
+	}
+	return constants
+}
+
+// Compile the files with at most nJobs workers.
+// The i-th class and error are the results of the i-th file. The error is nil if it succeeded.
+func compileAll(srcPaths []string, nJobs int) ([]*semantic.Class, []error) {
+	if nJobs < 1 {
+		nJobs = 1
+	}
+	classes := make([]*semantic.Class, len(srcPaths))
+	errs := make([]error, len(srcPaths))
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < nJobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				classes[i], errs[i] = compile(srcPaths[i])
+			}
+		}()
+	}
+	for i := range srcPaths {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+	return classes, errs
+}
+
+// Group the classes into programs by directory. It returns the directories in the order of the classes.
+func groupPrograms(classes []*semantic.Class) ([]string, map[string][]*semantic.Class) {
+	dirs := make([]string, 0)
+	programs := make(map[string][]*semantic.Class)
+	for _, c := range classes {
+		if c == nil {
+			continue
+		}
+		dir := filepath.Dir(c.Path)
+		if _, ok := programs[dir]; !ok {
+			dirs = append(dirs, dir)
+		}
+		programs[dir] = append(programs[dir], c)
+	}
+	return dirs, programs
+}
+
+// Check calls across the classes, and types if typeCheck is true. Classes in the same directory are a program.
+// Undefined classes are reported only when the whole directory is compiled.
+func checkPrograms(classes []*semantic.Class, wholeDir bool, typeCheck bool) []*semantic.Error {
+	dirs, programs := groupPrograms(classes)
+	errs := make([]*semantic.Error, 0)
+	for _, dir := range dirs {
+		p := semantic.NewProgram(programs[dir], wholeDir)
+		errs = append(errs, p.Check(programs[dir])...)
+		if typeCheck {
+			for _, c := range programs[dir] {
+				errs = append(errs, typeCheckClass(p, c)...)
+			}
+		}
+	}
+	semantic.SortErrors(errs)
+	return errs
+}
+
+// Return the warnings across the classes such as disposing of the pooled string literals.
+func warnPrograms(classes []*semantic.Class) []*semantic.Error {
+	dirs, programs := groupPrograms(classes)
+	warnings := make([]*semantic.Error, 0)
+	for _, dir := range dirs {
+		p := semantic.NewProgram(programs[dir], false)
+		warnings = append(warnings, p.Warnings(programs[dir])...)
+	}
+	semantic.SortErrors(warnings)
+	return warnings
+}
+
+// Compile the class again with the signatures in the program to check types.
+// The outputs are discarded.
+func typeCheckClass(p *semantic.Program, class *semantic.Class) []*semantic.Error {
+	src, err := os.ReadFile(class.Path)
+	if err != nil {
+		return nil
+	}
+	tokenizer, err := newTokenizer(src)
+	if err != nil || tokenizer.Tokenize() != nil {
+		return nil
+	}
+	vmWriter, _ := vmwriter.NewVMWriter()
+	ce := newCompilationEngine(tokenizer, vmWriter, class.Path)
+	tc := semantic.NewTypeChecker(p, class.Path)
+	ce.EnableTypeCheck(tc)
+	// Syntax errors were already reported in the first compilation.
+	ce.Compile()
+	return tc.Errors()
 }
 
 func main() {
 	flag.Parse()
 
 	args := flag.Args()
+	if *cleanCache {
+		// Clean removes the entries of every version.
+		c, err := build_cache.NewCache(*cacheDir, "")
+		if err == nil {
+			err = c.Clean()
+		}
+		if err != nil {
+			log.Fatalf("Failed to clean the build cache: %v", err)
+		}
+		if flag.NArg() < 1 {
+			return
+		}
+	}
 	if flag.NArg() < 1 {
 		exe, _ := os.Executable()
-		fmt.Fprintf(os.Stderr, "Usage: %v <.jack/.jack dir> [-tokenize | -parse]\n", filepath.Base(exe))
+		fmt.Fprintf(os.Stderr, "Usage: %v [-tokenize] [-typecheck] [-precedence] [-warn-precedence] [-ext] [-O] [-string-pool] [-g] [-max-errors n] [-diagnostics-format text|json] [-color] [-j n] [-cache-dir dir] [-clean] <.jack/.jack dir>\n", filepath.Base(exe))
 		os.Exit(1)
 	}
 
+	renderer, err := diagnostics.NewRenderer(os.Stderr, *diagFormat, *color)
+	if err != nil {
+		log.Fatal(err)
+	}
+	version, err := compilerVersion()
+	if err == nil {
+		buildCache, err = build_cache.NewCache(*cacheDir, version)
+	}
+	if err != nil {
+		// Compile without the cache
+		log.Printf("Warning: %v", err)
+		buildCache = nil
+	}
+
 	srcPath, _ := filepath.Abs(args[0])
-	finfo, err := os.Stat(srcPath)
+	srcPaths, err := findSources(srcPath)
 	if err != nil {
-		log.Fatalf("Couldn't read %v", srcPath)
+		log.Fatalf("Couldn't read %v: %v", srcPath, err)
 	}
-	if finfo.IsDir() {
-		err := filepath.Walk(srcPath, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
-			if !info.IsDir() && filepath.Ext(info.Name()) == ".jack" {
-				err = compile(path)
-				if err != nil {
-					return err
-				}
+
+	nErrors := 0
+	report := func(ds []*diagnostics.Diagnostic) {
+		for _, d := range ds {
+			nErrors++
+			if *maxErrors <= 0 || nErrors <= *maxErrors {
+				renderer.Render(d)
 			}
-			return nil
-		})
-		if err != nil {
-			log.Fatalf("Failed to compile: %v", err)
 		}
-	} else if filepath.Ext(srcPath) == ".jack" {
-		err = compile(srcPath)
+	}
+	if *ext {
+		programConstants = collectConstants(srcPaths)
+	}
+	classes, errs := compileAll(srcPaths, *jobs)
+	for i, err := range errs {
 		if err != nil {
-			log.Fatalf("Failed to compile %v: %v", srcPath, err)
+			report(diagnostics.FromError(srcPaths[i], err))
 		}
 	}
+	// Warnings don't fail the compilation and aren't counted as errors.
+	for _, c := range classes {
+		if c == nil {
+			continue
+		}
+		for _, w := range c.Warnings {
+			renderer.Render(&diagnostics.Diagnostic{Path: c.Path, Line: w.Pos[0], Column: w.Pos[1], Severity: diagnostics.WARNING, Message: w.Message})
+		}
+	}
+	for _, w := range warnPrograms(classes) {
+		renderer.Render(&diagnostics.Diagnostic{Path: w.Path, Line: w.Pos[0], Column: w.Pos[1], Severity: diagnostics.WARNING, Message: w.Message})
+	}
+	isDir := len(srcPaths) != 1 || srcPaths[0] != srcPath
+	for _, err := range checkPrograms(classes, isDir, *typeCheck) {
+		report(diagnostics.FromError(err.Path, err))
+	}
+	if *diagFormat == diagnostics.TEXT && *maxErrors > 0 && nErrors > *maxErrors {
+		fmt.Fprintf(os.Stderr, "too many errors (%v more)\n", nErrors-*maxErrors)
+	}
+	if nErrors > 0 {
+		os.Exit(1)
+	}
 }
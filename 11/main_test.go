@@ -0,0 +1,145 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"compiler/semantic"
+)
+
+// Copy .jack files and ans/ in srcDir to a temporary directory not to leave outputs in the repository.
+func copyTestDir(t *testing.T, srcDir string) string {
+	dstDir := t.TempDir()
+	for _, pattern := range []string{"*.jack", "ans/*.vm"} {
+		paths, _ := filepath.Glob(filepath.Join(srcDir, pattern))
+		for _, path := range paths {
+			b, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rel, _ := filepath.Rel(srcDir, path)
+			dst := filepath.Join(dstDir, rel)
+			os.MkdirAll(filepath.Dir(dst), 0777)
+			if err := os.WriteFile(dst, b, 0666); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return dstDir
+}
+
+func normalizeNewlines(s string) string {
+	return strings.TrimRight(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+}
+
+func TestCompileAll(t *testing.T) {
+	type test struct {
+		name   string
+		srcDir string
+	}
+	// Every program with the answers of the official compiler
+	tests := []test{}
+	ansDirs, _ := filepath.Glob("./test/*/ans")
+	for _, ansDir := range ansDirs {
+		srcDir := filepath.Dir(ansDir)
+		tests = append(tests, test{name: filepath.Base(srcDir), srcDir: srcDir})
+	}
+	if len(tests) < 6 {
+		t.Fatalf("found %v programs with answers in test/, want at least 6", len(tests))
+	}
+	tests = append(tests, test{name: "OS", srcDir: "../12"})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := copyTestDir(t, tt.srcDir)
+			srcPaths, err := findSources(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			classes, errs := compileAll(srcPaths, 4)
+			for i, err := range errs {
+				if err != nil {
+					t.Errorf("compile(%v) = %v", srcPaths[i], err)
+				}
+			}
+			for _, err := range checkPrograms(classes, true, true) {
+				t.Errorf("checkPrograms() = %v: %v", err.Path, err)
+			}
+			ansPaths, _ := filepath.Glob(filepath.Join(dir, "ans", "*.vm"))
+			for _, ansPath := range ansPaths {
+				want, _ := os.ReadFile(ansPath)
+				got, err := os.ReadFile(filepath.Join(dir, filepath.Base(ansPath)+".out"))
+				if err != nil {
+					t.Errorf("%v", err)
+				} else if normalizeNewlines(string(got)) != normalizeNewlines(string(want)) {
+					t.Errorf("%v differs from the answer", filepath.Base(ansPath))
+				}
+			}
+		})
+	}
+}
+
+func TestCompileAll_Errors(t *testing.T) {
+	dir := t.TempDir()
+	srcs := map[string]string{
+		"A.jack": "class A { function void f() { return; } }",
+		"B.jack": "class B { function void f() { let x = 1; return; } }",
+		"C.jack": "class C { function void f() { return; } }",
+		"D.jack": "class D { function void f() { let y = 1; return; } }",
+	}
+	for name, src := range srcs {
+		os.WriteFile(filepath.Join(dir, name), []byte(src), 0666)
+	}
+	srcPaths, _ := findSources(dir)
+	_, errs := compileAll(srcPaths, 3)
+	for i, path := range srcPaths {
+		wantErr := strings.HasSuffix(path, "B.jack") || strings.HasSuffix(path, "D.jack")
+		if (errs[i] != nil) != wantErr {
+			t.Errorf("compile(%v) = %v, want error: %v", filepath.Base(path), errs[i], wantErr)
+		}
+	}
+}
+
+func TestCompileAll_Constants(t *testing.T) {
+	*ext = true
+	defer func() {
+		*ext = false
+		programConstants = map[string]semantic.Constants{}
+	}()
+	dir := t.TempDir()
+	srcs := map[string]string{
+		"Main.jack":  "class Main { const int HALF = Board.WIDTH / 2; function int f() { return HALF + Board.RIGHT; } }",
+		"Board.jack": "class Board { const int WIDTH = Main.SIZE * 2; enum Direction { LEFT, RIGHT } }",
+		"Size.jack":  "class Size { function int f() { return Main.SIZE; } }",
+	}
+	for name, src := range srcs {
+		os.WriteFile(filepath.Join(dir, name), []byte(src), 0666)
+	}
+	// Main.SIZE isn't defined, so Board.WIDTH and Main.HALF can't be computed.
+	srcPaths, _ := findSources(dir)
+	programConstants = collectConstants(srcPaths)
+	if got := programConstants[dir].String(); got != "Board.LEFT=int 0\nBoard.RIGHT=int 1\n" {
+		t.Errorf("constants = %q", got)
+	}
+	before := cacheOptions(filepath.Join(dir, "Main.jack"))
+
+	// A constant can refer to a constant of another class which refers to the class.
+	srcs["Main.jack"] = "class Main { const int SIZE = 128; const int HALF = Board.WIDTH / 2; function int f() { return HALF + Board.RIGHT; } }"
+	os.WriteFile(filepath.Join(dir, "Main.jack"), []byte(srcs["Main.jack"]), 0666)
+	// Only Main.jack is compiled but the constants of the other classes in the directory are known.
+	srcPaths = []string{filepath.Join(dir, "Main.jack")}
+	programConstants = collectConstants(srcPaths)
+	if before == cacheOptions(srcPaths[0]) {
+		t.Error("cache options don't change with the constants")
+	}
+	_, errs := compileAll(srcPaths, 1)
+	if errs[0] != nil {
+		t.Fatal(errs[0])
+	}
+	got, _ := os.ReadFile(filepath.Join(dir, "Main.vm.out"))
+	want := "function Main.f 0\npush constant 128\npush constant 1\nadd\nreturn"
+	if normalizeNewlines(string(got)) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
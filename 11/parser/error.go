@@ -0,0 +1,50 @@
+package parser
+
+import (
+	"compiler/ast"
+	. "compiler/tokenizer"
+	"fmt"
+)
+
+// Error is a syntax error at a position of a source file.
+type Error struct {
+	Path string
+	Pos  ast.Pos
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("%d:%d: %s", e.Pos.Line, e.Pos.Column, e.Msg)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", e.Path, e.Pos.Line, e.Pos.Column, e.Msg)
+}
+
+// ErrorList is the syntax errors of a file in the order they are found.
+type ErrorList []*Error
+
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", l[0], len(l)-1)
+}
+
+// Describe the token for the error messages, e.g. keyword 'let' or symbol ';'
+func describe(token Token) string {
+	if token == nil {
+		return "end of file"
+	}
+	switch token.Type() {
+	case STR_CONST:
+		return fmt.Sprintf("string constant %q", token.String())
+	case INT_CONST, HEX_CONST, BIN_CONST:
+		return fmt.Sprintf("integer constant %v", token.String())
+	case CHAR_CONST:
+		return fmt.Sprintf("character constant '%v'", token.String())
+	}
+	return fmt.Sprintf("%v '%v'", token.Type(), token.String())
+}
@@ -0,0 +1,862 @@
+package parser
+
+import (
+	"compiler/ast"
+	. "compiler/tokenizer"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Parser builds the syntax tree of a class from the tokens.
+// It doesn't stop at a syntax error. It skips tokens to the next statement or declaration
+// and continues so that all errors are reported at once.
+type Parser struct {
+	t      *Tokenizer
+	path   string // Path of the source file for the error messages
+	eof    bool   // Whether the parser has consumed all tokens
+	ext    bool   // Whether the language extensions are enabled
+	errors ErrorList
+}
+
+func NewParser(t *Tokenizer, path string) *Parser {
+	_, err := t.LookAhead(0)
+	return &Parser{t: t, path: path, eof: err != nil}
+}
+
+// Parse the language extensions which aren't told by their tokens, such as else if.
+// The tokenizer should also enable them.
+func (p *Parser) EnableExtensions() {
+	p.ext = true
+}
+
+// Return the current token. It's nil at the end of the tokens.
+func (p *Parser) current() Token {
+	if p.eof {
+		return nil
+	}
+	return p.t.Current()
+}
+
+func (p *Parser) advance() {
+	if p.t.Advance() != nil {
+		p.eof = true
+	}
+}
+
+// Position of the current token. It's the last token at the end of the tokens.
+func (p *Parser) pos() ast.Pos {
+	if _, err := p.t.LookAhead(0); err != nil {
+		return ast.Pos{Line: 1, Column: 1}
+	}
+	return ast.NewPos(p.t.Current().Pos())
+}
+
+// Return the doc comment /** */ before the current token. It's empty if there is none.
+func (p *Parser) doc() string {
+	if p.current() == nil {
+		return ""
+	}
+	if c := p.t.CurrentItem().Doc(); c != nil {
+		return c.Text
+	}
+	return ""
+}
+
+// Return an error at the current token.
+func (p *Parser) errorExpected(what string) error {
+	return &Error{p.path, p.pos(), fmt.Sprintf("expected %v, found %v", what, describe(p.current()))}
+}
+
+// Record the error. Only the first error at a position is recorded since the others are caused by it.
+func (p *Parser) record(err error) {
+	e, ok := err.(*Error)
+	if !ok {
+		e = &Error{p.path, p.pos(), err.Error()}
+	}
+	if n := len(p.errors); n > 0 && p.errors[n-1].Pos == e.Pos {
+		return
+	}
+	p.errors = append(p.errors, e)
+}
+
+func (p *Parser) is(tokenType string, tokenString string) bool {
+	cur := p.current()
+	return cur != nil && cur.Type() == tokenType && cur.String() == tokenString
+}
+
+// Whether the n-th token after the current one is the token.
+func (p *Parser) isAhead(n int, tokenType string, tokenString string) bool {
+	t, err := p.t.LookAhead(n)
+	return err == nil && t.Type() == tokenType && t.String() == tokenString
+}
+
+func (p *Parser) isKeyword(keywords ...string) bool {
+	for _, k := range keywords {
+		if p.is(KEYWORD, k) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Parser) isSymbol(symbols ...string) bool {
+	for _, s := range symbols {
+		if p.is(SYMBOL, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether the current token starts a class variable, constant, enum or subroutine declaration
+func (p *Parser) isMember() bool {
+	return p.isKeyword(STATIC, FIELD, CONST, ENUM, CONSTRUCTOR, FUNCTION, METHOD)
+}
+
+// Skip tokens to the next statement after a syntax error.
+// It stops after ; or before }, a statement keyword or a declaration.
+func (p *Parser) syncStatement() {
+	for p.current() != nil {
+		if p.is(SYMBOL, ";") {
+			p.advance()
+			return
+		}
+		if p.is(SYMBOL, "}") || p.isKeyword(LET, IF, WHILE, DO, RETURN, FOR, BREAK, CONTINUE, SWITCH, CASE, DEFAULT) || p.isMember() {
+			return
+		}
+		p.advance()
+	}
+}
+
+// Skip tokens to the next declaration in the class after a syntax error.
+func (p *Parser) syncMember() {
+	for p.current() != nil && !p.isMember() {
+		p.advance()
+	}
+}
+
+// Consume the current token if it matches.
+func (p *Parser) expect(tokenType string, tokenString string) (ast.Pos, error) {
+	if !p.is(tokenType, tokenString) {
+		return ast.Pos{}, p.errorExpected(fmt.Sprintf("%v '%v'", tokenType, tokenString))
+	}
+	pos := p.pos()
+	p.advance()
+	return pos, nil
+}
+
+// Consume the current token if it's one of the keywords and return it.
+func (p *Parser) expectKeyword(what string, keywords ...string) (string, error) {
+	if !p.isKeyword(keywords...) {
+		return "", p.errorExpected(what)
+	}
+	k := p.current().String()
+	p.advance()
+	return k, nil
+}
+
+func (p *Parser) expectIdent() (*ast.Ident, error) {
+	cur := p.current()
+	if cur == nil || cur.Type() != IDENTIFIER {
+		return nil, p.errorExpected(IDENTIFIER)
+	}
+	ident := &ast.Ident{NamePos: p.pos(), Name: cur.String()}
+	p.advance()
+	return ident, nil
+}
+
+// int, char, boolean or className. void is also accepted if allowVoid is true.
+func (p *Parser) expectType(allowVoid bool) (*ast.Type, error) {
+	cur := p.current()
+	if cur == nil || !(cur.Type() == IDENTIFIER || p.isKeyword(INT, CHAR, BOOLEAN) || allowVoid && p.isKeyword(VOID)) {
+		if allowVoid {
+			return nil, p.errorExpected("type or keyword 'void'")
+		}
+		return nil, p.errorExpected("type")
+	}
+	t := &ast.Type{TypePos: p.pos(), Name: cur.String()}
+	p.advance()
+	return t, nil
+}
+
+// Parse a class. The tokenizer must be tokenized.
+// If there are syntax errors, it returns ErrorList with the tree parsed so far.
+func (p *Parser) ParseClass() (*ast.Class, error) {
+	class := p.parseClass()
+	if len(p.errors) > 0 {
+		return class, p.errors
+	}
+	return class, nil
+}
+
+// class className { classVarDec* subroutineDec* }
+func (p *Parser) parseClass() *ast.Class {
+	class := &ast.Class{Doc: p.doc(), ClassPos: p.pos(), Vars: []*ast.ClassVarDec{}, Consts: []*ast.ConstDecl{}, Enums: []*ast.EnumDecl{}, Subroutines: []*ast.Subroutine{}}
+	var err error
+	if _, err = p.expect(KEYWORD, CLASS); err != nil {
+		p.record(err)
+		return nil
+	}
+	if class.Name, err = p.expectIdent(); err != nil {
+		p.record(err)
+		return nil
+	}
+	if _, err = p.expect(SYMBOL, "{"); err != nil {
+		p.record(err)
+		return nil
+	}
+
+	for !p.is(SYMBOL, "}") {
+		switch {
+		case p.current() == nil:
+			p.record(p.errorExpected("symbol '}'"))
+			return class
+		case p.isKeyword(STATIC, FIELD):
+			d, err := p.parseClassVarDec()
+			if err != nil {
+				p.record(err)
+				p.syncMember()
+				continue
+			}
+			class.Vars = append(class.Vars, d)
+		case p.isKeyword(CONST):
+			d, err := p.parseConstDec()
+			if err != nil {
+				p.record(err)
+				p.syncMember()
+				continue
+			}
+			class.Consts = append(class.Consts, d)
+		case p.isKeyword(ENUM):
+			d, err := p.parseEnumDec()
+			if err != nil {
+				p.record(err)
+				p.syncMember()
+				continue
+			}
+			class.Enums = append(class.Enums, d)
+		case p.isKeyword(CONSTRUCTOR, FUNCTION, METHOD):
+			s, err := p.parseSubroutine()
+			if err != nil {
+				p.record(err)
+				p.syncMember()
+				continue
+			}
+			class.Subroutines = append(class.Subroutines, s)
+		default:
+			p.record(p.errorExpected("class variable or subroutine declaration"))
+			p.advance()
+			p.syncMember()
+			if p.current() == nil {
+				// The closing } has been skipped.
+				return class
+			}
+		}
+	}
+	return class
+}
+
+// const type constName = expression ;
+func (p *Parser) parseConstDec() (*ast.ConstDecl, error) {
+	d := &ast.ConstDecl{Doc: p.doc(), ConstPos: p.pos()}
+	p.advance()
+	var err error
+	if d.Type, err = p.expectType(false); err != nil {
+		return nil, err
+	}
+	if d.Name, err = p.expectIdent(); err != nil {
+		return nil, err
+	}
+	if _, err = p.expect(SYMBOL, "="); err != nil {
+		return nil, err
+	}
+	if d.Value, err = p.parseExpression(); err != nil {
+		return nil, err
+	}
+	if _, err = p.expect(SYMBOL, ";"); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// enum enumName { constName (, constName)* }
+func (p *Parser) parseEnumDec() (*ast.EnumDecl, error) {
+	d := &ast.EnumDecl{Doc: p.doc(), EnumPos: p.pos()}
+	p.advance()
+	var err error
+	if d.Name, err = p.expectIdent(); err != nil {
+		return nil, err
+	}
+	if _, err = p.expect(SYMBOL, "{"); err != nil {
+		return nil, err
+	}
+	if d.Members, err = p.parseNames(); err != nil {
+		return nil, err
+	}
+	if d.Rbrace, err = p.expect(SYMBOL, "}"); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// (static | field) type varName (, varName)* ;
+func (p *Parser) parseClassVarDec() (*ast.ClassVarDec, error) {
+	d := &ast.ClassVarDec{Doc: p.doc(), DeclPos: p.pos()}
+	var err error
+	if d.Kind, err = p.expectKeyword("keyword 'static' or 'field'", STATIC, FIELD); err != nil {
+		return nil, err
+	}
+	if d.Type, err = p.expectType(false); err != nil {
+		return nil, err
+	}
+	if d.Names, err = p.parseNames(); err != nil {
+		return nil, err
+	}
+	if _, err = p.expect(SYMBOL, ";"); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// varName (, varName)*
+func (p *Parser) parseNames() ([]*ast.Ident, error) {
+	names := make([]*ast.Ident, 0)
+	for {
+		name, err := p.expectIdent()
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+		if !p.is(SYMBOL, ",") {
+			return names, nil
+		}
+		p.advance()
+	}
+}
+
+// (constructor | function | method) (void | type) subroutineName ( parameterList ) subroutineBody
+// Errors in the body are recorded and only errors in the declaration are returned.
+func (p *Parser) parseSubroutine() (*ast.Subroutine, error) {
+	s := &ast.Subroutine{Doc: p.doc(), DeclPos: p.pos(), Params: []*ast.Param{}, Locals: []*ast.VarDec{}}
+	var err error
+	if s.Kind, err = p.expectKeyword("keyword 'constructor', 'function' or 'method'", CONSTRUCTOR, FUNCTION, METHOD); err != nil {
+		return nil, err
+	}
+	if s.ReturnType, err = p.expectType(true); err != nil {
+		return nil, err
+	}
+	if s.Name, err = p.expectIdent(); err != nil {
+		return nil, err
+	}
+	if _, err = p.expect(SYMBOL, "("); err != nil {
+		return nil, err
+	}
+	for !p.is(SYMBOL, ")") {
+		if len(s.Params) > 0 {
+			if _, err = p.expect(SYMBOL, ","); err != nil {
+				return nil, err
+			}
+		}
+		param := &ast.Param{}
+		if param.Type, err = p.expectType(false); err != nil {
+			return nil, err
+		}
+		if param.Name, err = p.expectIdent(); err != nil {
+			return nil, err
+		}
+		s.Params = append(s.Params, param)
+	}
+	p.advance()
+
+	// subroutineBody: { varDec* statements }
+	lbrace, err := p.expect(SYMBOL, "{")
+	if err != nil {
+		return nil, err
+	}
+	for p.is(KEYWORD, VAR) {
+		d, err := p.parseVarDec()
+		if err != nil {
+			p.record(err)
+			p.syncStatement()
+			continue
+		}
+		s.Locals = append(s.Locals, d)
+	}
+	s.Body = p.parseStatements(lbrace)
+	return s, nil
+}
+
+// var type varName (, varName)* ;
+func (p *Parser) parseVarDec() (*ast.VarDec, error) {
+	d := &ast.VarDec{VarPos: p.pos()}
+	p.advance()
+	var err error
+	if d.Type, err = p.expectType(false); err != nil {
+		return nil, err
+	}
+	if d.Names, err = p.parseNames(); err != nil {
+		return nil, err
+	}
+	if _, err = p.expect(SYMBOL, ";"); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// { statements }
+func (p *Parser) parseBlock() (*ast.Block, error) {
+	lbrace, err := p.expect(SYMBOL, "{")
+	if err != nil {
+		return nil, err
+	}
+	return p.parseStatements(lbrace), nil
+}
+
+// statements }
+// Errors in the statements are recorded and the parser continues from the next statement.
+func (p *Parser) parseStatements(lbrace ast.Pos) *ast.Block {
+	b := &ast.Block{Lbrace: lbrace}
+	var ok bool
+	if b.Stmts, ok = p.parseStatementList(func() bool { return p.is(SYMBOL, "}") }); !ok {
+		return b
+	}
+	b.Rbrace = p.pos()
+	p.advance()
+	return b
+}
+
+// Parse statements until isEnd returns true. The last token isn't consumed.
+// It returns false if the block isn't closed before the end of the tokens or the next declaration.
+func (p *Parser) parseStatementList(isEnd func() bool) ([]ast.Stmt, bool) {
+	stmts := []ast.Stmt{}
+	for !isEnd() {
+		if p.current() == nil || p.isMember() {
+			// The block isn't closed. Let the caller continue from the next declaration.
+			p.record(p.errorExpected("symbol '}'"))
+			return stmts, false
+		}
+		start := p.current()
+		s, err := p.parseStatement()
+		if err != nil {
+			p.record(err)
+			if p.current() == start {
+				p.advance()
+			}
+			p.syncStatement()
+			continue
+		}
+		stmts = append(stmts, s)
+	}
+	return stmts, true
+}
+
+func (p *Parser) parseStatement() (ast.Stmt, error) {
+	switch {
+	case p.is(KEYWORD, LET):
+		return p.parseLet()
+	case p.is(KEYWORD, IF):
+		return p.parseIf()
+	case p.is(KEYWORD, WHILE):
+		return p.parseWhile()
+	case p.is(KEYWORD, DO):
+		return p.parseDo()
+	case p.is(KEYWORD, RETURN):
+		return p.parseReturn()
+	case p.is(KEYWORD, FOR):
+		return p.parseFor()
+	case p.is(KEYWORD, SWITCH):
+		return p.parseSwitch()
+	case p.isKeyword(BREAK, CONTINUE):
+		return p.parseBranch()
+	}
+	return nil, p.errorExpected("statement")
+}
+
+// let varName ([ expression ])? = expression ;
+func (p *Parser) parseLet() (*ast.LetStmt, error) {
+	s, err := p.parseLetClause()
+	if err != nil {
+		return nil, err
+	}
+	if _, err = p.expect(SYMBOL, ";"); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// let varName ([ expression ])? = expression
+// The language extension adds let varName ([ expression ])? op= expression and let varName ([ expression ])? ++|--
+func (p *Parser) parseLetClause() (*ast.LetStmt, error) {
+	s := &ast.LetStmt{LetPos: p.pos()}
+	p.advance()
+	var err error
+	if s.Name, err = p.expectIdent(); err != nil {
+		return nil, err
+	}
+	if p.is(SYMBOL, "[") {
+		p.advance()
+		if s.Index, err = p.parseExpression(); err != nil {
+			return nil, err
+		}
+		if _, err = p.expect(SYMBOL, "]"); err != nil {
+			return nil, err
+		}
+	}
+	s.OpPos = p.pos()
+	if p.isSymbol(INCREMENT, DECREMENT) {
+		s.Op = p.current().String()
+		p.advance()
+		return s, nil
+	}
+	if p.isSymbol(PLUS_EQUAL, MINUS_EQUAL, ASTERISK_EQUAL, SLASH_EQUAL, AND_EQUAL, OR_EQUAL) {
+		s.Op = strings.TrimSuffix(p.current().String(), "=")
+		p.advance()
+	} else if _, err = p.expect(SYMBOL, "="); err != nil {
+		return nil, err
+	}
+	if s.Value, err = p.parseExpression(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// ( expression )
+func (p *Parser) parseCondition() (ast.Expr, error) {
+	if _, err := p.expect(SYMBOL, "("); err != nil {
+		return nil, err
+	}
+	cond, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if _, err = p.expect(SYMBOL, ")"); err != nil {
+		return nil, err
+	}
+	return cond, nil
+}
+
+// if ( expression ) { statements } (else { statements })?
+// else ifStatement is also allowed with the language extensions.
+func (p *Parser) parseIf() (*ast.IfStmt, error) {
+	s := &ast.IfStmt{IfPos: p.pos()}
+	p.advance()
+	var err error
+	if s.Cond, err = p.parseCondition(); err != nil {
+		return nil, err
+	}
+	if s.Then, err = p.parseBlock(); err != nil {
+		return nil, err
+	}
+	if p.is(KEYWORD, ELSE) {
+		p.advance()
+		if p.ext && p.is(KEYWORD, IF) {
+			s.ElseIf, err = p.parseIf()
+			return s, err
+		}
+		if s.Else, err = p.parseBlock(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// while ( expression ) { statements }
+func (p *Parser) parseWhile() (*ast.WhileStmt, error) {
+	s := &ast.WhileStmt{WhilePos: p.pos()}
+	p.advance()
+	var err error
+	if s.Cond, err = p.parseCondition(); err != nil {
+		return nil, err
+	}
+	if s.Body, err = p.parseBlock(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// for ( letStatement? expression? ; (let varName ([ expression ])? = expression)? ) { statements }
+func (p *Parser) parseFor() (*ast.ForStmt, error) {
+	s := &ast.ForStmt{ForPos: p.pos()}
+	p.advance()
+	var err error
+	if _, err = p.expect(SYMBOL, "("); err != nil {
+		return nil, err
+	}
+	if p.is(SYMBOL, ";") {
+		p.advance()
+	} else {
+		if !p.is(KEYWORD, LET) {
+			return nil, p.errorExpected("let statement or symbol ';'")
+		}
+		if s.Init, err = p.parseLet(); err != nil {
+			return nil, err
+		}
+	}
+	if !p.is(SYMBOL, ";") {
+		if s.Cond, err = p.parseExpression(); err != nil {
+			return nil, err
+		}
+	}
+	if _, err = p.expect(SYMBOL, ";"); err != nil {
+		return nil, err
+	}
+	if !p.is(SYMBOL, ")") {
+		if !p.is(KEYWORD, LET) {
+			return nil, p.errorExpected("let statement or symbol ')'")
+		}
+		if s.Post, err = p.parseLetClause(); err != nil {
+			return nil, err
+		}
+	}
+	if _, err = p.expect(SYMBOL, ")"); err != nil {
+		return nil, err
+	}
+	if s.Body, err = p.parseBlock(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// switch ( expression ) { ((case expression | default) : statements)* }
+func (p *Parser) parseSwitch() (*ast.SwitchStmt, error) {
+	s := &ast.SwitchStmt{SwitchPos: p.pos()}
+	p.advance()
+	var err error
+	if s.Tag, err = p.parseCondition(); err != nil {
+		return nil, err
+	}
+	if _, err = p.expect(SYMBOL, "{"); err != nil {
+		return nil, err
+	}
+	isEnd := func() bool { return p.is(SYMBOL, "}") || p.isKeyword(CASE, DEFAULT) }
+	for !p.is(SYMBOL, "}") {
+		if p.current() == nil || p.isMember() {
+			p.record(p.errorExpected("symbol '}'"))
+			return s, nil
+		}
+		c, err := p.parseCaseLabel()
+		if err != nil {
+			// Skip the clause and continue from the next one.
+			p.record(err)
+			for p.current() != nil && !p.isMember() && !isEnd() {
+				p.advance()
+			}
+			continue
+		}
+		var ok bool
+		c.Body, ok = p.parseStatementList(isEnd)
+		s.Cases = append(s.Cases, c)
+		if !ok {
+			return s, nil
+		}
+	}
+	s.Rbrace = p.pos()
+	p.advance()
+	return s, nil
+}
+
+// (case expression | default) :
+func (p *Parser) parseCaseLabel() (*ast.CaseClause, error) {
+	c := &ast.CaseClause{CasePos: p.pos()}
+	k, err := p.expectKeyword("keyword 'case' or 'default'", CASE, DEFAULT)
+	if err != nil {
+		return nil, err
+	}
+	if k == CASE {
+		if c.Value, err = p.parseExpression(); err != nil {
+			return nil, err
+		}
+	}
+	if _, err = p.expect(SYMBOL, ":"); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// (break | continue) ;
+func (p *Parser) parseBranch() (*ast.BranchStmt, error) {
+	s := &ast.BranchStmt{TokPos: p.pos(), Tok: p.current().String()}
+	p.advance()
+	if _, err := p.expect(SYMBOL, ";"); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// do subroutineCall ;
+func (p *Parser) parseDo() (*ast.DoStmt, error) {
+	s := &ast.DoStmt{DoPos: p.pos()}
+	p.advance()
+	name, err := p.expectIdent()
+	if err != nil {
+		return nil, err
+	}
+	if s.Call, err = p.parseCall(name); err != nil {
+		return nil, err
+	}
+	if _, err = p.expect(SYMBOL, ";"); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// return expression? ;
+func (p *Parser) parseReturn() (*ast.ReturnStmt, error) {
+	s := &ast.ReturnStmt{ReturnPos: p.pos()}
+	p.advance()
+	if !p.is(SYMBOL, ";") {
+		var err error
+		if s.Value, err = p.parseExpression(); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := p.expect(SYMBOL, ";"); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func isOp(token Token) bool {
+	if token == nil || token.Type() != SYMBOL {
+		return false
+	}
+	switch token.String() {
+	case "+", "-", "*", "/", "&", "|", "<", ">", "=", LOGICAL_AND, LOGICAL_OR:
+		return true
+	}
+	return false
+}
+
+// term (op term)*
+// Jack has no operator precedence. Operators are applied from left to right.
+func (p *Parser) parseExpression() (ast.Expr, error) {
+	x, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for isOp(p.current()) {
+		b := &ast.BinaryExpr{X: x, OpPos: p.pos(), Op: p.current().String()}
+		p.advance()
+		if b.Y, err = p.parseTerm(); err != nil {
+			return nil, err
+		}
+		x = b
+	}
+	return x, nil
+}
+
+func (p *Parser) parseTerm() (ast.Expr, error) {
+	cur := p.current()
+	if cur == nil {
+		return nil, p.errorExpected("term")
+	}
+	pos := p.pos()
+	switch cur.Type() {
+	case INT_CONST:
+		i, err := strconv.Atoi(cur.String())
+		if err != nil {
+			return nil, &Error{p.path, pos, err.Error()}
+		}
+		p.advance()
+		return &ast.IntLit{ValuePos: pos, Value: i, Literal: cur.String()}, nil
+	case HEX_CONST, BIN_CONST:
+		p.advance()
+		return &ast.IntLit{ValuePos: pos, Value: cur.(*IntConst).Int(), Literal: cur.String()}, nil
+	case CHAR_CONST:
+		p.advance()
+		return &ast.CharLit{ValuePos: pos, Value: cur.(*CharConst).Int(), Literal: cur.String()}, nil
+	case STR_CONST:
+		p.advance()
+		s := &ast.StringLit{ValuePos: pos, Value: cur.(*StrConst).Value()}
+		if s.Value != cur.String() {
+			s.Literal = cur.String()
+		}
+		return s, nil
+	case KEYWORD:
+		switch cur.String() {
+		case TRUE, FALSE, NULL, THIS:
+			p.advance()
+			return &ast.KeywordConst{KeywordPos: pos, Value: cur.String()}, nil
+		}
+	case SYMBOL:
+		switch cur.String() {
+		case "-", "~":
+			p.advance()
+			x, err := p.parseTerm()
+			if err != nil {
+				return nil, err
+			}
+			return &ast.UnaryExpr{OpPos: pos, Op: cur.String(), X: x}, nil
+		case "(":
+			p.advance()
+			x, err := p.parseExpression()
+			if err != nil {
+				return nil, err
+			}
+			if _, err = p.expect(SYMBOL, ")"); err != nil {
+				return nil, err
+			}
+			return &ast.ParenExpr{Lparen: pos, X: x}, nil
+		}
+	case IDENTIFIER:
+		name, _ := p.expectIdent()
+		if p.is(SYMBOL, "[") {
+			// varName [ expression ]
+			p.advance()
+			index, err := p.parseExpression()
+			if err != nil {
+				return nil, err
+			}
+			if _, err = p.expect(SYMBOL, "]"); err != nil {
+				return nil, err
+			}
+			return &ast.IndexExpr{Name: name, Index: index}, nil
+		} else if p.ext && p.is(SYMBOL, ".") && !p.isAhead(2, SYMBOL, "(") {
+			// className . constName of the language extensions
+			p.advance()
+			constName, err := p.expectIdent()
+			if err != nil {
+				return nil, err
+			}
+			return &ast.ConstRef{Class: name, Name: constName}, nil
+		} else if p.is(SYMBOL, "(") || p.is(SYMBOL, ".") {
+			return p.parseCall(name)
+		}
+		// varName
+		return name, nil
+	}
+	return nil, p.errorExpected("term")
+}
+
+// The first identifier of the call is already consumed.
+// subroutineName ( expressionList ) | (className | varName) . subroutineName ( expressionList )
+func (p *Parser) parseCall(first *ast.Ident) (*ast.CallExpr, error) {
+	call := &ast.CallExpr{Name: first, Args: []ast.Expr{}}
+	if p.is(SYMBOL, ".") {
+		p.advance()
+		name, err := p.expectIdent()
+		if err != nil {
+			return nil, err
+		}
+		call.Receiver = first
+		call.Name = name
+	}
+	if _, err := p.expect(SYMBOL, "("); err != nil {
+		return nil, err
+	}
+	for !p.is(SYMBOL, ")") {
+		if len(call.Args) > 0 {
+			if _, err := p.expect(SYMBOL, ","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		call.Args = append(call.Args, arg)
+	}
+	p.advance()
+	return call, nil
+}
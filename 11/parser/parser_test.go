@@ -0,0 +1,537 @@
+package parser
+
+import (
+	"compiler/ast"
+	"compiler/tokenizer"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func parse(src string) (*ast.Class, error) {
+	return parseWith(src, false)
+}
+
+// Parse the source with the language extensions if ext is true.
+func parseWith(src string, ext bool) (*ast.Class, error) {
+	tk, _ := tokenizer.NewTokenizer(strings.NewReader(src))
+	if ext {
+		tk.EnableExtensions()
+	}
+	if err := tk.Tokenize(); err != nil {
+		return nil, err
+	}
+	p := NewParser(tk, "A.jack")
+	if ext {
+		p.EnableExtensions()
+	}
+	return p.ParseClass()
+}
+
+// Write the expression with parentheses to show the shape of the tree
+func sexpr(e ast.Expr) string {
+	switch e := e.(type) {
+	case *ast.IntLit:
+		return e.Literal
+	case *ast.Ident:
+		return e.Name
+	case *ast.KeywordConst:
+		return e.Value
+	case *ast.UnaryExpr:
+		return "(" + e.Op + sexpr(e.X) + ")"
+	case *ast.BinaryExpr:
+		return "(" + sexpr(e.X) + " " + e.Op + " " + sexpr(e.Y) + ")"
+	case *ast.ParenExpr:
+		return sexpr(e.X)
+	case *ast.IndexExpr:
+		return e.Name.Name + "[" + sexpr(e.Index) + "]"
+	case *ast.ConstRef:
+		return e.Class.Name + "." + e.Name.Name
+	case *ast.CallExpr:
+		args := []string{}
+		for _, a := range e.Args {
+			args = append(args, sexpr(a))
+		}
+		name := e.Name.Name
+		if e.Receiver != nil {
+			name = e.Receiver.Name + "." + name
+		}
+		return name + "(" + strings.Join(args, ", ") + ")"
+	}
+	return "?"
+}
+
+func TestParser_Expression(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		want string
+	}{
+		{name: "left associative", expr: "1 + 2 * 3 - x", want: "(((1 + 2) * 3) - x)"},
+		{name: "parentheses", expr: "1 + (2 * 3)", want: "(1 + (2 * 3))"},
+		{name: "unary", expr: "-a[i] & ~true", want: "((-a[i]) & (~true))"},
+		{name: "calls", expr: "f(1, g()) + Math.max(a, b)", want: "(f(1, g()) + Math.max(a, b))"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			class, err := parse("class A { function void f() { return " + tt.expr + "; } }")
+			if err != nil {
+				t.Fatal(err)
+			}
+			ret := class.Subroutines[0].Body.Stmts[0].(*ast.ReturnStmt)
+			if got := sexpr(ret.Value); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParser_LogicalOperators(t *testing.T) {
+	c, err := parseWith("class A { function void f() { return a && b || ~c & d; } }", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ret := c.Subroutines[0].Body.Stmts[0].(*ast.ReturnStmt)
+	if got, want := sexpr(ret.Value), "(((a && b) || (~c)) & d)"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	_, err = parseWith("class A { function void f() { return a && b; } }", false)
+	if list, ok := err.(ErrorList); !ok || list[0].Error() != "A.jack:1:41: expected term, found symbol '&'" {
+		t.Errorf("got %v, want the error at the second &", err)
+	}
+}
+
+func TestParser_Pos(t *testing.T) {
+	src := "class A {\n  function void f() {\n    let x = y + 1;\n  }\n}"
+	class, err := parse(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []ast.Pos{}
+	ast.Inspect(class, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.LetStmt, *ast.BinaryExpr, *ast.IntLit:
+			got = append(got, n.Pos())
+		}
+		return true
+	})
+	want := []ast.Pos{{Line: 3, Column: 5}, {Line: 3, Column: 13}, {Line: 3, Column: 17}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("positions differ: %v", diff)
+	}
+}
+
+func TestParser_Doc(t *testing.T) {
+	src := `// Not a doc comment
+/** A class. */
+class A {
+  /** Count. */ field int count;
+  static int total; /** Not attached. */ // Comment
+
+  /**
+   * Create A.
+   */
+  constructor A new() { /** In the body. */ return this; }
+  /* Not a doc comment */ method void f() { return; }
+}`
+	class, err := parse(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{class.Doc}
+	for _, d := range class.Vars {
+		got = append(got, d.Doc)
+	}
+	for _, s := range class.Subroutines {
+		got = append(got, s.Doc)
+	}
+	want := []string{"/** A class. */", "/** Count. */", "", "/**\n   * Create A.\n   */", ""}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("doc comments differ: %v", diff)
+	}
+}
+
+func TestParser_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []string
+	}{
+		{
+			name: "missing term",
+			src:  "class A { function void f() { return } }",
+			want: []string{"A.jack:1:38: expected term, found symbol '}'"},
+		},
+		{
+			name: "unexpected end",
+			src:  "class A { function void f() { return;",
+			want: []string{"A.jack:1:37: expected symbol '}', found end of file"},
+		},
+		{
+			name: "bad class body",
+			src:  "class A { let x; function void f() { return; } let }",
+			want: []string{
+				"A.jack:1:11: expected class variable or subroutine declaration, found keyword 'let'",
+				"A.jack:1:48: expected class variable or subroutine declaration, found keyword 'let'",
+			},
+		},
+		{
+			name: "no tokens",
+			src:  "",
+			want: []string{"A.jack:1:1: expected keyword 'class', found end of file"},
+		},
+		{
+			name: "errors in statements",
+			src: `class A {
+  function void f() {
+    var int x
+    let x = 1 +;
+    do Output.printInt(x;
+    if (x) { let = 2; }
+    return;
+  }
+  method void g(int) { return; }
+  function int h() { return 1 }
+}`,
+			want: []string{
+				"A.jack:4:5: expected symbol ';', found keyword 'let'",
+				"A.jack:4:16: expected term, found symbol ';'",
+				"A.jack:5:25: expected symbol ',', found symbol ';'",
+				"A.jack:6:18: expected identifier, found symbol '='",
+				"A.jack:9:20: expected identifier, found symbol ')'",
+				"A.jack:10:31: expected symbol ';', found symbol '}'",
+			},
+		},
+		{
+			name: "unclosed block",
+			src:  "class A { function void f() { if (true) { return; } function void g() { return; } }",
+			want: []string{"A.jack:1:53: expected symbol '}', found keyword 'function'"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parse(tt.src)
+			list, ok := err.(ErrorList)
+			if !ok {
+				t.Fatalf("got %v, want ErrorList", err)
+			}
+			got := []string{}
+			for _, e := range list {
+				got = append(got, e.Error())
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("errors differ: %v", diff)
+			}
+		})
+	}
+}
+
+func TestParser_For(t *testing.T) {
+	tests := []struct {
+		stmt string
+		want string // init; cond; post
+	}{
+		{"for (let i = 0; i < 10; let i = i + 1) { break; }", "i = 0; (i < 10); i = (i + 1)"},
+		{"for (let a[i] = 1; ; let a[i] = a[i] * 2) { continue; }", "a[i] = 1; ; a[i] = (a[i] * 2)"},
+		{"for (; ;) { break; }", "; ; "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.stmt, func(t *testing.T) {
+			c, err := parseWith("class A { function void f() { "+tt.stmt+" return; } }", true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s, ok := c.Subroutines[0].Body.Stmts[0].(*ast.ForStmt)
+			if !ok {
+				t.Fatalf("got %T, want *ast.ForStmt", c.Subroutines[0].Body.Stmts[0])
+			}
+			let := func(l *ast.LetStmt) string {
+				if l == nil {
+					return ""
+				}
+				name := l.Name.Name
+				if l.Index != nil {
+					name += "[" + sexpr(l.Index) + "]"
+				}
+				return name + " = " + sexpr(l.Value)
+			}
+			cond := ""
+			if s.Cond != nil {
+				cond = sexpr(s.Cond)
+			}
+			if got := let(s.Init) + "; " + cond + "; " + let(s.Post); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			if _, ok := s.Body.Stmts[0].(*ast.BranchStmt); !ok {
+				t.Errorf("got %T, want *ast.BranchStmt", s.Body.Stmts[0])
+			}
+		})
+	}
+}
+
+func TestParser_ForErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		ext  bool
+		want string
+	}{
+		{"init without let", "for (i = 0; i < 1; let i = i + 1) {}", true, "A.jack:1:36: expected let statement or symbol ';', found identifier 'i'"},
+		{"post with semicolon", "for (let i = 0; i < 1; let i = i + 1;) {}", true, "A.jack:1:67: expected symbol ')', found symbol ';'"},
+		{"missing semicolon", "break }", true, "A.jack:1:37: expected symbol ';', found symbol '}'"},
+		{"not extended", "for (let i = 0; i < 1; let i = i + 1) {}", false, "A.jack:1:31: expected statement, found identifier 'for'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseWith("class A { function void f() { "+tt.src+" return; } }", tt.ext)
+			list, ok := err.(ErrorList)
+			if !ok || len(list) == 0 {
+				t.Fatalf("got %v, want ErrorList", err)
+			}
+			if got := list[0].Error(); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParser_CompoundAssignment(t *testing.T) {
+	tests := []struct {
+		stmt string
+		want string
+	}{
+		{"let x += 1;", "x + (1) at 1:37"},
+		{"let a[i - 1] *= x + 2;", "a[(i - 1)] * ((x + 2)) at 1:44"},
+		{"let x /= -x;", "x / ((-x)) at 1:37"},
+		{"let x &= y; let x |= y; let x -= y;", "x & (y) at 1:37"},
+		{"let x++;", "x ++ at 1:36"},
+		{"let a[0]--;", "a[0] -- at 1:39"},
+		{"let x = y;", "x  (y) at 1:37"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.stmt, func(t *testing.T) {
+			c, err := parseWith("class A { function void f() { "+tt.stmt+" return; } }", true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := c.Subroutines[0].Body.Stmts[0].(*ast.LetStmt)
+			got := s.Name.Name
+			if s.Index != nil {
+				got += "[" + sexpr(s.Index) + "]"
+			}
+			got += " " + s.Op
+			if s.Value != nil {
+				got += " (" + sexpr(s.Value) + ")"
+			}
+			got += fmt.Sprintf(" at %v:%v", s.OpPos.Line, s.OpPos.Column)
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// They are errors in Jack.
+	_, err := parseWith("class A { function void f() { let x += 1; } }", false)
+	if list, ok := err.(ErrorList); !ok || list[0].Error() != "A.jack:1:37: expected symbol '=', found symbol '+'" {
+		t.Errorf("got %v, want the error at +=", err)
+	}
+	_, err = parseWith("class A { function void f() { let x++ 1; } }", true)
+	if list, ok := err.(ErrorList); !ok || list[0].Error() != "A.jack:1:39: expected symbol ';', found integer constant 1" {
+		t.Errorf("got %v, want the error after ++", err)
+	}
+}
+
+func TestParser_ConstEnum(t *testing.T) {
+	src := `class A {
+  static int s;
+  /** Width. */ const int W = 512 / B.N;
+  enum Dir { UP, DOWN }
+  field int f;
+  function int g() { return A.W + UP + A.g() + B.h(); }
+}`
+	c, err := parseWith(src, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, d := range c.Decls() {
+		switch d := d.(type) {
+		case *ast.ClassVarDec:
+			got = append(got, d.Kind+" "+d.Names[0].Name)
+		case *ast.ConstDecl:
+			got = append(got, fmt.Sprintf("%v const %v %v = %v", d.Doc, d.Type.Name, d.Name.Name, sexpr(d.Value)))
+		case *ast.EnumDecl:
+			got = append(got, fmt.Sprintf("enum %v %v %v at %v:%v", d.Name.Name, d.Members[0].Name, d.Members[1].Name, d.Rbrace.Line, d.Rbrace.Column))
+		}
+	}
+	ret := c.Subroutines[0].Body.Stmts[0].(*ast.ReturnStmt)
+	got = append(got, sexpr(ret.Value))
+	want := []string{"static s", "/** Width. */ const int W = (512 / B.N)", "enum Dir UP DOWN at 4:23", "field f", "(((A.W + UP) + A.g()) + B.h())"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("declarations differ: %v", diff)
+	}
+
+	// A.W needs parentheses in Jack.
+	_, err = parseWith("class A { function int g() { return A.W; } }", false)
+	if list, ok := err.(ErrorList); !ok || list[0].Error() != "A.jack:1:40: expected symbol '(', found symbol ';'" {
+		t.Errorf("got %v, want the error after A.W", err)
+	}
+	_, err = parseWith("class A { const int W; enum E { } function void f() { return; } }", true)
+	list, ok := err.(ErrorList)
+	if !ok || len(list) != 2 || list[0].Error() != "A.jack:1:22: expected symbol '=', found symbol ';'" || list[1].Error() != "A.jack:1:33: expected identifier, found symbol '}'" {
+		t.Errorf("got %v, want the errors of const and enum", err)
+	}
+}
+
+func TestParser_ElseIf(t *testing.T) {
+	src := "class A { function void f(int x) { if (x = 1) { return; } else if (x = 2) { return; } else if (x = 3) { return; } else { return; } } }"
+	c, err := parseWith(src, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conds := []string{}
+	s := c.Subroutines[0].Body.Stmts[0].(*ast.IfStmt)
+	for ; s.ElseIf != nil; s = s.ElseIf {
+		conds = append(conds, sexpr(s.Cond))
+		if s.Else != nil {
+			t.Errorf("Else of %v isn't nil", sexpr(s.Cond))
+		}
+	}
+	conds = append(conds, sexpr(s.Cond))
+	if diff := cmp.Diff([]string{"(x = 1)", "(x = 2)", "(x = 3)"}, conds); diff != "" {
+		t.Errorf("conditions differ: %v", diff)
+	}
+	if s.Else == nil {
+		t.Error("The last else is missing")
+	}
+
+	// else if is an error in Jack.
+	_, err = parseWith(src, false)
+	if list, ok := err.(ErrorList); !ok || list[0].Error() != "A.jack:1:64: expected symbol '{', found keyword 'if'" {
+		t.Errorf("got %v, want the error at else if", err)
+	}
+}
+
+func TestParser_Switch(t *testing.T) {
+	src := `class A { function void f(int x) {
+  switch (x + 1) {
+    case 1:
+      let x = 2;
+      break;
+    case -2:
+    default:
+      do A.g();
+      return;
+  }
+  return;
+} }`
+	c, err := parseWith(src, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, ok := c.Subroutines[0].Body.Stmts[0].(*ast.SwitchStmt)
+	if !ok {
+		t.Fatalf("got %T, want *ast.SwitchStmt", c.Subroutines[0].Body.Stmts[0])
+	}
+	got := []string{sexpr(s.Tag)}
+	for _, c := range s.Cases {
+		v := "default"
+		if c.Value != nil {
+			v = sexpr(c.Value)
+		}
+		got = append(got, fmt.Sprintf("%v:%v: %v %v", c.Pos().Line, c.Pos().Column, v, len(c.Body)))
+	}
+	want := []string{"(x + 1)", "3:5: 1 2", "6:5: (-2) 0", "7:5: default 2"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("switch differs: %v", diff)
+	}
+}
+
+func TestParser_SwitchErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []string
+	}{
+		{"statement before case", "switch (x) { let x = 1; }", []string{"A.jack:1:49: expected keyword 'case' or 'default', found keyword 'let'"}},
+		{"missing colon", "switch (x) { case 1 let x = 1; }", []string{"A.jack:1:56: expected symbol ':', found keyword 'let'"}},
+		{
+			name: "error in a clause",
+			src:  "switch (x) { case 1: let x = ; case 2: let = 1; }",
+			want: []string{"A.jack:1:65: expected term, found symbol ';'", "A.jack:1:79: expected identifier, found symbol '='"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseWith("class A { function void f(int x) { "+tt.src+" return; } }", true)
+			list, ok := err.(ErrorList)
+			if !ok {
+				t.Fatalf("got %v, want ErrorList", err)
+			}
+			got := []string{}
+			for _, e := range list {
+				got = append(got, e.Error())
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("errors differ: %v", diff)
+			}
+		})
+	}
+}
+
+// Lines with the surrounding spaces removed. TextComparer of Nand2Tetris ignores them.
+func xmlLines(s string) []string {
+	lines := []string{}
+	for _, l := range strings.Split(s, "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			lines = append(lines, l)
+		}
+	}
+	return lines
+}
+
+// The tokenizer and parser are shared with the syntax analyzer of chapter 10.
+// Compare the outputs with the answers of both chapters.
+func TestParser_XMLFiles(t *testing.T) {
+	srcPaths := []string{}
+	for _, pattern := range []string{"../test_parser/*/*.jack", "../../10/test/*/*.jack"} {
+		paths, err := filepath.Glob(pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		srcPaths = append(srcPaths, paths...)
+	}
+	if len(srcPaths) == 0 {
+		t.Fatal("No .jack files found")
+	}
+	for _, srcPath := range srcPaths {
+		t.Run(srcPath, func(t *testing.T) {
+			src, err := os.ReadFile(srcPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tk, _ := tokenizer.NewTokenizer(strings.NewReader(string(src)))
+			if err := tk.Tokenize(); err != nil {
+				t.Fatal(err)
+			}
+			class, err := NewParser(tk, srcPath).ParseClass()
+			if err != nil {
+				t.Fatal(err)
+			}
+			base := strings.TrimSuffix(srcPath, ".jack")
+			for ansPath, got := range map[string]string{base + "T.xml": tk.XML(), base + ".xml": ast.XML(class)} {
+				want, err := os.ReadFile(ansPath)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if diff := cmp.Diff(xmlLines(string(want)), xmlLines(got)); diff != "" {
+					t.Errorf("%v differs: %v", ansPath, diff)
+				}
+			}
+		})
+	}
+}
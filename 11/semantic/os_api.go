@@ -0,0 +1,84 @@
+package semantic
+
+// Signatures of the Jack OS. They must be same as the declarations in 12/*.jack.
+var osAPI = map[string][]Subroutine{
+	"Array": {
+		{Name: "new", Kind: "function", ReturnType: "Array", Params: []string{"int"}},
+		{Name: "dispose", Kind: "method", ReturnType: "void", Params: []string{}},
+	},
+	"Keyboard": {
+		{Name: "init", Kind: "function", ReturnType: "void", Params: []string{}},
+		{Name: "keyPressed", Kind: "function", ReturnType: "char", Params: []string{}},
+		{Name: "readChar", Kind: "function", ReturnType: "char", Params: []string{}},
+		{Name: "readLine", Kind: "function", ReturnType: "String", Params: []string{"String"}},
+		{Name: "readInt", Kind: "function", ReturnType: "int", Params: []string{"String"}},
+	},
+	"Math": {
+		{Name: "init", Kind: "function", ReturnType: "void", Params: []string{}},
+		{Name: "abs", Kind: "function", ReturnType: "int", Params: []string{"int"}},
+		{Name: "multiply", Kind: "function", ReturnType: "int", Params: []string{"int", "int"}},
+		{Name: "divide", Kind: "function", ReturnType: "int", Params: []string{"int", "int"}},
+		{Name: "sqrt", Kind: "function", ReturnType: "int", Params: []string{"int"}},
+		{Name: "max", Kind: "function", ReturnType: "int", Params: []string{"int", "int"}},
+		{Name: "min", Kind: "function", ReturnType: "int", Params: []string{"int", "int"}},
+	},
+	"Memory": {
+		{Name: "init", Kind: "function", ReturnType: "void", Params: []string{}},
+		{Name: "peek", Kind: "function", ReturnType: "int", Params: []string{"int"}},
+		{Name: "poke", Kind: "function", ReturnType: "void", Params: []string{"int", "int"}},
+		{Name: "alloc", Kind: "function", ReturnType: "int", Params: []string{"int"}},
+		{Name: "deAlloc", Kind: "function", ReturnType: "void", Params: []string{"Array"}, Disposes: []int{0}},
+	},
+	"Output": {
+		{Name: "init", Kind: "function", ReturnType: "void", Params: []string{}},
+		{Name: "initMap", Kind: "function", ReturnType: "void", Params: []string{}},
+		{Name: "create", Kind: "function", ReturnType: "void", Params: []string{"int", "int", "int", "int", "int", "int", "int", "int", "int", "int", "int", "int"}},
+		{Name: "getMap", Kind: "function", ReturnType: "Array", Params: []string{"char"}},
+		{Name: "moveCursor", Kind: "function", ReturnType: "void", Params: []string{"int", "int"}},
+		{Name: "printChar", Kind: "function", ReturnType: "void", Params: []string{"char"}},
+		{Name: "printString", Kind: "function", ReturnType: "void", Params: []string{"String"}},
+		{Name: "printInt", Kind: "function", ReturnType: "void", Params: []string{"int"}},
+		{Name: "println", Kind: "function", ReturnType: "void", Params: []string{}},
+		{Name: "backSpace", Kind: "function", ReturnType: "void", Params: []string{}},
+	},
+	"Screen": {
+		{Name: "init", Kind: "function", ReturnType: "void", Params: []string{}},
+		{Name: "clearScreen", Kind: "function", ReturnType: "void", Params: []string{}},
+		{Name: "setColor", Kind: "function", ReturnType: "void", Params: []string{"boolean"}},
+		{Name: "drawPixel", Kind: "function", ReturnType: "void", Params: []string{"int", "int"}},
+		{Name: "drawLine", Kind: "function", ReturnType: "void", Params: []string{"int", "int", "int", "int"}},
+		{Name: "drawRectangle", Kind: "function", ReturnType: "void", Params: []string{"int", "int", "int", "int"}},
+		{Name: "drawCircle", Kind: "function", ReturnType: "void", Params: []string{"int", "int", "int"}},
+	},
+	"String": {
+		{Name: "new", Kind: "constructor", ReturnType: "String", Params: []string{"int"}},
+		{Name: "dispose", Kind: "method", ReturnType: "void", Params: []string{}},
+		{Name: "length", Kind: "method", ReturnType: "int", Params: []string{}},
+		{Name: "charAt", Kind: "method", ReturnType: "char", Params: []string{"int"}},
+		{Name: "setCharAt", Kind: "method", ReturnType: "void", Params: []string{"int", "char"}},
+		{Name: "appendChar", Kind: "method", ReturnType: "String", Params: []string{"char"}},
+		{Name: "eraseLastChar", Kind: "method", ReturnType: "void", Params: []string{}},
+		{Name: "intValue", Kind: "method", ReturnType: "int", Params: []string{}},
+		{Name: "setInt", Kind: "method", ReturnType: "void", Params: []string{"int"}},
+		{Name: "newLine", Kind: "function", ReturnType: "char", Params: []string{}},
+		{Name: "backSpace", Kind: "function", ReturnType: "char", Params: []string{}},
+		{Name: "doubleQuote", Kind: "function", ReturnType: "char", Params: []string{}},
+	},
+	"Sys": {
+		{Name: "init", Kind: "function", ReturnType: "void", Params: []string{}},
+		{Name: "halt", Kind: "function", ReturnType: "void", Params: []string{}},
+		{Name: "wait", Kind: "function", ReturnType: "void", Params: []string{"int"}},
+		{Name: "error", Kind: "function", ReturnType: "void", Params: []string{"int"}},
+	},
+}
+
+// Return the OS classes. They have no path.
+func OSClasses() []*Class {
+	classes := make([]*Class, 0, len(osAPI))
+	for name, subs := range osAPI {
+		c := NewClass("", name)
+		c.Subroutines = subs
+		classes = append(classes, c)
+	}
+	return classes
+}
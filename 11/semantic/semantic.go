@@ -0,0 +1,254 @@
+package semantic
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Subroutine is the signature of a subroutine.
+type Subroutine struct {
+	Name       string
+	Kind       string   // constructor, function, or method
+	ReturnType string   // void or type name
+	Params     []string // Types of the parameters. The implicit this of a method isn't included.
+	Disposes   []int    // Indices of the parameters which the subroutine disposes
+	Pos        []int
+}
+
+// Call is a subroutine call site.
+type Call struct {
+	Caller     string // Name of the subroutine containing the call
+	CallerKind string // Kind of the subroutine containing the call
+	Class      string // Class name or type of the variable
+	Name       string
+	NArgs      int   // Number of the explicit arguments
+	OnVariable bool  // varName.foo()
+	Implicit   bool  // foo()
+	PooledArgs []int // Indices of the arguments which are string literals in the string pool
+	Pos        []int
+}
+
+// Problem is a semantic error or a warning found while compiling a class.
+type Problem struct {
+	Message string
+	Pos     []int
+}
+
+// Constant is a class constant or an enum member of the language extensions. Its value is computed at compile time.
+type Constant struct {
+	Class string
+	Name  string
+	Type  string // int, char or boolean
+	Value int
+	Pos   []int
+}
+
+// Constants are the constants of classes keyed by Class.name.
+type Constants map[string]Constant
+
+func (cs Constants) Add(c Constant) {
+	cs[c.Class+"."+c.Name] = c
+}
+
+func (cs Constants) Lookup(className string, name string) (Constant, bool) {
+	c, ok := cs[className+"."+name]
+	return c, ok
+}
+
+// Return the names of the constants of the class in lexical order.
+func (cs Constants) Names(className string) []string {
+	names := []string{}
+	for _, c := range cs {
+		if c.Class == className {
+			names = append(names, c.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Return the constants as Class.name=value lines in lexical order. Positions aren't included.
+func (cs Constants) String() string {
+	lines := []string{}
+	for key, c := range cs {
+		lines = append(lines, fmt.Sprintf("%v=%v %v\n", key, c.Type, c.Value))
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "")
+}
+
+// Class is what the compilation engine records about a class for the whole-program check.
+type Class struct {
+	Path        string
+	Name        string
+	Subroutines []Subroutine
+	Calls       []Call
+	Problems    []Problem
+	Warnings    []Problem // Problems which don't fail the compilation
+}
+
+func NewClass(path string, name string) *Class {
+	return &Class{Path: path, Name: name, Subroutines: []Subroutine{}, Calls: []Call{}, Problems: []Problem{}, Warnings: []Problem{}}
+}
+
+func (c *Class) Subroutine(name string) (*Subroutine, bool) {
+	for i := range c.Subroutines {
+		if c.Subroutines[i].Name == name {
+			return &c.Subroutines[i], true
+		}
+	}
+	return nil, false
+}
+
+func (c *Class) AddProblem(pos []int, format string, a ...interface{}) {
+	c.Problems = append(c.Problems, Problem{fmt.Sprintf(format, a...), pos})
+}
+
+func (c *Class) AddWarning(pos []int, format string, a ...interface{}) {
+	c.Warnings = append(c.Warnings, Problem{fmt.Sprintf(format, a...), pos})
+}
+
+// Error is a semantic error with the file path and position.
+type Error struct {
+	Path       string
+	Pos        []int
+	Message    string
+	Suggestion string // The name which may be meant instead of an undefined one
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("line=%v, column=%v: %v", e.Pos[0], e.Pos[1], e.Message)
+}
+
+// Program is a set of classes compiled together. It includes the OS classes.
+type Program struct {
+	classes map[string]*Class
+	// Whether all classes of the program are known. Undefined classes are reported only if it's true.
+	closed bool
+}
+
+func NewProgram(classes []*Class, closed bool) *Program {
+	p := &Program{map[string]*Class{}, closed}
+	for _, c := range OSClasses() {
+		p.classes[c.Name] = c
+	}
+	// User classes take precedence over the OS ones.
+	for _, c := range classes {
+		p.classes[c.Name] = c
+	}
+	return p
+}
+
+func (p *Program) Class(name string) (*Class, bool) {
+	c, ok := p.classes[name]
+	return c, ok
+}
+
+func isPrimitive(typeName string) bool {
+	switch typeName {
+	case "int", "char", "boolean":
+		return true
+	}
+	return false
+}
+
+// Check all calls and problems in the classes and return errors ordered by file and position.
+func (p *Program) Check(classes []*Class) []*Error {
+	errs := make([]*Error, 0)
+	for _, c := range classes {
+		for _, prob := range c.Problems {
+			errs = append(errs, &Error{c.Path, prob.Pos, prob.Message, ""})
+		}
+		for _, call := range c.Calls {
+			if msg, suggestion := p.checkCall(call); msg != "" {
+				errs = append(errs, &Error{c.Path, call.Pos, msg, suggestion})
+			}
+		}
+	}
+	SortErrors(errs)
+	return errs
+}
+
+// Return warnings for the pooled string literals passed to the subroutines which dispose of them, ordered by file and position.
+// A pooled literal is shared by every evaluation, so disposing of it breaks the later ones.
+func (p *Program) Warnings(classes []*Class) []*Error {
+	warnings := make([]*Error, 0)
+	for _, c := range classes {
+		for _, call := range c.Calls {
+			class, ok := p.classes[call.Class]
+			if !ok {
+				continue
+			}
+			sub, ok := class.Subroutine(call.Name)
+			if !ok {
+				continue
+			}
+			for _, i := range call.PooledArgs {
+				for _, d := range sub.Disposes {
+					if i == d {
+						msg := fmt.Sprintf("%v.%v disposes of argument %v, but the string literal is shared in the string pool", call.Class, call.Name, i+1)
+						warnings = append(warnings, &Error{c.Path, call.Pos, msg, ""})
+					}
+				}
+			}
+		}
+	}
+	SortErrors(warnings)
+	return warnings
+}
+
+// Sort errors by file and position.
+func SortErrors(errs []*Error) {
+	sort.SliceStable(errs, func(i, j int) bool {
+		if errs[i].Path != errs[j].Path {
+			return errs[i].Path < errs[j].Path
+		}
+		if errs[i].Pos[0] != errs[j].Pos[0] {
+			return errs[i].Pos[0] < errs[j].Pos[0]
+		}
+		return errs[i].Pos[1] < errs[j].Pos[1]
+	})
+}
+
+// Return an error message for the call, or empty if it's valid.
+// For an undefined class or subroutine, it also returns the similar name if any.
+func (p *Program) checkCall(call Call) (string, string) {
+	name := call.Class + "." + call.Name
+	if call.OnVariable && isPrimitive(call.Class) {
+		return fmt.Sprintf("Can't call %v on a variable of type %v", call.Name, call.Class), ""
+	}
+	class, ok := p.classes[call.Class]
+	if !ok {
+		if p.closed {
+			names := make([]string, 0, len(p.classes))
+			for name := range p.classes {
+				names = append(names, name)
+			}
+			return fmt.Sprintf("Class %v is not defined", call.Class), Suggest(call.Class, names)
+		}
+		return "", ""
+	}
+	sub, ok := class.Subroutine(call.Name)
+	if !ok {
+		names := make([]string, 0, len(class.Subroutines))
+		for _, s := range class.Subroutines {
+			names = append(names, s.Name)
+		}
+		return fmt.Sprintf("Subroutine %v is not defined", name), Suggest(call.Name, names)
+	}
+	switch {
+	case call.Implicit && sub.Kind == "method" && call.CallerKind == "function":
+		return fmt.Sprintf("Method %v can't be called from function %v without an object", name, call.Caller), ""
+	case call.Implicit && sub.Kind != "method":
+		return fmt.Sprintf("%v %v must be called as %v()", sub.Kind, call.Name, name), ""
+	case !call.Implicit && !call.OnVariable && sub.Kind == "method":
+		return fmt.Sprintf("Method %v is called on the class name %v", call.Name, call.Class), ""
+	case call.OnVariable && sub.Kind != "method":
+		return fmt.Sprintf("%v %v is called on a variable. Call it as %v()", sub.Kind, call.Name, name), ""
+	}
+	if call.NArgs != len(sub.Params) {
+		return fmt.Sprintf("%v takes %v argument(s), but %v given", name, len(sub.Params), call.NArgs), ""
+	}
+	return "", ""
+}
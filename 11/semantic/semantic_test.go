@@ -0,0 +1,309 @@
+package semantic_test
+
+import (
+	"compiler/compilation_engine"
+	"compiler/semantic"
+	"compiler/tokenizer"
+	"compiler/vmwriter"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func compileClass(t *testing.T, path string, src string) *semantic.Class {
+	tk, _ := tokenizer.NewTokenizer(strings.NewReader(src))
+	if err := tk.Tokenize(); err != nil {
+		t.Fatal(err)
+	}
+	ce := compilation_engine.NewCompilationEngine(tk, &vmwriter.VMWriter{})
+	if err := ce.Compile(); err != nil {
+		t.Fatal(err)
+	}
+	c := ce.Class()
+	c.Path = path
+	return c
+}
+
+func TestProgram_Check(t *testing.T) {
+	ball := `
+class Ball {
+	field int x;
+	constructor Ball new(int ax) { let x = ax; return this; }
+	method void move(int dx) { let x = x + dx; return; }
+	function int count() { return 0; }
+}`
+	tests := []struct {
+		name   string
+		src    string
+		closed bool
+		want   []string
+	}{
+		{
+			name:   "valid",
+			src:    "class Main { function void main() { var Ball b; let b = Ball.new(1); do b.move(2); do Output.printInt(Ball.count()); return; } }",
+			closed: true,
+			want:   []string{},
+		},
+		{
+			name:   "undefined class and subroutine",
+			src:    "class Main { function void main() { do Foo.bar(); do Ball.jump(); return; } }",
+			closed: true,
+			want:   []string{"Class Foo is not defined", "Subroutine Ball.jump is not defined"},
+		},
+		{
+			name:   "undefined class in an open program",
+			src:    "class Main { function void main() { do Foo.bar(); return; } }",
+			closed: false,
+			want:   []string{},
+		},
+		{
+			name:   "arity mismatch",
+			src:    "class Main { function void main() { var Ball b; let b = Ball.new(); do b.move(1, 2); do Math.max(1); return; } }",
+			closed: true,
+			want:   []string{"Ball.new takes 1 argument(s), but 0 given", "Ball.move takes 1 argument(s), but 2 given", "Math.max takes 2 argument(s), but 1 given"},
+		},
+		{
+			name:   "method on class name and function on variable",
+			src:    "class Main { function void main() { var Ball b; do Ball.move(1); do b.count(); return; } }",
+			closed: true,
+			want:   []string{"Method move is called on the class name Ball", "function count is called on a variable. Call it as Ball.count()"},
+		},
+		{
+			name:   "this and method in function",
+			src:    "class Main { method void m() { return; } function Main f() { do m(); return this; } }",
+			closed: true,
+			want:   []string{"Method Main.m can't be called from function f without an object", "this can't be used in function f"},
+		},
+		{
+			name:   "constructor not returning this",
+			src:    "class Main { constructor Main new() { return 0; } }",
+			closed: true,
+			want:   []string{"Constructor new must return this"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			classes := []*semantic.Class{compileClass(t, "Main.jack", tt.src), compileClass(t, "Ball.jack", ball)}
+			errs := semantic.NewProgram(classes, tt.closed).Check(classes)
+			got := make([]string, 0)
+			for _, err := range errs {
+				got = append(got, err.Message)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Check() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProgram_Warnings(t *testing.T) {
+	free := `
+class Free {
+	function void string(String s) { do s.dispose(); return; }
+	function void array(int n, Array a) { do Memory.deAlloc(a); return; }
+	function void keep(String s) { return; }
+}`
+	compile := func(path string, src string) *semantic.Class {
+		tk, _ := tokenizer.NewTokenizer(strings.NewReader(src))
+		if err := tk.Tokenize(); err != nil {
+			t.Fatal(err)
+		}
+		ce := compilation_engine.NewCompilationEngine(tk, &vmwriter.VMWriter{})
+		ce.EnableStringPool()
+		if err := ce.Compile(); err != nil {
+			t.Fatal(err)
+		}
+		c := ce.Class()
+		c.Path = path
+		return c
+	}
+	src := `class Main {
+		function void main() {
+			do Free.string("a");
+			do Free.array(1, "b");
+			do Free.keep("c");
+			do Memory.deAlloc("d");
+			return;
+		}
+	}`
+	classes := []*semantic.Class{compile("Main.jack", src), compile("Free.jack", free)}
+	got := make([]string, 0)
+	for _, w := range semantic.NewProgram(classes, true).Warnings(classes) {
+		got = append(got, w.Message)
+	}
+	want := []string{
+		"Free.string disposes of argument 1, but the string literal is shared in the string pool",
+		"Free.array disposes of argument 2, but the string literal is shared in the string pool",
+		"Memory.deAlloc disposes of argument 1, but the string literal is shared in the string pool",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Warnings() = %q, want %q", got, want)
+	}
+}
+
+// The built-in OS signatures must follow the OS sources.
+func TestOSClasses(t *testing.T) {
+	paths, _ := filepath.Glob("../../12/*.jack")
+	if len(paths) == 0 {
+		t.Skip("No OS sources")
+	}
+	builtin := map[string]*semantic.Class{}
+	for _, c := range semantic.OSClasses() {
+		builtin[c.Name] = c
+	}
+	for _, path := range paths {
+		src, _ := os.ReadFile(path)
+		c := compileClass(t, path, string(src))
+		osClass, ok := builtin[c.Name]
+		if !ok {
+			t.Errorf("No built-in class %v", c.Name)
+			continue
+		}
+		for _, sub := range c.Subroutines {
+			b, ok := osClass.Subroutine(sub.Name)
+			if !ok || b.Kind != sub.Kind || b.ReturnType != sub.ReturnType || !reflect.DeepEqual(b.Params, sub.Params) {
+				t.Errorf("Built-in %v.%v = %+v, want %+v", c.Name, sub.Name, b, sub)
+			}
+		}
+		if len(c.Subroutines) != len(osClass.Subroutines) {
+			t.Errorf("Built-in %v has %v subroutines, want %v", c.Name, len(osClass.Subroutines), len(c.Subroutines))
+		}
+	}
+}
+
+// Make a compilation engine with the language extensions.
+func newExtCompilationEngine(src string) *compilation_engine.CompilationEngine {
+	tk, _ := tokenizer.NewTokenizer(strings.NewReader(src))
+	tk.EnableExtensions()
+	tk.Tokenize()
+	ce := compilation_engine.NewCompilationEngine(tk, &vmwriter.VMWriter{})
+	ce.EnableExtensions()
+	return ce
+}
+
+func TestTypeChecker(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []string
+	}{
+		{
+			name: "valid",
+			src: `class Main {
+				function void main() {
+					var int i; var char c; var boolean b; var Array a; var String s;
+					let c = 65; let i = c + 1; let b = (i < 10) & ~b; let a = Array.new(3);
+					let a[i] = s; let s = "abc"; let s = null;
+					if (b) { do Output.printChar(c); }
+					while (i > 0) { let i = i - 1; }
+					return;
+				}
+				function int f() { return Math.max(1, 2); }
+			}`,
+			want: []string{},
+		},
+		{
+			name: "assignment and return",
+			src: `class Main {
+				function int f() { var int i; var boolean b; let i = true; let b = "s"; return b; }
+				function void g() { return 1; }
+				function String h() { return; }
+			}`,
+			want: []string{
+				"Can't assign boolean to i of type int",
+				"Can't assign String to b of type boolean",
+				"f must return int, got boolean",
+				"Void subroutine g can't return a value",
+				"h must return String",
+			},
+		},
+		{
+			name: "conditions and operators",
+			src: `class Main {
+				function void f() {
+					var int i; var boolean b;
+					if (i) { let i = b + 1; }
+					while (b & i) { let b = -b; }
+					return;
+				}
+			}`,
+			want: []string{
+				"Condition of if must be boolean, got int",
+				"Operator + needs int operands, got boolean and int",
+				"Operator & needs both boolean or both int operands, got boolean and int",
+				"Operator - can't be applied to boolean",
+			},
+		},
+		{
+			name: "indexing and arguments",
+			src: `class Main {
+				function void f() {
+					var int i; var String s;
+					let i = s[0]; let s[i] = 1;
+					do Output.printString(i); do Output.printInt(s);
+					return;
+				}
+			}`,
+			want: []string{
+				"s of type String can't be indexed. Only Array can be",
+				"s of type String can't be indexed. Only Array can be",
+				"Argument 1 of Output.printString must be String, got int",
+				"Argument 1 of Output.printInt must be int, got String",
+			},
+		},
+		{
+			name: "language extensions",
+			src: `class Main {
+				function void f() {
+					var int i; var boolean b;
+					for (let i = 0; i; let i = b) { }
+					if (b) { } else if (i) { }
+					switch (b) { case true: return; case 2: return; }
+					switch (i) { case 1: return; }
+					let b = 'A'; let i = 0x10 + 'a';
+					let i += 'a'; let b += 1; let b++; let i |= b;
+					let b = b && (i < 1) || true; let b = i && b; let i = b || b;
+					return;
+				}
+			}`,
+			want: []string{
+				"Condition of for must be boolean, got int",
+				"Can't assign boolean to i of type int",
+				"Condition of if must be boolean, got int",
+				"Value of switch must be int or char, got boolean",
+				"Value of switch must be int or char, got boolean",
+				"Can't assign char to b of type boolean",
+				"Operator + needs int operands, got boolean and int",
+				"Can't assign int to b of type boolean",
+				"Operator ++ can't be applied to boolean",
+				"Operator | needs both boolean or both int operands, got int and boolean",
+				"Operator && needs boolean operands, got int and boolean",
+				"Can't assign boolean to i of type int",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first := newExtCompilationEngine(tt.src)
+			if err := first.Compile(); err != nil {
+				t.Fatal(err)
+			}
+			p := semantic.NewProgram([]*semantic.Class{first.Class()}, true)
+			ce := newExtCompilationEngine(tt.src)
+			tc := semantic.NewTypeChecker(p, "Main.jack")
+			ce.EnableTypeCheck(tc)
+			if err := ce.Compile(); err != nil {
+				t.Fatal(err)
+			}
+			got := make([]string, 0)
+			for _, err := range tc.Errors() {
+				got = append(got, err.Message)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Errors() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package semantic
+
+import (
+	"sort"
+	"strings"
+)
+
+// Levenshtein distance of the strings
+func distance(a string, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func min(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
+
+// Suggest returns the candidate which is the most similar to the misspelled name,
+// or an empty string if no candidate is similar enough.
+func Suggest(name string, candidates []string) string {
+	sorted := append([]string{}, candidates...)
+	sort.Strings(sorted)
+	best := ""
+	// Allow a typo per 3 characters.
+	bestDistance := len(name)/3 + 1
+	for _, c := range sorted {
+		if c == name {
+			continue
+		}
+		d := distance(strings.ToLower(name), strings.ToLower(c))
+		if d < bestDistance || d == 0 {
+			best, bestDistance = c, d
+		}
+	}
+	return best
+}
@@ -0,0 +1,24 @@
+package semantic
+
+import "testing"
+
+func TestSuggest(t *testing.T) {
+	tests := []struct {
+		name       string
+		candidates []string
+		want       string
+	}{
+		{"cout", []string{"count", "total"}, "count"},
+		{"Outpt", []string{"Math", "Output", "Screen"}, "Output"},
+		{"output", []string{"Output"}, "Output"},
+		{"x", []string{"y", "z"}, ""},
+		{"total", []string{"count"}, ""},
+		{"pritnInt", []string{"printChar", "printInt", "println"}, "printInt"},
+		{"a", nil, ""},
+	}
+	for _, tt := range tests {
+		if got := Suggest(tt.name, tt.candidates); got != tt.want {
+			t.Errorf("Suggest(%q, %q) = %q, want %q", tt.name, tt.candidates, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package semantic
+
+import (
+	"fmt"
+)
+
+// Types of expressions other than the declared types
+const (
+	UNKNOWN = ""     // Type can't be determined, e.g. an element of Array. It's compatible with any types.
+	NULL    = "null" // Type of null
+	VOID    = "void" // Type of a call of void subroutine
+)
+
+func isNumeric(t string) bool {
+	return t == "int" || t == "char"
+}
+
+// Whether a value of type src can be assigned to a variable of type dst.
+// char and int are interchangeable, and Array can hold any address as the OS does.
+func Assignable(dst string, src string) bool {
+	switch {
+	case dst == UNKNOWN || src == UNKNOWN:
+		return true
+	case src == VOID || dst == VOID:
+		return false
+	case dst == src:
+		return true
+	case isNumeric(dst) && isNumeric(src):
+		return true
+	case src == NULL:
+		return dst != "boolean" && dst != "char"
+	case dst == "Array" || src == "Array":
+		return dst != "boolean" && src != "boolean"
+	}
+	return false
+}
+
+// TypeChecker checks types of a class with the signatures in the program.
+// All methods can be called on nil, which means type checking is disabled. Then they return UNKNOWN.
+type TypeChecker struct {
+	program *Program
+	path    string
+	errs    []*Error
+}
+
+func NewTypeChecker(program *Program, path string) *TypeChecker {
+	return &TypeChecker{program, path, []*Error{}}
+}
+
+func (tc *TypeChecker) Errors() []*Error {
+	if tc == nil {
+		return nil
+	}
+	return tc.errs
+}
+
+func (tc *TypeChecker) errorf(pos []int, format string, a ...interface{}) {
+	tc.errs = append(tc.errs, &Error{tc.path, pos, fmt.Sprintf(format, a...), ""})
+}
+
+// let varName = expression;
+func (tc *TypeChecker) Assign(pos []int, varName string, varType string, exprType string) {
+	if tc == nil {
+		return
+	}
+	if !Assignable(varType, exprType) {
+		tc.errorf(pos, "Can't assign %v to %v of type %v", exprType, varName, varType)
+	}
+}
+
+// return expression; exprType is VOID if the expression is omitted.
+func (tc *TypeChecker) Return(pos []int, subroutineName string, returnType string, exprType string) {
+	if tc == nil {
+		return
+	}
+	if returnType == VOID && exprType != VOID {
+		tc.errorf(pos, "Void subroutine %v can't return a value", subroutineName)
+	} else if returnType != VOID && exprType == VOID {
+		tc.errorf(pos, "%v must return %v", subroutineName, returnType)
+	} else if returnType != VOID && !Assignable(returnType, exprType) {
+		tc.errorf(pos, "%v must return %v, got %v", subroutineName, returnType, exprType)
+	}
+}
+
+// Condition of if, while and for
+func (tc *TypeChecker) Condition(pos []int, statement string, exprType string) {
+	if tc == nil {
+		return
+	}
+	if exprType != UNKNOWN && exprType != "boolean" {
+		tc.errorf(pos, "Condition of %v must be boolean, got %v", statement, exprType)
+	}
+}
+
+// Value of switch and its cases
+func (tc *TypeChecker) Switch(pos []int, exprType string) {
+	if tc == nil {
+		return
+	}
+	if exprType != UNKNOWN && !isNumeric(exprType) {
+		tc.errorf(pos, "Value of switch must be int or char, got %v", exprType)
+	}
+}
+
+// Return the type of the binary operation.
+func (tc *TypeChecker) BinaryOp(pos []int, op string, left string, right string) string {
+	if tc == nil {
+		return UNKNOWN
+	}
+	// Array is an address, so pointer arithmetic on it is allowed.
+	numeric := func(t string) bool { return t == UNKNOWN || isNumeric(t) || t == "Array" }
+	switch op {
+	case "+", "-", "*", "/":
+		if !numeric(left) || !numeric(right) {
+			tc.errorf(pos, "Operator %v needs int operands, got %v and %v", op, left, right)
+		}
+		return "int"
+	case "<", ">":
+		if !numeric(left) || !numeric(right) {
+			tc.errorf(pos, "Operator %v needs int operands, got %v and %v", op, left, right)
+		}
+		return "boolean"
+	case "=":
+		if !Assignable(left, right) && !Assignable(right, left) {
+			tc.errorf(pos, "Can't compare %v with %v", left, right)
+		}
+		return "boolean"
+	case "&", "|":
+		if left == UNKNOWN {
+			return right
+		} else if right == UNKNOWN {
+			return left
+		} else if left == "boolean" && right == "boolean" {
+			return "boolean"
+		} else if isNumeric(left) && isNumeric(right) {
+			return "int"
+		}
+		tc.errorf(pos, "Operator %v needs both boolean or both int operands, got %v and %v", op, left, right)
+	case "&&", "||":
+		boolean := func(t string) bool { return t == UNKNOWN || t == "boolean" }
+		if !boolean(left) || !boolean(right) {
+			tc.errorf(pos, "Operator %v needs boolean operands, got %v and %v", op, left, right)
+		}
+		return "boolean"
+	}
+	return UNKNOWN
+}
+
+// Return the type of the unary operation.
+func (tc *TypeChecker) UnaryOp(pos []int, op string, operand string) string {
+	if tc == nil {
+		return UNKNOWN
+	}
+	switch {
+	case operand == UNKNOWN:
+		return UNKNOWN
+	case op == "-" && isNumeric(operand):
+		return "int"
+	case (op == "++" || op == "--") && (isNumeric(operand) || operand == "Array"):
+		// Increment and decrement of the language extension. Array is incremented as an address.
+		return operand
+	case op == "~" && (operand == "boolean" || isNumeric(operand)):
+		return operand
+	}
+	tc.errorf(pos, "Operator %v can't be applied to %v", op, operand)
+	return UNKNOWN
+}
+
+// varName[expression]. Return the type of the element.
+func (tc *TypeChecker) Index(pos []int, varName string, varType string, indexType string) string {
+	if tc == nil {
+		return UNKNOWN
+	}
+	if varType != "Array" {
+		tc.errorf(pos, "%v of type %v can't be indexed. Only Array can be", varName, varType)
+	}
+	if indexType != UNKNOWN && !isNumeric(indexType) {
+		tc.errorf(pos, "Index of %v must be int, got %v", varName, indexType)
+	}
+	return UNKNOWN
+}
+
+// Check the arguments of the call and return the type of the return value.
+func (tc *TypeChecker) Call(pos []int, className string, subroutineName string, argTypes []string) string {
+	if tc == nil {
+		return UNKNOWN
+	}
+	class, ok := tc.program.Class(className)
+	if !ok {
+		return UNKNOWN
+	}
+	sub, ok := class.Subroutine(subroutineName)
+	if !ok {
+		return UNKNOWN
+	}
+	// Arity is checked by Program.Check.
+	for i := 0; i < len(argTypes) && i < len(sub.Params); i++ {
+		if !Assignable(sub.Params[i], argTypes[i]) {
+			tc.errorf(pos, "Argument %v of %v.%v must be %v, got %v", i+1, className, subroutineName, sub.Params[i], argTypes[i])
+		}
+	}
+	return sub.ReturnType
+}
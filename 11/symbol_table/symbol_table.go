@@ -7,6 +7,26 @@ type Entry struct {
 	varNum  int
 }
 
+// Name of the variable
+func (e Entry) Name() string {
+	return e.varName
+}
+
+// Type of the variable: int, boolean, char or a class name
+func (e Entry) Type() string {
+	return e.varType
+}
+
+// Kind of the variable: static, field, argument or var
+func (e Entry) Kind() string {
+	return e.varKind
+}
+
+// Index of the variable in its segment
+func (e Entry) Index() int {
+	return e.varNum
+}
+
 type SymbolTable struct {
 	name        string
 	entries     []Entry
@@ -53,6 +73,25 @@ func (s *SymbolTable) IndexOf(varName string) (int, bool) {
 	return -1, false
 }
 
+// Return the entry of the variable.
+func (s *SymbolTable) Lookup(varName string) (Entry, bool) {
+	for _, e := range s.entries {
+		if e.varName == varName {
+			return e, true
+		}
+	}
+	return Entry{}, false
+}
+
 func (s *SymbolTable) Name() string {
 	return s.name
 }
+
+// Return the names of the variables in the order of definition.
+func (s *SymbolTable) Names() []string {
+	names := make([]string, 0, len(s.entries))
+	for _, e := range s.entries {
+		names = append(names, e.varName)
+	}
+	return names
+}
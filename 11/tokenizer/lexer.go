@@ -0,0 +1,391 @@
+package tokenizer
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// Position is a location in a source. Offset is in bytes from the head of the source and starts with 0.
+// Line and Column start with 1. Column is counted in bytes.
+type Position struct {
+	Offset int
+	Line   int
+	Column int
+}
+
+// Item is a token and its extent in the source.
+type Item struct {
+	Token
+	Start    Position
+	End      Position   // Position just after the last character of the token
+	Comments []*Comment // Comments between the previous token and this token
+}
+
+// Return the doc comment /** */ of the declaration starting with this token.
+// It's the last comment before the token. nil if it isn't a doc comment.
+func (i *Item) Doc() *Comment {
+	if n := len(i.Comments); n > 0 && i.Comments[n-1].IsDoc() {
+		return i.Comments[n-1]
+	}
+	return nil
+}
+
+// Comment is a comment in a source: // to the end of the line, /* */ or /** */.
+// Text includes the delimiters but not the line break.
+type Comment struct {
+	Text  string
+	Start Position
+	End   Position
+}
+
+// Whether the comment is a doc comment /** */
+func (c *Comment) IsDoc() bool {
+	return strings.HasPrefix(c.Text, "/**") && c.Text != "/**/"
+}
+
+// LexError is a lexical error at a position of a source.
+type LexError struct {
+	Pos Position
+	Msg string
+}
+
+func (e *LexError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Pos.Line, e.Pos.Column, e.Msg)
+}
+
+// LexErrorList is the lexical errors of a source in the order they are found.
+type LexErrorList []*LexError
+
+func (l LexErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", l[0], len(l)-1)
+}
+
+const eof = -1
+
+// Lexer reads tokens from a source one by one.
+type Lexer struct {
+	r        *bufio.Reader
+	pos      Position   // Position of the next character
+	err      error      // Read error other than io.EOF. The lexer stops after it.
+	comments []*Comment // Comments read after the last token
+	ext      bool       // Whether the language extensions are enabled
+}
+
+func NewLexer(r io.Reader) *Lexer {
+	return &Lexer{r: bufio.NewReader(r), pos: Position{0, 1, 1}}
+}
+
+// Read the keywords of the language extensions such as for and break.
+// They are identifiers in Jack.
+func (l *Lexer) EnableExtensions() {
+	l.ext = true
+}
+
+// Read the next character and move the position. It returns eof at the end of the source.
+func (l *Lexer) read() rune {
+	if l.err != nil {
+		return eof
+	}
+	c, size, err := l.r.ReadRune()
+	if err != nil {
+		if !errors.Is(err, io.EOF) {
+			l.err = err
+		}
+		return eof
+	}
+	l.pos.Offset += size
+	if c == '\n' {
+		l.pos.Line++
+		l.pos.Column = 1
+	} else {
+		l.pos.Column += size
+	}
+	return c
+}
+
+// Return the next character without reading it.
+func (l *Lexer) peek() rune {
+	if l.err != nil {
+		return eof
+	}
+	c, _, err := l.r.ReadRune()
+	if err != nil {
+		return eof
+	}
+	l.r.UnreadRune()
+	return c
+}
+
+func isLetter(c rune) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '_'
+}
+
+func isDigit(c rune) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isSpace(c rune) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f'
+}
+
+func isSymbol(c rune) bool {
+	return c < utf8.RuneSelf && strings.ContainsRune("{}()[].,;+-*/&|<>=~", c)
+}
+
+// Symbols of the language extensions
+func isExtSymbol(c rune) bool {
+	return c == ':'
+}
+
+// Whether the two characters are an operator of the compound assignment, the increment and decrement,
+// or the logical operators && and ||.
+// Note that x--1 is read as x -- 1, not x - -1, with the language extensions.
+func isCompoundSymbol(c rune, next rune) bool {
+	return strings.ContainsRune("+-*/&|", c) && next == '=' || strings.ContainsRune("+-&|", c) && next == c
+}
+
+func isKeyword(s string) bool {
+	switch s {
+	case CLASS, CONSTRUCTOR, FUNCTION, METHOD, FIELD, STATIC, VAR, INT, CHAR, BOOLEAN, VOID, TRUE, FALSE, NULL, THIS, LET, DO, IF, ELSE, WHILE, RETURN:
+		return true
+	}
+	return false
+}
+
+func isExtKeyword(s string) bool {
+	switch s {
+	case FOR, BREAK, CONTINUE, SWITCH, CASE, DEFAULT, CONST, ENUM:
+		return true
+	}
+	return false
+}
+
+// Return the next token. It returns io.EOF at the end of the source.
+// A lexical error is returned as *LexError. The lexer can continue after it.
+func (l *Lexer) Next() (*Item, error) {
+	if err := l.skipSpacesAndComments(); err != nil {
+		return nil, err
+	}
+	start := l.pos
+	c := l.read()
+	pos := []int{start.Line, start.Column}
+	var t Token
+	switch {
+	case c == eof:
+		if l.err != nil {
+			return nil, l.err
+		}
+		return nil, io.EOF
+	case c == '"':
+		literal, value, err := l.stringConstant(start)
+		if err != nil {
+			return nil, err
+		}
+		t = NewEscapedStrConst(literal, value, pos)
+	case c == '\'' && l.ext:
+		literal, value, err := l.charConstant(start)
+		if err != nil {
+			return nil, err
+		}
+		t = NewCharConst(literal, value, pos)
+	case c == '0' && l.ext && strings.ContainsRune("xXbB", l.peek()):
+		s := l.readWhile(c, func(c rune) bool { return isLetter(c) || isDigit(c) })
+		ic, err := NewRadixIntConst(s, pos)
+		if err != nil {
+			return nil, &LexError{start, err.Error()}
+		}
+		t = ic
+	case isLetter(c):
+		s := l.readWhile(c, func(c rune) bool { return isLetter(c) || isDigit(c) })
+		if isKeyword(s) || l.ext && isExtKeyword(s) {
+			t = NewKeyword(s, pos)
+		} else {
+			t = NewIdentifier(s, pos)
+		}
+	case isDigit(c):
+		s := l.readWhile(c, isDigit)
+		ic, err := NewIntConst(s, pos)
+		if err != nil {
+			return nil, &LexError{start, err.Error()}
+		}
+		t = ic
+	case isSymbol(c) || l.ext && isExtSymbol(c):
+		s := string(c)
+		if l.ext && isCompoundSymbol(c, l.peek()) {
+			s += string(l.read())
+		}
+		t = NewSymbol(s, pos)
+	default:
+		return nil, &LexError{start, fmt.Sprintf("illegal character %q", c)}
+	}
+	item := &Item{t, start, l.pos, l.comments}
+	l.comments = nil
+	return item, nil
+}
+
+// Return the comments read after the last token. They are the comments at the end of the source after Next returns io.EOF.
+func (l *Lexer) Comments() []*Comment {
+	return l.comments
+}
+
+// Read the characters following the first one while they meet the condition.
+func (l *Lexer) readWhile(first rune, cond func(rune) bool) string {
+	var b strings.Builder
+	b.WriteRune(first)
+	for cond(l.peek()) {
+		b.WriteRune(l.read())
+	}
+	return b.String()
+}
+
+// Read a string constant after the opening double quote. It returns the string as written and
+// the string with the escape sequences replaced. The escape sequences are read only with the language extensions.
+// A string constant can't contain line breaks. It must be closed in the same line.
+func (l *Lexer) stringConstant(start Position) (string, string, error) {
+	var literal, value strings.Builder
+	var err error // The first bad escape sequence. The string is read to the end after it.
+	for {
+		switch c := l.peek(); {
+		case c == eof || c == '\n' || c == '\r':
+			return "", "", &LexError{start, "unterminated string constant"}
+		case c == '"':
+			l.read()
+			return literal.String(), value.String(), err
+		case c == '\\' && l.ext:
+			r, escErr := l.escape(&literal)
+			if escErr != nil && err == nil {
+				err = escErr
+			}
+			value.WriteRune(r)
+		default:
+			l.read()
+			literal.WriteRune(c)
+			value.WriteRune(c)
+		}
+	}
+}
+
+// Codes of the escape sequences in the Hack character set
+var escapes = map[rune]rune{
+	'n':  128, // Newline
+	'\\': '\\',
+	'"':  '"',
+	'\'': '\'',
+}
+
+// Read an escape sequence \n, \\, \" or \' and write it to literal. It returns the character of it.
+func (l *Lexer) escape(literal *strings.Builder) (rune, error) {
+	start := l.pos
+	literal.WriteRune(l.read())
+	c := l.peek()
+	if c == eof || c == '\n' || c == '\r' {
+		// The caller reports that the constant isn't closed.
+		return 0, nil
+	}
+	l.read()
+	literal.WriteRune(c)
+	r, ok := escapes[c]
+	if !ok {
+		return 0, &LexError{start, fmt.Sprintf("unknown escape sequence \\%c", c)}
+	}
+	return r, nil
+}
+
+// Read a character constant after the opening single quote. It returns the character as written and its code.
+// The character must be in the Hack character set, printable ASCII characters or an escape sequence.
+func (l *Lexer) charConstant(start Position) (string, int, error) {
+	var literal strings.Builder
+	codes := []rune{}
+	var err error // The first error. The constant is read to the end after it.
+	for {
+		pos := l.pos
+		switch c := l.peek(); {
+		case c == eof || c == '\n' || c == '\r':
+			return "", 0, &LexError{start, "unterminated character constant"}
+		case c == '\'':
+			l.read()
+			switch {
+			case err != nil:
+				return "", 0, err
+			case len(codes) == 0:
+				return "", 0, &LexError{start, "empty character constant"}
+			case len(codes) > 1:
+				return "", 0, &LexError{start, fmt.Sprintf("character constant must be one character: '%v'", literal.String())}
+			}
+			return literal.String(), int(codes[0]), nil
+		case c == '\\':
+			r, escErr := l.escape(&literal)
+			if escErr != nil && err == nil {
+				err = escErr
+			}
+			codes = append(codes, r)
+		default:
+			l.read()
+			literal.WriteRune(c)
+			if (c < ' ' || c > '~') && err == nil {
+				err = &LexError{pos, fmt.Sprintf("character %q isn't in the Hack character set", c)}
+			}
+			codes = append(codes, c)
+		}
+	}
+}
+
+// Skip white spaces and read comments: // to the end of the line, /* */ and /** */.
+func (l *Lexer) skipSpacesAndComments() error {
+	for {
+		c := l.peek()
+		switch {
+		case isSpace(c):
+			l.read()
+		case c == '/':
+			// Look at the character after the slash.
+			next, err := l.r.Peek(2)
+			if err != nil || (next[1] != '/' && next[1] != '*') {
+				return nil
+			}
+			start := l.pos
+			var b strings.Builder
+			b.WriteRune(l.read())
+			if c := l.read(); c == '/' {
+				b.WriteRune(c)
+				for c := l.peek(); c != '\n' && c != eof; c = l.peek() {
+					b.WriteRune(l.read())
+				}
+			} else {
+				b.WriteRune(c)
+				if !l.readBlockComment(&b) {
+					return &LexError{start, "unterminated comment"}
+				}
+			}
+			text := strings.TrimSuffix(b.String(), "\r")
+			l.comments = append(l.comments, &Comment{text, start, l.pos})
+		default:
+			return nil
+		}
+	}
+}
+
+// Read a block comment after "/*" to b. It returns false if the comment isn't closed.
+func (l *Lexer) readBlockComment(b *strings.Builder) bool {
+	for prev := rune(0); ; {
+		c := l.read()
+		if c == eof {
+			return false
+		}
+		b.WriteRune(c)
+		if prev == '*' && c == '/' {
+			return true
+		}
+		prev = c
+	}
+}
@@ -0,0 +1,367 @@
+package tokenizer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Read all items and errors from the lexer.
+func lexAll(src string) ([]*Item, []error) {
+	return lexAllWith(src, false)
+}
+
+// Read all items and errors from the lexer with the language extensions if ext is true.
+func lexAllWith(src string, ext bool) ([]*Item, []error) {
+	l := NewLexer(strings.NewReader(src))
+	if ext {
+		l.EnableExtensions()
+	}
+	items := []*Item{}
+	errs := []error{}
+	for {
+		item, err := l.Next()
+		if errors.Is(err, io.EOF) {
+			return items, errs
+		} else if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		items = append(items, item)
+	}
+}
+
+func TestLexer_Next(t *testing.T) {
+	type span struct {
+		token      string
+		start, end Position
+	}
+	tests := []struct {
+		name string
+		src  string
+		want []span
+	}{
+		{
+			name: "offsets and end positions",
+			src:  "let x = 12;",
+			want: []span{
+				{"let", Position{0, 1, 1}, Position{3, 1, 4}},
+				{"x", Position{4, 1, 5}, Position{5, 1, 6}},
+				{"=", Position{6, 1, 7}, Position{7, 1, 8}},
+				{"12", Position{8, 1, 9}, Position{10, 1, 11}},
+				{";", Position{10, 1, 11}, Position{11, 1, 12}},
+			},
+		},
+		{
+			name: "CRLF and comments",
+			src:  "a // b\r\n/* c\r\n */ d /** e */f",
+			want: []span{
+				{"a", Position{0, 1, 1}, Position{1, 1, 2}},
+				{"d", Position{18, 3, 5}, Position{19, 3, 6}},
+				{"f", Position{28, 3, 15}, Position{29, 3, 16}},
+			},
+		},
+		{
+			name: "string constant",
+			src:  "x\n  \"a b\";",
+			want: []span{
+				{"x", Position{0, 1, 1}, Position{1, 1, 2}},
+				{"a b", Position{4, 2, 3}, Position{9, 2, 8}},
+				{";", Position{9, 2, 8}, Position{10, 2, 9}},
+			},
+		},
+		{
+			name: "division isn't a comment",
+			src:  "a/b",
+			want: []span{
+				{"a", Position{0, 1, 1}, Position{1, 1, 2}},
+				{"/", Position{1, 1, 2}, Position{2, 1, 3}},
+				{"b", Position{2, 1, 3}, Position{3, 1, 4}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, errs := lexAll(tt.src)
+			if len(errs) > 0 {
+				t.Fatal(errs)
+			}
+			got := make([]span, len(items))
+			for i, item := range items {
+				got[i] = span{item.String(), item.Start, item.End}
+				if pos := []int{item.Start.Line, item.Start.Column}; !reflect.DeepEqual(item.Pos(), pos) {
+					t.Errorf("Pos() = %v, want %v", item.Pos(), pos)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Next() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLexer_Errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		src        string
+		wantTokens []string
+		wantErrs   []string
+	}{
+		{"illegal characters", "a @ b#\n$", []string{"a", "b"}, []string{"1:3: illegal character '@'", "1:6: illegal character '#'", "2:1: illegal character '$'"}},
+		{"non-ASCII character", "x = あ;", []string{"x", "=", ";"}, []string{"1:5: illegal character 'あ'"}},
+		{"unterminated string", "let s = \"abc;\n", []string{"let", "s", "="}, []string{"1:9: unterminated string constant"}},
+		{"multi-line string", "\"abc\r\ndef\" x", []string{"def"}, []string{"1:1: unterminated string constant", "2:4: unterminated string constant"}},
+		{"unterminated comment", "a /** b\n c *", []string{"a"}, []string{"1:3: unterminated comment"}},
+		{"too large integer", "32769 1", []string{"1"}, []string{"1:1: Integer constant must be in [0, 32767], or 32768 only as -32768: 32769"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, errs := lexAll(tt.src)
+			gotTokens := []string{}
+			for _, item := range items {
+				gotTokens = append(gotTokens, item.String())
+			}
+			gotErrs := []string{}
+			for _, err := range errs {
+				gotErrs = append(gotErrs, err.Error())
+			}
+			if !reflect.DeepEqual(gotTokens, tt.wantTokens) {
+				t.Errorf("tokens = %q, want %q", gotTokens, tt.wantTokens)
+			}
+			if !reflect.DeepEqual(gotErrs, tt.wantErrs) {
+				t.Errorf("errors = %q, want %q", gotErrs, tt.wantErrs)
+			}
+		})
+	}
+}
+
+func TestLexer_Comments(t *testing.T) {
+	src := "// license\r\n/** Doc of A. */\nclass A {\n  /* not doc */ /** Doc of f.\n   */ function void f() {} // trailing\n  /** not attached */ // line\n  field int x; }"
+	tests := []struct {
+		token    string
+		comments []string
+		doc      string
+	}{
+		{"class", []string{"// license", "/** Doc of A. */"}, "/** Doc of A. */"},
+		{"A", nil, ""},
+		{"{", nil, ""},
+		{"function", []string{"/* not doc */", "/** Doc of f.\n   */"}, "/** Doc of f.\n   */"},
+		{"void", nil, ""},
+		{"f", nil, ""},
+		{"(", nil, ""},
+		{")", nil, ""},
+		{"{", nil, ""},
+		{"}", nil, ""},
+		{"field", []string{"// trailing", "/** not attached */", "// line"}, ""},
+		{"int", nil, ""},
+		{"x", nil, ""},
+		{";", nil, ""},
+		{"}", nil, ""},
+	}
+	items, errs := lexAll(src)
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	if len(items) != len(tests) {
+		t.Fatalf("got %v tokens, want %v", len(items), len(tests))
+	}
+	for i, tt := range tests {
+		item := items[i]
+		var comments []string
+		for _, c := range item.Comments {
+			comments = append(comments, c.Text)
+		}
+		doc := ""
+		if d := item.Doc(); d != nil {
+			doc = d.Text
+		}
+		if item.String() != tt.token || !reflect.DeepEqual(comments, tt.comments) || doc != tt.doc {
+			t.Errorf("%v: got %q %q doc=%q, want %q %q doc=%q", i, item.String(), comments, doc, tt.token, tt.comments, tt.doc)
+		}
+	}
+	if c := items[0].Comments[1]; c.Start != (Position{12, 2, 1}) || c.End != (Position{28, 2, 17}) {
+		t.Errorf("extent of the doc comment = %v-%v", c.Start, c.End)
+	}
+}
+
+func TestTokenizer_TrailingComments(t *testing.T) {
+	tk, _ := NewTokenizer(strings.NewReader("class A { } // end\n/* last */\n"))
+	if err := tk.Tokenize(); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range tk.TrailingComments() {
+		got = append(got, c.Text)
+	}
+	if want := []string{"// end", "/* last */"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("TrailingComments() = %q, want %q", got, want)
+	}
+}
+
+func TestTokenizer_Tokenize_Errors(t *testing.T) {
+	tk, _ := NewTokenizer(strings.NewReader("class A { @ }\n\"abc"))
+	err := tk.Tokenize()
+	errs, ok := err.(LexErrorList)
+	if !ok || len(errs) != 2 {
+		t.Fatalf("Tokenize() = %v, want 2 errors", err)
+	}
+	if want := "1:11: illegal character '@' (and 1 more errors)"; err.Error() != want {
+		t.Errorf("Error() = %v, want %v", err, want)
+	}
+}
+
+func TestLexer_Extensions(t *testing.T) {
+	src := "for break continue switch case default const enum while"
+	tests := []struct {
+		ext  bool
+		want []string
+	}{
+		{false, []string{"identifier for", "identifier break", "identifier continue", "identifier switch", "identifier case", "identifier default", "identifier const", "identifier enum", "keyword while"}},
+		{true, []string{"keyword for", "keyword break", "keyword continue", "keyword switch", "keyword case", "keyword default", "keyword const", "keyword enum", "keyword while"}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("ext=%v", tt.ext), func(t *testing.T) {
+			tk, _ := NewTokenizer(strings.NewReader(src))
+			if tt.ext {
+				tk.EnableExtensions()
+			}
+			if err := tk.Tokenize(); err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, item := range tk.Items() {
+				got = append(got, item.Type()+" "+item.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLexer_ExtensionSymbols(t *testing.T) {
+	tk, _ := NewTokenizer(strings.NewReader("case 1:"))
+	if err := tk.Tokenize(); err == nil || err.Error() != "1:7: illegal character ':'" {
+		t.Errorf("got %v, want the illegal character error", err)
+	}
+	tk, _ = NewTokenizer(strings.NewReader("case 1:"))
+	tk.EnableExtensions()
+	if err := tk.Tokenize(); err != nil {
+		t.Fatal(err)
+	}
+	if got := tk.Items()[2]; got.Type() != SYMBOL || got.String() != COLON {
+		t.Errorf("got %v %v, want symbol :", got.Type(), got.String())
+	}
+}
+
+func TestLexer_CompoundSymbols(t *testing.T) {
+	src := "x+=1 -= *= /= &= |= ++ -- x--1 + = <= /**/= && || &&&"
+	tests := []struct {
+		ext  bool
+		want []string
+	}{
+		{true, []string{"x", "+=", "1", "-=", "*=", "/=", "&=", "|=", "++", "--", "x", "--", "1", "+", "=", "<", "=", "=", "&&", "||", "&&", "&"}},
+		{false, []string{"x", "+", "=", "1", "-", "=", "*", "=", "/", "=", "&", "=", "|", "=", "+", "+", "-", "-", "x", "-", "-", "1", "+", "=", "<", "=", "=", "&", "&", "|", "|", "&", "&", "&"}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint("ext=", tt.ext), func(t *testing.T) {
+			items, errs := lexAllWith(src, tt.ext)
+			if len(errs) > 0 {
+				t.Fatal(errs)
+			}
+			got := []string{}
+			for _, item := range items {
+				got = append(got, item.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLexer_ExtensionConstants(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		ext  bool
+		want []string // type literal value
+	}{
+		{"char", `'A' '~' ' '`, true, []string{"charConstant A 65", "charConstant ~ 126", "charConstant   32"}},
+		{"escaped char", `'\n' '\\' '\'' '"'`, true, []string{`charConstant \n 128`, `charConstant \\ 92`, `charConstant \' 39`, `charConstant " 34`}},
+		{"escaped string", `"a\nb\"c\\" "'"`, true, []string{"stringConstant a\\nb\\\"c\\\\ \"a\\u0080b\\\"c\\\\\"", `stringConstant ' "'"`}},
+		{"backslash in Jack", `"a\nb"`, false, []string{`stringConstant a\nb "a\\nb"`}},
+		{"hexadecimal", "0x4000 0XfF 0xFFFF 0x8000 0x0", true, []string{"hexConstant 0x4000 16384", "hexConstant 0XfF 255", "hexConstant 0xFFFF -1", "hexConstant 0x8000 -32768", "hexConstant 0x0 0"}},
+		{"binary", "0b1010 0B0 0b1111111111111111", true, []string{"binaryConstant 0b1010 10", "binaryConstant 0B0 0", "binaryConstant 0b1111111111111111 -1"}},
+		{"decimal", "0 10 0 x1", true, []string{"integerConstant 0 0", "integerConstant 10 10", "integerConstant 0 0", "identifier x1 "}},
+		{"hexadecimal in Jack", "0x10", false, []string{"integerConstant 0 0", "identifier x10 "}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, errs := lexAllWith(tt.src, tt.ext)
+			if len(errs) > 0 {
+				t.Fatal(errs)
+			}
+			got := []string{}
+			for _, item := range items {
+				value := ""
+				switch tok := item.Token.(type) {
+				case *CharConst:
+					value = fmt.Sprint(tok.Int())
+				case *IntConst:
+					value = fmt.Sprint(tok.Int())
+				case *StrConst:
+					value = fmt.Sprintf("%q", tok.Value())
+				}
+				got = append(got, item.Type()+" "+item.String()+" "+value)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLexer_ExtensionErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		src        string
+		wantTokens []string
+		wantErrs   []string
+	}{
+		{"unknown escape", `x "ab\tc\q" y`, []string{"x", "y"}, []string{`1:6: unknown escape sequence \t`}},
+		{"unknown escape in char", `'\a' y`, []string{"y"}, []string{`1:2: unknown escape sequence \a`}},
+		{"empty char", "'' y", []string{"y"}, []string{"1:1: empty character constant"}},
+		{"long char", "'ab' y", []string{"y"}, []string{"1:1: character constant must be one character: 'ab'"}},
+		{"unterminated char", "'a\ny", []string{"y"}, []string{"1:1: unterminated character constant"}},
+		{"escaped line break", "\"a\\\nb", []string{"b"}, []string{"1:1: unterminated string constant"}},
+		{"non-Hack char", "x '\t' 'あ'", []string{"x"}, []string{"1:4: character '\\t' isn't in the Hack character set", "1:8: character 'あ' isn't in the Hack character set"}},
+		{"no hexadecimal digits", "0x y", []string{"y"}, []string{"1:1: hexadecimal constant has no digits: 0x"}},
+		{"invalid hexadecimal", "0x12G4 y", []string{"y"}, []string{"1:1: invalid hexadecimal constant: 0x12G4"}},
+		{"too large hexadecimal", "0x10000 0xFFFFFFFFFFFFFFFFF", nil, []string{"1:1: hexadecimal constant must be in 16 bits: 0x10000", "1:9: hexadecimal constant must be in 16 bits: 0xFFFFFFFFFFFFFFFFF"}},
+		{"invalid binary", "0b102", nil, []string{"1:1: invalid binary constant: 0b102"}},
+		{"too large binary", "0b10000000000000000", nil, []string{"1:1: binary constant must be in 16 bits: 0b10000000000000000"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, errs := lexAllWith(tt.src, true)
+			gotTokens := []string{}
+			for _, item := range items {
+				gotTokens = append(gotTokens, item.String())
+			}
+			gotErrs := []string{}
+			for _, err := range errs {
+				gotErrs = append(gotErrs, err.Error())
+			}
+			if len(gotTokens) != len(tt.wantTokens) || len(tt.wantTokens) > 0 && !reflect.DeepEqual(gotTokens, tt.wantTokens) {
+				t.Errorf("tokens = %q, want %q", gotTokens, tt.wantTokens)
+			}
+			if !reflect.DeepEqual(gotErrs, tt.wantErrs) {
+				t.Errorf("errors = %q, want %q", gotErrs, tt.wantErrs)
+			}
+		})
+	}
+}
@@ -5,18 +5,19 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"log"
 	"os"
-	"regexp"
 	"strconv"
 	"strings"
 )
 
 type Tokenizer struct {
-	src     string
-	tokens  []Token
-	current int
+	r        io.Reader
+	items    []*Item
+	trailing []*Comment // Comments after the last token
+	tokens   []Token
+	current  int
+	ext      bool // Whether the language extensions are enabled
 }
 
 type Token interface {
@@ -49,16 +50,46 @@ const (
 	INT_CONST  = "integerConstant"
 	IDENTIFIER = "identifier"
 	KEYWORD    = "keyword"
+	// Constants of the language extensions
+	CHAR_CONST = "charConstant"
+	HEX_CONST  = "hexConstant"
+	BIN_CONST  = "binaryConstant"
 )
 
 type StrConst struct {
 	*GenericToken
+	value string
 }
 
+// The string with the escape sequences replaced by the characters
+func (t *StrConst) Value() string {
+	return t.value
+}
+
+// s is the string without the double quotes.
 func NewStrConst(s string, pos []int) *StrConst {
-	s = strings.ReplaceAll(s, "\"", "")
-	s = strings.ReplaceAll(s, "\n", "")
-	return &StrConst{&GenericToken{token: s, tokenType: "stringConstant", pos: pos}}
+	return NewEscapedStrConst(s, s, pos)
+}
+
+// literal is the string as written without the double quotes. value is the string with the escape sequences replaced.
+func NewEscapedStrConst(literal string, value string, pos []int) *StrConst {
+	return &StrConst{&GenericToken{token: literal, tokenType: STR_CONST, pos: pos}, value}
+}
+
+// CharConst is a character constant 'A' of the language extensions.
+type CharConst struct {
+	*GenericToken
+	value int
+}
+
+// The code of the character in the Hack character set
+func (t *CharConst) Int() int {
+	return t.value
+}
+
+// literal is the character as written without the single quotes.
+func NewCharConst(literal string, value int, pos []int) *CharConst {
+	return &CharConst{&GenericToken{token: literal, tokenType: CHAR_CONST, pos: pos}, value}
 }
 
 type Symbol struct {
@@ -89,6 +120,18 @@ const (
 	GREATER       = ">"
 	EQUAL         = "="
 	TILDA         = "~"
+	// Language extensions
+	COLON          = ":"
+	PLUS_EQUAL     = "+="
+	MINUS_EQUAL    = "-="
+	ASTERISK_EQUAL = "*="
+	SLASH_EQUAL    = "/="
+	AND_EQUAL      = "&="
+	OR_EQUAL       = "|="
+	INCREMENT      = "++"
+	DECREMENT      = "--"
+	LOGICAL_AND    = "&&"
+	LOGICAL_OR     = "||"
 )
 
 type IntConst struct {
@@ -100,12 +143,37 @@ func (t *IntConst) Int() int {
 	return t.value
 }
 
+// 32768 is accepted since it's written as -32768. Otherwise the compiler reports it.
 func NewIntConst(s string, pos []int) (*IntConst, error) {
 	value, err := strconv.Atoi(s)
-	if value < 0 || value > 32768 {
-		return nil, errors.New(fmt.Sprintf("Integer constant must be in [0, 32768]: %v", value))
+	if err != nil || value < 0 || value > 32768 {
+		return nil, fmt.Errorf("Integer constant must be in [0, 32767], or 32768 only as -32768: %v", s)
+	}
+	return &IntConst{GenericToken: &GenericToken{token: s, tokenType: INT_CONST, pos: pos}, value: value}, nil
+}
+
+// Integer constant in hexadecimal 0x4000 or binary 0b1010 of the language extensions.
+// Any 16-bit pattern is accepted. 0x8000 to 0xFFFF are negative.
+func NewRadixIntConst(s string, pos []int) (*IntConst, error) {
+	base, tokenType, name := 16, HEX_CONST, "hexadecimal"
+	if strings.HasPrefix(s, "0b") || strings.HasPrefix(s, "0B") {
+		base, tokenType, name = 2, BIN_CONST, "binary"
+	}
+	digits := s[2:]
+	if digits == "" {
+		return nil, fmt.Errorf("%v constant has no digits: %v", name, s)
+	}
+	value, err := strconv.ParseUint(digits, base, 64)
+	if err != nil {
+		if errors.Is(err, strconv.ErrRange) {
+			return nil, fmt.Errorf("%v constant must be in 16 bits: %v", name, s)
+		}
+		return nil, fmt.Errorf("invalid %v constant: %v", name, s)
+	}
+	if value > 0xFFFF {
+		return nil, fmt.Errorf("%v constant must be in 16 bits: %v", name, s)
 	}
-	return &IntConst{GenericToken: &GenericToken{token: s, tokenType: INT_CONST, pos: pos}, value: value}, err
+	return &IntConst{GenericToken: &GenericToken{token: s, tokenType: tokenType, pos: pos}, value: int(int16(value))}, nil
 }
 
 type Identifier struct {
@@ -148,6 +216,18 @@ const (
 	RETURN      = "return"
 )
 
+// Keywords of the language extensions
+const (
+	FOR      = "for"
+	BREAK    = "break"
+	CONTINUE = "continue"
+	SWITCH   = "switch"
+	CASE     = "case"
+	DEFAULT  = "default"
+	CONST    = "const"
+	ENUM     = "enum"
+)
+
 func (t *Tokenizer) HasMoreTokens() bool {
 	return len(t.tokens)-1 > t.current
 }
@@ -156,6 +236,11 @@ func (t *Tokenizer) Current() Token {
 	return t.tokens[t.current]
 }
 
+// Return the current token with its extent and the comments before it.
+func (t *Tokenizer) CurrentItem() *Item {
+	return t.items[t.current]
+}
+
 func (t *Tokenizer) Advance() error {
 	if !t.HasMoreTokens() {
 		return errors.New("Couldn't advance. No more tokens.")
@@ -181,95 +266,42 @@ func (t *Tokenizer) LookAhead(offset int) (Token, error) {
 	return t.tokens[t.current+offset], nil
 }
 
-func preprocess(src string) string {
-	// CRLF -> LF
-	src = strings.ReplaceAll(src, "\r\n", "\n")
-	return src
-}
-
-var lf *regexp.Regexp = regexp.MustCompile(`\n`)
-
-func lineCountCummulativeMap(src string) map[int]int {
-	matches := lf.FindAllStringIndex(src, -1)
-	m := make(map[int]int, 0)
-	for _, j := range matches {
-		m[j[0]] += 1
-	}
-	return m
-}
-
-// Return array of [row,column] at each character index
-// Row and column start with 1.
-func charIndex2LineColumnArray(src string) [][]int {
-	matchedLFs := lf.FindAllStringIndex(src, -1)
-	if len(matchedLFs) == 0 {
-		matchedLFs = [][]int{{len(src)}}
+// Read all tokens with Lexer. It also returns the comments after the last token.
+// It returns all lexical errors as LexErrorList.
+func lex(r io.Reader, ext bool) ([]*Item, []*Comment, error) {
+	lexer := NewLexer(r)
+	if ext {
+		lexer.EnableExtensions()
 	}
-
-	lineColumn := make([][]int, len(src))
-	nLF := 0
-	posLF := matchedLFs[nLF][0]
-	prevPosLF := -1
-	for i := 0; i < len(lineColumn); i++ {
-		if posLF < i {
-			nLF++
-			prevPosLF = posLF
-			if nLF < len(matchedLFs) {
-				posLF = matchedLFs[nLF][0]
-			} else {
-				// No more LFs.
-				posLF = len(src)
-			}
+	items := make([]*Item, 0)
+	errs := LexErrorList{}
+	for {
+		item, err := lexer.Next()
+		if errors.Is(err, io.EOF) {
+			break
 		}
-		currentLine := nLF + 1
-		currentColumn := i - prevPosLF // How many chars after previous LF
-		lineColumn[i] = []int{currentLine, currentColumn}
+		if lexErr, ok := err.(*LexError); ok {
+			errs = append(errs, lexErr)
+			continue
+		} else if err != nil {
+			return nil, nil, fmt.Errorf("Failed to read .jack: %v", err)
+		}
+		items = append(items, item)
+	}
+	if len(errs) > 0 {
+		return nil, nil, errs
 	}
-	return lineColumn
+	return items, lexer.Comments(), nil
 }
 
-var tokenRegexp *regexp.Regexp = regexp.MustCompile(`(?P<multComment>(?s)(/\*\*).*?(\*/))|(?P<singleComment>(?P<slash>//).*)|(?P<emptyLine>(?m)^\n)|(?P<strConst>"[^"]+")|(?P<idOrKeyword>[a-zA-Z_][a-zA-Z0-9_]*)|(?P<symbol>[{}\(\)\[\]\.\,;\+\-\*\/&\|<>=~])|(?P<intConst>[0-9]+)`)
-
 func tokenize(src string) ([]Token, error) {
-	charIndex2LineColumn := charIndex2LineColumnArray(src)
-	tokens := make([]Token, 0)
-	matchStrings := tokenRegexp.FindAllStringSubmatch(src, -1) // Use capturing groups.
-	matchIndices := tokenRegexp.FindAllStringIndex(src, -1)
-	groupNames := tokenRegexp.SubexpNames()
-	for i, matchString := range matchStrings {
-		lineColumn := charIndex2LineColumn[matchIndices[i][0]]
-		for j, name := range groupNames[1:] { // SubexpNames()[0] is always empty. Ordered samely as the regex.
-			m := matchString[j+1]
-			if m != "" {
-				var t Token
-				switch name {
-				case "multComment", "singleComment", "emptyLine":
-					goto Skip
-				case "strConst":
-					t = NewStrConst(m, lineColumn)
-				case "idOrKeyword":
-					switch m {
-					case CLASS, CONSTRUCTOR, FUNCTION, METHOD, FIELD, STATIC, VAR, INT, CHAR, BOOLEAN, VOID, TRUE, FALSE, NULL, THIS, LET, DO, IF, ELSE, WHILE, RETURN:
-						t = NewKeyword(m, lineColumn)
-					default:
-						t = NewIdentifier(m, lineColumn)
-					}
-				case "symbol":
-					t = NewSymbol(m, lineColumn)
-				case "intConst":
-					var err error
-					t, err = NewIntConst(m, lineColumn)
-					if err != nil {
-						return nil, fmt.Errorf("Invalid integer constant: %w", err)
-					}
-				default:
-					continue
-					//return nil, errors.New(fmt.Sprintf("Unknown token: %v", m))
-				}
-				tokens = append(tokens, t)
-			}
-		}
-	Skip:
+	items, _, err := lex(strings.NewReader(src), false)
+	if err != nil {
+		return nil, err
+	}
+	tokens := make([]Token, len(items))
+	for i, item := range items {
+		tokens[i] = item.Token
 	}
 	return tokens, nil
 }
@@ -295,22 +327,36 @@ func (t *Tokenizer) XML() string {
 	return string(buf)
 }
 
+// Tokenize the language extensions. Call it before Tokenize.
+func (t *Tokenizer) EnableExtensions() {
+	t.ext = true
+}
+
+// Tokenize the whole source. Lexical errors are returned as LexErrorList.
 func (t *Tokenizer) Tokenize() error {
-	t.src = preprocess(t.src)
-	tokens, err := tokenize(t.src)
+	items, trailing, err := lex(t.r, t.ext)
 	if err != nil {
-		return fmt.Errorf("Failed to tokenize: %v", err)
+		return err
+	}
+	t.items = items
+	t.trailing = trailing
+	t.tokens = make([]Token, len(items))
+	for i, item := range items {
+		t.tokens[i] = item.Token
 	}
-	t.tokens = tokens
 	return nil
 }
 
+// Return the tokens with their extents in the source.
+func (t *Tokenizer) Items() []*Item {
+	return t.items
+}
+
+// Return the comments after the last token, which no token has.
+func (t *Tokenizer) TrailingComments() []*Comment {
+	return t.trailing
+}
+
 func NewTokenizer(r io.Reader) (*Tokenizer, error) {
-	b, err := ioutil.ReadAll(r)
-	src := string(b)
-	if err != nil {
-		return nil, fmt.Errorf("Failed to read .jack: %v", err)
-	}
-	p := &Tokenizer{src, nil, 0}
-	return p, nil
+	return &Tokenizer{r: r}, nil
 }
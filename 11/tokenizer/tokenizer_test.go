@@ -22,14 +22,15 @@ func TestTokenize(t *testing.T) {
 		args args
 		want []Token
 	}{
-		{"stringConstant", args{"\"azAZあclass{09\n\""}, []Token{NewStrConst("azAZあclass{09", pos)}},
+		{"stringConstant", args{"\"azAZあclass{09 /**/\""}, []Token{NewStrConst("azAZあclass{09 /**/", pos)}},
 		{"keyword", args{"class"}, []Token{NewKeyword("class", pos)}},
 		{"identifier", args{"class_09"}, []Token{NewIdentifier("class_09", pos)}},
 		{"symbol", args{"{}()[].,;+-*/&|<>=~"}, []Token{NewSymbol("{", pos), NewSymbol("}", pos), NewSymbol("(", pos), NewSymbol(")", pos), NewSymbol("[", pos), NewSymbol("]", pos), NewSymbol(".", pos), NewSymbol(",", pos), NewSymbol(";", pos), NewSymbol("+", pos), NewSymbol("-", pos), NewSymbol("*", pos), NewSymbol("/", pos), NewSymbol("&", pos), NewSymbol("|", pos), NewSymbol("<", pos), NewSymbol(">", pos), NewSymbol("=", pos), NewSymbol("~", pos)}},
 		{"integerConstant", args{"09"}, []Token{newIntConstIgnoreErr("09")}},
-		{"combination", args{"\"azAZあclass{09\n\"class class_09{123"}, []Token{NewStrConst("azAZあclass{09", pos), NewKeyword("class", pos), NewIdentifier("class_09", pos), NewSymbol("{", pos), newIntConstIgnoreErr("123")}},
+		{"combination", args{"\"azAZあclass{09\"class class_09{123"}, []Token{NewStrConst("azAZあclass{09", pos), NewKeyword("class", pos), NewIdentifier("class_09", pos), NewSymbol("{", pos), newIntConstIgnoreErr("123")}},
 		{"single line comment", args{"code//comment\ncode"}, []Token{NewIdentifier("code", pos), NewIdentifier("code", pos)}},
 		{"multi line comment", args{"code/** comment1\ncomment2. */code"}, []Token{NewIdentifier("code", pos), NewIdentifier("code", pos)}},
+		{"block comment", args{"code/* comment1\ncomment2. */code/**/code"}, []Token{NewIdentifier("code", pos), NewIdentifier("code", pos), NewIdentifier("code", pos)}},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -103,7 +104,7 @@ func isTokenEqualExceptPos(tokens1 []Token, tokens2 []Token) bool {
 // }
 
 func TestXML(t *testing.T) {
-	r := strings.NewReader("\"test1\n\" class{123")
+	r := strings.NewReader("\"test1\" class{123")
 	tokenizer, err := NewTokenizer(r)
 	if err != nil {
 		t.Errorf("%v", err)
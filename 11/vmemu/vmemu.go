@@ -0,0 +1,383 @@
+// Package vmemu runs VM code to test the code generated by the compiler.
+// It follows the VM specification of chapters 7 and 8 but runs the commands directly, not the Hack code.
+// gt and lt behave as the VM translator of the repository, which compares x-y with 0 and overflows.
+package vmemu
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Addresses of the RAM. See p142.
+const (
+	SP         = 0
+	LCL        = 1
+	ARG        = 2
+	THIS       = 3
+	THAT       = 4
+	tempBase   = 5
+	staticBase = 16
+	stackBase  = 256
+	heapBase   = 2048
+	ramSize    = 32768
+)
+
+// Builtin is a subroutine implemented in Go instead of VM code, such as OS functions.
+type Builtin func(m *Machine, args []int) (int, error)
+
+type command struct {
+	name   string // push, add, function, ...
+	arg1   string
+	arg2   int
+	target int    // Index of the command which goto, if-goto and call jump to
+	fn     string // Name of the function which the command belongs to
+	class  string // Class name for the static segment
+}
+
+// Machine is a VM with the RAM and the loaded code.
+type Machine struct {
+	ram       []int // Values are 16-bit signed integers.
+	code      []command
+	functions map[string]int // Function name to the index of the function command
+	statics   map[string]int // Class.index to the address
+	Builtins  map[string]Builtin
+	Steps     int // Number of the VM commands executed. A call of a builtin is 1 step.
+	MaxSteps  int // Run fails after this steps. 0 means no limit.
+	Cycles    int // Number of the Hack instructions the VM translator of chapter 8 would execute. See cycles.
+	heap      int // Next address Memory.alloc returns
+}
+
+func NewMachine() *Machine {
+	m := &Machine{
+		ram:       make([]int, ramSize),
+		functions: map[string]int{},
+		statics:   map[string]int{},
+		Builtins:  map[string]Builtin{},
+		heap:      heapBase,
+	}
+	for name, b := range defaultBuiltins {
+		m.Builtins[name] = b
+	}
+	return m
+}
+
+// Wrap the value around to a 16-bit integer.
+func wrap(v int) int {
+	return int(int16(v))
+}
+
+func boolValue(b bool) int {
+	if b {
+		return -1
+	}
+	return 0
+}
+
+// The OS functions needed for expressions. Each of them costs 1 step.
+// Load the VM code of the OS to override them.
+var defaultBuiltins = map[string]Builtin{
+	"Math.multiply": func(m *Machine, args []int) (int, error) {
+		return wrap(args[0] * args[1]), nil
+	},
+	"Math.divide": func(m *Machine, args []int) (int, error) {
+		if args[1] == 0 {
+			return 0, errors.New("Division by zero")
+		}
+		return wrap(args[0] / args[1]), nil
+	},
+	"Memory.alloc": func(m *Machine, args []int) (int, error) {
+		addr := m.heap
+		m.heap += args[0]
+		if args[0] < 0 || m.heap > ramSize {
+			return 0, fmt.Errorf("Heap overflow: size=%v", args[0])
+		}
+		return addr, nil
+	},
+	"Memory.deAlloc": func(m *Machine, args []int) (int, error) {
+		return 0, nil
+	},
+}
+
+// Cycles of a call of a builtin. Only the call and the return are counted, not the body.
+const builtinCycles = 52 + 62
+
+// Return the number of the Hack instructions run by the code the VM translator of chapter 8 writes for the command.
+// Every written instruction runs once except in the comparisons. Their true path is counted here,
+// and compare adds the 2 more instructions of the false path. See setTrueOrFalseToD of 08/codewriter.
+func cycles(c command) int {
+	switch c.name {
+	case "push":
+		if c.arg1 == "constant" || c.arg1 == "static" {
+			return 7
+		}
+		return 11
+	case "pop":
+		if c.arg1 == "static" {
+			return 15
+		}
+		return 17
+	case "add", "sub", "and", "or":
+		return 21
+	case "neg", "not":
+		return 13
+	case "eq", "gt", "lt":
+		return 25
+	case "goto":
+		return 2
+	case "if-goto":
+		return 6
+	case "function":
+		return 7 * c.arg2
+	case "call":
+		return 52
+	case "return":
+		return 62
+	}
+	return 0
+}
+
+// Load the VM code of a class. className is used for the static segment.
+func (m *Machine) Load(className string, lines []string) error {
+	labels := map[string]int{}
+	start := len(m.code)
+	fn := ""
+	for i, line := range lines {
+		if j := strings.Index(line, "//"); j >= 0 {
+			line = line[:j]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		c := command{name: fields[0], fn: fn, class: className}
+		if len(fields) > 1 {
+			c.arg1 = fields[1]
+		}
+		if len(fields) > 2 {
+			n, err := strconv.Atoi(fields[2])
+			if err != nil {
+				return fmt.Errorf("%v:%v: Invalid number: %v", className, i+1, fields[2])
+			}
+			c.arg2 = n
+		}
+		switch c.name {
+		case "function":
+			fn = c.arg1
+			c.fn = fn
+			m.functions[fn] = len(m.code)
+		case "label":
+			labels[fn+"$"+c.arg1] = len(m.code)
+		}
+		m.code = append(m.code, c)
+	}
+	// Resolve jump targets in this class. Calls are resolved at runtime.
+	for i := start; i < len(m.code); i++ {
+		c := &m.code[i]
+		if c.name == "goto" || c.name == "if-goto" {
+			target, ok := labels[c.fn+"$"+c.arg1]
+			if !ok {
+				return fmt.Errorf("%v: Undefined label %v in %v", className, c.arg1, c.fn)
+			}
+			c.target = target
+		}
+	}
+	return nil
+}
+
+func (m *Machine) push(v int) {
+	m.ram[m.ram[SP]] = v
+	m.ram[SP]++
+}
+
+func (m *Machine) pop() int {
+	m.ram[SP]--
+	return m.ram[m.ram[SP]]
+}
+
+// Return the address of the segment[index].
+func (m *Machine) address(c command) (int, error) {
+	switch c.arg1 {
+	case "local":
+		return m.ram[LCL] + c.arg2, nil
+	case "argument":
+		return m.ram[ARG] + c.arg2, nil
+	case "this":
+		return m.ram[THIS] + c.arg2, nil
+	case "that":
+		return m.ram[THAT] + c.arg2, nil
+	case "pointer":
+		return THIS + c.arg2, nil
+	case "temp":
+		return tempBase + c.arg2, nil
+	case "static":
+		key := fmt.Sprintf("%v.%v", c.class, c.arg2)
+		addr, ok := m.statics[key]
+		if !ok {
+			addr = staticBase + len(m.statics)
+			m.statics[key] = addr
+		}
+		return addr, nil
+	}
+	return 0, fmt.Errorf("Unknown segment: %v", c.arg1)
+}
+
+// Read the word of the RAM.
+func (m *Machine) Peek(addr int) int {
+	return m.ram[addr]
+}
+
+// Write the word to the RAM.
+func (m *Machine) Poke(addr int, v int) {
+	m.ram[addr] = wrap(v)
+}
+
+// Call the function with the arguments and run until it returns. It returns the return value.
+func (m *Machine) Call(name string, args ...int) (int, error) {
+	entry, ok := m.functions[name]
+	if !ok {
+		if b, ok := m.Builtins[name]; ok {
+			m.Steps++
+			m.Cycles += builtinCycles
+			return b(m, args)
+		}
+		return 0, fmt.Errorf("Undefined function: %v", name)
+	}
+	m.ram[SP] = stackBase
+	for _, a := range args {
+		m.push(wrap(a))
+	}
+	// The frame of the caller. The return address -1 stops the machine.
+	m.push(-1)
+	m.push(m.ram[LCL])
+	m.push(m.ram[ARG])
+	m.push(m.ram[THIS])
+	m.push(m.ram[THAT])
+	m.ram[ARG] = m.ram[SP] - len(args) - 5
+	m.ram[LCL] = m.ram[SP]
+	if err := m.run(entry); err != nil {
+		return 0, fmt.Errorf("%v: %v", name, err)
+	}
+	return m.pop(), nil
+}
+
+// Push the result of the comparison. The false path of the VM translator is 2 instructions longer.
+func (m *Machine) compare(b bool) {
+	if !b {
+		m.Cycles += 2
+	}
+	m.push(boolValue(b))
+}
+
+func (m *Machine) run(pc int) error {
+	for pc >= 0 {
+		if pc >= len(m.code) {
+			return errors.New("Program counter is out of the code")
+		}
+		if m.ram[SP] < stackBase || m.ram[SP] >= heapBase {
+			return fmt.Errorf("Stack overflow: SP=%v", m.ram[SP])
+		}
+		m.Steps++
+		if m.MaxSteps > 0 && m.Steps > m.MaxSteps {
+			return fmt.Errorf("Too many steps: %v", m.Steps)
+		}
+		c := m.code[pc]
+		pc++
+		m.Cycles += cycles(c)
+		switch c.name {
+		case "push":
+			if c.arg1 == "constant" {
+				m.push(c.arg2)
+				continue
+			}
+			addr, err := m.address(c)
+			if err != nil {
+				return err
+			}
+			m.push(m.ram[addr])
+		case "pop":
+			addr, err := m.address(c)
+			if err != nil {
+				return err
+			}
+			m.ram[addr] = m.pop()
+		case "add":
+			y, x := m.pop(), m.pop()
+			m.push(wrap(x + y))
+		case "sub":
+			y, x := m.pop(), m.pop()
+			m.push(wrap(x - y))
+		case "neg":
+			m.push(wrap(-m.pop()))
+		case "eq":
+			y, x := m.pop(), m.pop()
+			m.compare(x == y)
+		case "gt":
+			// The VM translator compares the sign of x-y, which overflows if the values are more than 32767 apart.
+			y, x := m.pop(), m.pop()
+			m.compare(wrap(x-y) > 0)
+		case "lt":
+			y, x := m.pop(), m.pop()
+			m.compare(wrap(x-y) < 0)
+		case "and":
+			y, x := m.pop(), m.pop()
+			m.push(x & y)
+		case "or":
+			y, x := m.pop(), m.pop()
+			m.push(x | y)
+		case "not":
+			m.push(wrap(^m.pop()))
+		case "label":
+		case "goto":
+			pc = c.target
+		case "if-goto":
+			if m.pop() != 0 {
+				pc = c.target
+			}
+		case "function":
+			for i := 0; i < c.arg2; i++ {
+				m.push(0)
+			}
+		case "call":
+			entry, ok := m.functions[c.arg1]
+			if b, isBuiltin := m.Builtins[c.arg1]; !ok && isBuiltin {
+				args := make([]int, c.arg2)
+				for i := c.arg2 - 1; i >= 0; i-- {
+					args[i] = m.pop()
+				}
+				v, err := b(m, args)
+				if err != nil {
+					return fmt.Errorf("%v: %v", c.arg1, err)
+				}
+				m.Cycles += builtinCycles - cycles(c)
+				m.push(wrap(v))
+				continue
+			}
+			if !ok {
+				return fmt.Errorf("Undefined function: %v", c.arg1)
+			}
+			m.push(pc)
+			m.push(m.ram[LCL])
+			m.push(m.ram[ARG])
+			m.push(m.ram[THIS])
+			m.push(m.ram[THAT])
+			m.ram[ARG] = m.ram[SP] - c.arg2 - 5
+			m.ram[LCL] = m.ram[SP]
+			pc = entry
+		case "return":
+			frame := m.ram[LCL]
+			ret := m.ram[frame-5]
+			m.ram[m.ram[ARG]] = m.pop()
+			m.ram[SP] = m.ram[ARG] + 1
+			m.ram[THAT] = m.ram[frame-1]
+			m.ram[THIS] = m.ram[frame-2]
+			m.ram[ARG] = m.ram[frame-3]
+			m.ram[LCL] = m.ram[frame-4]
+			pc = ret
+		default:
+			return fmt.Errorf("Unknown command: %v", c.name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package vmemu
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMachine_Call(t *testing.T) {
+	// Sum of 1..n by a loop, recursion and a static counter
+	src := `
+function Main.sum 1
+  push constant 0
+  pop local 0
+label LOOP
+  push argument 0
+  push constant 0
+  eq
+  if-goto END
+  push local 0
+  push argument 0
+  add
+  pop local 0
+  push argument 0
+  push constant 1
+  sub
+  pop argument 0
+  goto LOOP
+label END
+  push local 0
+  return
+function Main.fact 0 // n!
+  push static 0
+  push constant 1
+  add
+  pop static 0
+  push argument 0
+  push constant 1
+  gt
+  not
+  if-goto END
+  push argument 0
+  push argument 0
+  push constant 1
+  sub
+  call Main.fact 1
+  call Math.multiply 2
+  return
+label END
+  push constant 1
+  return
+function Main.calls 0
+  push static 0
+  return
+`
+	m := NewMachine()
+	if err := m.Load("Main", strings.Split(src, "\n")); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		args []int
+		want int
+	}{
+		{"Main.sum", []int{10}, 55},
+		{"Main.sum", []int{300}, wrap(45150)},
+		{"Main.fact", []int{7}, 5040},
+		{"Main.calls", nil, 7},
+		{"Math.divide", []int{-7, 2}, -3},
+	}
+	for _, tt := range tests {
+		got, err := m.Call(tt.name, tt.args...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%v(%v) = %v, want %v", tt.name, tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestMachine_Comparison(t *testing.T) {
+	// Main.f(x, y) returns x op y.
+	tests := []struct {
+		op   string
+		x, y int
+		want int
+	}{
+		{"lt", 1, 2, -1},
+		{"lt", 2, 1, 0},
+		{"gt", -3, -5, -1},
+		{"eq", 7, 7, -1},
+		// x-y overflows as in the VM translator.
+		{"lt", -30000, 10000, 0},
+		{"gt", -30000, 10000, -1},
+		{"lt", 32767, -1, -1},
+		{"gt", 32767, -1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%v %v %v", tt.x, tt.op, tt.y), func(t *testing.T) {
+			m := NewMachine()
+			src := "function Main.f 0\npush argument 0\npush argument 1\n" + tt.op + "\nreturn"
+			if err := m.Load("Main", strings.Split(src, "\n")); err != nil {
+				t.Fatal(err)
+			}
+			got, err := m.Call("Main.f", tt.x, tt.y)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMachine_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"undefined label", "function Main.f 0\ngoto X", "Main: Undefined label X in Main.f"},
+		{"undefined function", "function Main.f 0\ncall Main.g 0\nreturn", "Main.f: Undefined function: Main.g"},
+		{"division by zero", "function Main.f 0\npush constant 1\npush constant 0\ncall Math.divide 2\nreturn", "Main.f: Math.divide: Division by zero"},
+		{"infinite loop", "function Main.f 0\nlabel L\ngoto L", "Main.f: Too many steps: 1001"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMachine()
+			m.MaxSteps = 1000
+			err := m.Load("Main", strings.Split(tt.src, "\n"))
+			if err == nil {
+				_, err = m.Call("Main.f")
+			}
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMachine_Cycles(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want int
+	}{
+		{"push and pop", "function Main.f 1\npush constant 1\npop local 0\npush local 0\nreturn", 7 + 7 + 17 + 11 + 62},
+		{"static", "function Main.f 0\npush static 0\npop static 1\npush constant 0\nreturn", 7 + 15 + 7 + 62},
+		{"arithmetic", "function Main.f 0\npush constant 1\nneg\npush constant 2\nadd\npush constant 3\nlt\nreturn", 7 + 13 + 7 + 21 + 7 + 25 + 62},
+		{"false comparison", "function Main.f 0\npush constant 1\npush constant 1\ngt\nreturn", 7 + 7 + 27 + 62},
+		{"jump", "function Main.f 0\ngoto L\nlabel L\npush constant 0\nif-goto L\npush constant 0\nreturn", 2 + 7 + 6 + 7 + 62},
+		{"builtin", "function Main.f 0\npush constant 6\npush constant 7\ncall Math.multiply 2\nreturn", 7 + 7 + 52 + 62 + 62},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMachine()
+			if err := m.Load("Main", strings.Split(tt.src, "\n")); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := m.Call("Main.f"); err != nil {
+				t.Fatal(err)
+			}
+			if m.Cycles != tt.want {
+				t.Errorf("got %v, want %v", m.Cycles, tt.want)
+			}
+		})
+	}
+}
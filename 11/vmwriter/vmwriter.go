@@ -7,50 +7,95 @@ import (
 	"strings"
 )
 
+// Stack is a LIFO of strings. It grows as needed.
 type Stack struct {
 	stack []string
-	sp    int
 }
 
 func NewStack() *Stack {
-	initialSize := 20
-	return &Stack{make([]string, initialSize), 0}
+	return &Stack{make([]string, 0)}
 }
 
 func (s *Stack) Push(e string) {
-	s.stack[s.sp] = e
-	s.sp++
+	s.stack = append(s.stack, e)
 }
 
 // Pop. Panic if the stack is empty.
 func (s *Stack) Pop() string {
-	poped := s.stack[s.sp-1]
-	s.sp--
+	poped := s.stack[len(s.stack)-1]
+	s.stack = s.stack[:len(s.stack)-1]
 	return poped
 }
 
 // See the top of the stack. Panic if the stack is empty.
 func (s *Stack) Top() string {
-	return s.stack[s.sp-1]
+	return s.stack[len(s.stack)-1]
+}
+
+func (s *Stack) Len() int {
+	return len(s.stack)
+}
+
+// SourcePos is the position of the token in the .jack source which a VM command is written for.
+// Line 0 means unknown.
+type SourcePos struct {
+	Line   int
+	Column int
 }
 
 type VMWriter struct {
-	lines []string
+	lines     []string
+	positions []SourcePos // Source position of each line
+	pos       SourcePos   // Source position of the lines added next
 }
 
 func (w *VMWriter) Add(code string) {
 	w.lines = append(w.lines, code)
+	w.positions = append(w.positions, w.pos)
 }
 
 func (w *VMWriter) Code() []string {
 	return w.lines
 }
 
+// Set the source position of the lines added next. It returns the previous one.
+func (w *VMWriter) SetPos(pos SourcePos) SourcePos {
+	prev := w.pos
+	w.pos = pos
+	return prev
+}
+
+// Return the source position of each line of the code.
+func (w *VMWriter) Positions() []SourcePos {
+	return w.positions
+}
+
 func NewVMWriter() (*VMWriter, error) {
-	vmWriter := VMWriter{[]string{}}
+	vmWriter := VMWriter{lines: []string{}, positions: []SourcePos{}}
 	return &vmWriter, nil
 }
 
+// Return the code with a comment before the commands of each source line, such as "// Main.jack:42 let x = 1;".
+// The comment has the file name, the line number and the trimmed source line.
+func AnnotateCode(vmCode []string, positions []SourcePos, fileName string, src []string) []string {
+	annotated := make([]string, 0, len(vmCode))
+	line := 0
+	for i, code := range vmCode {
+		if pos := positions[i]; pos.Line > 0 && pos.Line != line {
+			line = pos.Line
+			comment := fmt.Sprintf("// %v:%v", fileName, line)
+			if line <= len(src) {
+				if s := strings.TrimSpace(src[line-1]); s != "" {
+					comment += " " + s
+				}
+			}
+			annotated = append(annotated, comment)
+		}
+		annotated = append(annotated, code)
+	}
+	return annotated
+}
+
 func WriteCode(vmCode []string, filepath string) error {
 	err := os.WriteFile(filepath, []byte(strings.Join(vmCode, "\n")), 0666)
 	if err != nil {
@@ -96,22 +141,40 @@ func ReturnCode() string {
 }
 
 type LabelManager struct {
-	counter    map[string]int
-	ifStack    Stack
-	whileStack Stack
+	counter       map[string]int
+	ifStack       Stack
+	whileStack    Stack
+	forStack      Stack
+	switchStack   Stack
+	logicalStack  Stack
+	breakStack    Stack // Labels which break jumps to in the enclosing loops and switches
+	continueStack Stack // Labels which continue jumps to in the enclosing loops
 }
 
 func NewLabelManager() *LabelManager {
-	return &LabelManager{map[string]int{"while": -1, "if": -1}, *NewStack(), *NewStack()}
+	return &LabelManager{
+		counter:       map[string]int{"while": -1, "if": -1, "for": -1, "switch": -1, "logical": -1},
+		ifStack:       *NewStack(),
+		whileStack:    *NewStack(),
+		forStack:      *NewStack(),
+		switchStack:   *NewStack(),
+		logicalStack:  *NewStack(),
+		breakStack:    *NewStack(),
+		continueStack: *NewStack(),
+	}
 }
 
 func (l *LabelManager) StartWhile() {
 	l.counter["while"]++
 	l.whileStack.Push(strconv.Itoa(l.counter["while"]))
+	l.breakStack.Push(l.WhileEndLabel())
+	l.continueStack.Push(l.WhileExpLabel())
 }
 
 func (l *LabelManager) EndWhile() {
 	l.whileStack.Pop()
+	l.breakStack.Pop()
+	l.continueStack.Pop()
 }
 
 func (l *LabelManager) WhileExpLabel() string {
@@ -122,6 +185,102 @@ func (l *LabelManager) WhileEndLabel() string {
 	return fmt.Sprintf("WHILE_END%s", l.whileStack.Top())
 }
 
+func (l *LabelManager) StartFor() {
+	l.counter["for"]++
+	l.forStack.Push(strconv.Itoa(l.counter["for"]))
+	l.breakStack.Push(l.ForEndLabel())
+	l.continueStack.Push(l.ForIncLabel())
+}
+
+func (l *LabelManager) EndFor() {
+	l.forStack.Pop()
+	l.breakStack.Pop()
+	l.continueStack.Pop()
+}
+
+func (l *LabelManager) ForExpLabel() string {
+	return fmt.Sprintf("FOR_EXP%s", l.forStack.Top())
+}
+
+// The head of the post statement, where continue jumps to
+func (l *LabelManager) ForIncLabel() string {
+	return fmt.Sprintf("FOR_INC%s", l.forStack.Top())
+}
+
+func (l *LabelManager) ForEndLabel() string {
+	return fmt.Sprintf("FOR_END%s", l.forStack.Top())
+}
+
+func (l *LabelManager) StartSwitch() {
+	l.counter["switch"]++
+	l.switchStack.Push(strconv.Itoa(l.counter["switch"]))
+	l.breakStack.Push(l.SwitchEndLabel())
+}
+
+func (l *LabelManager) EndSwitch() {
+	l.switchStack.Pop()
+	l.breakStack.Pop()
+}
+
+// The label of the i-th case clause of the switch in the source order
+func (l *LabelManager) SwitchCaseLabel(i int) string {
+	return fmt.Sprintf("SWITCH_CASE%s_%d", l.switchStack.Top(), i)
+}
+
+// The label of a node of the binary search for the case
+func (l *LabelManager) SwitchSearchLabel(i int) string {
+	return fmt.Sprintf("SWITCH_SEARCH%s_%d", l.switchStack.Top(), i)
+}
+
+func (l *LabelManager) SwitchEndLabel() string {
+	return fmt.Sprintf("SWITCH_END%s", l.switchStack.Top())
+}
+
+// Start && or || which skips the right operand.
+func (l *LabelManager) StartLogical() {
+	l.counter["logical"]++
+	l.logicalStack.Push(strconv.Itoa(l.counter["logical"]))
+}
+
+func (l *LabelManager) EndLogical() {
+	l.logicalStack.Pop()
+}
+
+// The label jumped to when the left operand decides the value: false of && or true of ||
+func (l *LabelManager) LogicalShortLabel() string {
+	return fmt.Sprintf("LOGICAL_SHORT%s", l.logicalStack.Top())
+}
+
+func (l *LabelManager) LogicalEndLabel() string {
+	return fmt.Sprintf("LOGICAL_END%s", l.logicalStack.Top())
+}
+
+// The label after building the pooled string literals at the entry of the subroutine
+func (l *LabelManager) StringsReadyLabel() string {
+	return "STRINGS_READY"
+}
+
+// Whether break is in a loop or switch
+func (l *LabelManager) CanBreak() bool {
+	return l.breakStack.Len() > 0
+}
+
+// Whether continue is in a loop
+func (l *LabelManager) CanContinue() bool {
+	return l.continueStack.Len() > 0
+}
+
+// The label break jumps to: the end of the innermost loop or switch. Panic if there is none.
+func (l *LabelManager) BreakLabel() string {
+	return l.breakStack.Top()
+}
+
+// The label continue jumps to: the condition of the innermost while loop or the post statement of the for loop.
+// Panic if it's not in a loop.
+func (l *LabelManager) ContinueLabel() string {
+	return l.continueStack.Top()
+}
+
 func (l *LabelManager) StartIf() {
 	l.counter["if"]++
 	l.ifStack.Push(strconv.Itoa(l.counter["if"]))